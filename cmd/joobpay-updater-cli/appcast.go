@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/appcast"
+)
+
+// releaseRecordName es el archivo que build deja junto a cada ZIP
+// (releases/{versión}/appcast-item.json) con los datos de su item de appcast.
+// Una release de otro canal usa appcast-item.{canal}.json
+const releaseRecordName = "appcast-item"
+
+// releaseRecord describe una release generada por build. El appcast se genera
+// con todos los registros de un directorio de releases, por lo que retirar una
+// release es borrar su directorio y regenerarlo
+type releaseRecord struct {
+	Title                string `json:"title"`
+	PubDate              string `json:"pub_date,omitempty"`
+	ReleaseNotesLink     string `json:"release_notes_link,omitempty"`
+	Version              string `json:"version"`
+	ShortVersion         string `json:"short_version,omitempty"`
+	MinimumSystemVersion string `json:"minimum_system_version,omitempty"`
+	Channel              string `json:"channel,omitempty"`
+	Critical             bool   `json:"critical,omitempty"`
+
+	// Path es la ruta del ZIP relativa al directorio de releases; la URL del
+	// enclosure se arma con --download-url-prefix al generar el appcast.
+	// URL se usa tal cual (items importados de un appcast existente)
+	Path string `json:"path,omitempty"`
+	URL  string `json:"url,omitempty"`
+
+	Length      int64  `json:"length"`
+	EdSignature string `json:"ed_signature,omitempty"`
+}
+
+// runAppcast genera un appcast con todas las releases de un directorio
+func runAppcast(args []string) error {
+	fs := flag.NewFlagSet("appcast", flag.ExitOnError)
+	releasesDir := fs.String("releases-dir", ".", "Directorio de releases (el --output-dir de build)")
+	output := fs.String("output", "", "Appcast a generar (requerido)")
+	title := fs.String("title", "", "Título del appcast (default: el del appcast existente)")
+	downloadURLPrefix := fs.String("download-url-prefix", "", "URL pública donde se publican los ZIP, para los enclosures (opcional)")
+	fs.Parse(args)
+
+	if *output == "" {
+		fs.Usage()
+		return fmt.Errorf("--output es requerido")
+	}
+
+	return generateAppcast(*releasesDir, *output, *title, *downloadURLPrefix)
+}
+
+// generateAppcast escribe en appcastPath un appcast con las releases de
+// releasesDir, de la más reciente a la más antigua. Conserva el título, link y
+// descripción de un appcast existente
+func generateAppcast(releasesDir, appcastPath, title, downloadURLPrefix string) error {
+	feed, err := readAppcast(appcastPath)
+	if err != nil {
+		return err
+	}
+	if title != "" {
+		feed.Title = title
+	}
+
+	if err := importAppcastReleases(releasesDir, feed); err != nil {
+		return err
+	}
+	records, err := loadReleaseRecords(releasesDir)
+	if err != nil {
+		return err
+	}
+	sortReleaseRecords(records)
+
+	feed.Items = nil
+	for _, record := range records {
+		item, err := record.item(downloadURLPrefix)
+		if err != nil {
+			return err
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	out, err := feed.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(appcastPath, out, 0644); err != nil {
+		return fmt.Errorf("error escribiendo appcast: %w", err)
+	}
+
+	fmt.Printf("Appcast generado con %d releases: %s\n", len(feed.Items), appcastPath)
+	return nil
+}
+
+// readAppcast lee un appcast existente; si no existe retorna uno vacío
+func readAppcast(appcastPath string) (*appcast.Feed, error) {
+	data, err := os.ReadFile(appcastPath)
+	if os.IsNotExist(err) {
+		return &appcast.Feed{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo appcast: %w", err)
+	}
+	return appcast.Parse(data)
+}
+
+// importAppcastReleases crea registros para los items de un appcast existente
+// si releasesDir todavía no tiene ninguno, para no perder las releases
+// generadas antes de que build los creara. Con registros no hace nada: el
+// appcast se genera solo a partir de ellos
+func importAppcastReleases(releasesDir string, feed *appcast.Feed) error {
+	records, err := loadReleaseRecords(releasesDir)
+	if err != nil || len(records) > 0 || len(feed.Items) == 0 {
+		return err
+	}
+
+	fmt.Printf("Importando %d releases del appcast existente\n", len(feed.Items))
+	for _, item := range feed.Items {
+		record := recordFromItem(item)
+		if err := writeReleaseRecord(releasesDir, record.ShortVersion, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseRecordPath retorna el archivo de registro de una release
+func releaseRecordPath(releasesDir, version, channel string) (string, error) {
+	if version == "" || version != path.Base(version) || version == "." || version == ".." || strings.Contains(version, `\`) {
+		return "", fmt.Errorf("versión inválida para el directorio de la release: %q", version)
+	}
+
+	name := releaseRecordName + ".json"
+	if channel != "" {
+		if channel != path.Base(channel) || strings.ContainsAny(channel, `.\`) {
+			return "", fmt.Errorf("canal inválido: %q", channel)
+		}
+		name = releaseRecordName + "." + channel + ".json"
+	}
+	return filepath.Join(releasesDir, "releases", version, name), nil
+}
+
+// writeReleaseRecord guarda el registro de una release en su directorio
+func writeReleaseRecord(releasesDir, version string, record releaseRecord) error {
+	recordPath, err := releaseRecordPath(releasesDir, version, record.Channel)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(recordPath), 0755); err != nil {
+		return fmt.Errorf("error creando directorio de la release: %w", err)
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error generando registro de la release: %w", err)
+	}
+	if err := os.WriteFile(recordPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error escribiendo registro de la release: %w", err)
+	}
+	return nil
+}
+
+// loadReleaseRecords lee los registros de releases/*/ en releasesDir
+func loadReleaseRecords(releasesDir string) ([]releaseRecord, error) {
+	paths, err := filepath.Glob(filepath.Join(releasesDir, "releases", "*", releaseRecordName+"*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []releaseRecord
+	for _, recordPath := range paths {
+		data, err := os.ReadFile(recordPath)
+		if err != nil {
+			return nil, fmt.Errorf("error leyendo %s: %w", recordPath, err)
+		}
+		var record releaseRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("error parseando %s: %w", recordPath, err)
+		}
+		if record.Version == "" || (record.Path == "" && record.URL == "") {
+			return nil, fmt.Errorf("registro de release incompleto: %s", recordPath)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// sortReleaseRecords ordena las releases de la más reciente a la más antigua
+// (por pubDate; las que no tienen fecha válida al final) y, a igual fecha, por
+// ruta, para que el appcast generado sea estable
+func sortReleaseRecords(records []releaseRecord) {
+	pubDate := func(record releaseRecord) time.Time {
+		date, _ := time.Parse(time.RFC1123Z, record.PubDate)
+		return date
+	}
+	sort.SliceStable(records, func(a, b int) bool {
+		dateA, dateB := pubDate(records[a]), pubDate(records[b])
+		if !dateA.Equal(dateB) {
+			return dateA.After(dateB)
+		}
+		return records[a].Path+records[a].URL+records[a].Channel > records[b].Path+records[b].URL+records[b].Channel
+	})
+}
+
+// item arma el item de appcast del registro
+func (r releaseRecord) item(downloadURLPrefix string) (appcast.Item, error) {
+	zipURL := r.URL
+	if zipURL == "" {
+		var err error
+		if zipURL, err = enclosureURL(downloadURLPrefix, r.Path); err != nil {
+			return appcast.Item{}, err
+		}
+	}
+
+	return appcast.Item{
+		Title:                r.Title,
+		PubDate:              r.PubDate,
+		ReleaseNotesLink:     r.ReleaseNotesLink,
+		Version:              r.Version,
+		ShortVersion:         r.ShortVersion,
+		MinimumSystemVersion: r.MinimumSystemVersion,
+		Channel:              r.Channel,
+		Critical:             r.Critical,
+		URL:                  zipURL,
+		Length:               r.Length,
+		EdSignature:          r.EdSignature,
+	}, nil
+}
+
+// recordFromItem convierte un item de un appcast existente en registro
+func recordFromItem(item appcast.Item) releaseRecord {
+	record := releaseRecord{
+		Title:                item.Title,
+		PubDate:              item.PubDate,
+		ReleaseNotesLink:     item.ReleaseNotesLink,
+		Version:              item.Version,
+		ShortVersion:         item.ShortVersion,
+		MinimumSystemVersion: item.MinimumSystemVersion,
+		Channel:              item.Channel,
+		Critical:             item.Critical,
+		URL:                  item.URL,
+		Length:               item.Length,
+		EdSignature:          item.EdSignature,
+	}
+	if record.ShortVersion == "" {
+		record.ShortVersion = item.Version
+	}
+	return record
+}
+
+// enclosureURL construye la URL pública del ZIP a partir del prefijo de descarga
+// y la ruta versionada del payload
+func enclosureURL(prefix, payloadPath string) (string, error) {
+	if prefix == "" {
+		return payloadPath, nil
+	}
+	base, err := url.Parse(strings.TrimSuffix(prefix, "/") + "/")
+	if err != nil {
+		return "", fmt.Errorf("--download-url-prefix inválido: %w", err)
+	}
+	return base.ResolveReference(&url.URL{Path: payloadPath}).String(), nil
+}
+
+// newReleaseRecord arma el registro de appcast de una release generada por build
+func newReleaseRecord(title, version, buildVersion, minimumSystemVersion, channel, payloadPath, edSignature string, size int64, pubDate time.Time) releaseRecord {
+	return releaseRecord{
+		Title:                title,
+		PubDate:              pubDate.UTC().Format(time.RFC1123Z),
+		Version:              buildVersion,
+		ShortVersion:         version,
+		MinimumSystemVersion: minimumSystemVersion,
+		Channel:              channel,
+		Path:                 payloadPath,
+		Length:               size,
+		EdSignature:          edSignature,
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/appcast"
+)
+
+// addRelease registra una release como lo hace build
+func addRelease(t *testing.T, dir, version, channel string, day int) {
+	t.Helper()
+	payloadPath := "releases/" + version + "/MyApp.zip"
+	pubDate := time.Date(2026, time.October, day, 10, 0, 0, 0, time.UTC)
+	record := newReleaseRecord("MyApp "+version, version, version, "13.0", channel, payloadPath, "c2ln", 1024, pubDate)
+	if err := writeReleaseRecord(dir, version, record); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readItems genera el appcast y retorna sus items como "versión canal url"
+func readItems(t *testing.T, dir, appcastPath, prefix string) []string {
+	t.Helper()
+	if err := generateAppcast(dir, appcastPath, "MyApp", prefix); err != nil {
+		t.Fatalf("generateAppcast: %v", err)
+	}
+	feed, err := readAppcast(appcastPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items []string
+	for _, item := range feed.Items {
+		items = append(items, item.ShortVersion+" "+item.Channel+" "+item.URL)
+	}
+	return items
+}
+
+func TestGenerateAppcastFromReleases(t *testing.T) {
+	dir := t.TempDir()
+	appcastPath := filepath.Join(dir, "appcast.xml")
+
+	addRelease(t, dir, "1.0.0", "", 1)
+	addRelease(t, dir, "1.2.0", "beta", 3)
+	addRelease(t, dir, "1.1.0", "", 2)
+	addRelease(t, dir, "1.1.0", "beta", 2)
+
+	got := readItems(t, dir, appcastPath, "https://cdn.example.com/myapp")
+	want := []string{
+		"1.2.0 beta https://cdn.example.com/myapp/releases/1.2.0/MyApp.zip",
+		"1.1.0 beta https://cdn.example.com/myapp/releases/1.1.0/MyApp.zip",
+		"1.1.0  https://cdn.example.com/myapp/releases/1.1.0/MyApp.zip",
+		"1.0.0  https://cdn.example.com/myapp/releases/1.0.0/MyApp.zip",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("items =\n%q\nwant\n%q", got, want)
+	}
+
+	// Reconstruir una release la reemplaza en vez de duplicarla
+	addRelease(t, dir, "1.0.0", "", 1)
+	if got := readItems(t, dir, appcastPath, "https://cdn.example.com/myapp"); !reflect.DeepEqual(got, want) {
+		t.Errorf("items tras reconstruir 1.0.0 =\n%q\nwant\n%q", got, want)
+	}
+
+	// Retirar una release es borrar su directorio
+	if err := os.RemoveAll(filepath.Join(dir, "releases", "1.1.0")); err != nil {
+		t.Fatal(err)
+	}
+	got = readItems(t, dir, appcastPath, "")
+	want = []string{
+		"1.2.0 beta releases/1.2.0/MyApp.zip",
+		"1.0.0  releases/1.0.0/MyApp.zip",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("items tras retirar 1.1.0 =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestGenerateAppcastImportsExistingFeed(t *testing.T) {
+	dir := t.TempDir()
+	appcastPath := filepath.Join(dir, "appcast.xml")
+
+	existing := &appcast.Feed{
+		Title: "MyApp",
+		Link:  "https://example.com/appcast.xml",
+		Items: []appcast.Item{
+			{Title: "MyApp 0.9.0", PubDate: "Wed, 30 Sep 2026 10:00:00 +0000", Version: "90", ShortVersion: "0.9.0", URL: "https://old.example.com/MyApp-0.9.0.zip", Length: 512},
+		},
+	}
+	data, err := existing.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(appcastPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// La primera generación importa las releases del appcast existente
+	if err := importAppcastReleases(dir, existing); err != nil {
+		t.Fatalf("importAppcastReleases: %v", err)
+	}
+	addRelease(t, dir, "1.0.0", "", 1)
+
+	got := readItems(t, dir, appcastPath, "https://cdn.example.com")
+	want := []string{
+		"1.0.0  https://cdn.example.com/releases/1.0.0/MyApp.zip",
+		"0.9.0  https://old.example.com/MyApp-0.9.0.zip",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("items =\n%q\nwant\n%q", got, want)
+	}
+
+	feed, err := readAppcast(appcastPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Link != existing.Link {
+		t.Errorf("link = %q, want %q", feed.Link, existing.Link)
+	}
+
+	// Con registros el appcast ya no se vuelve a importar: retirar la release
+	// importada la saca del appcast
+	if err := os.RemoveAll(filepath.Join(dir, "releases", "0.9.0")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readItems(t, dir, appcastPath, "https://cdn.example.com"); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("items tras retirar 0.9.0 =\n%q\nwant\n%q", got, want[:1])
+	}
+}
+
+func TestReleaseRecordPathRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		version string
+		channel string
+	}{
+		{"", ""},
+		{"..", ""},
+		{"1.0/../..", ""},
+		{"1.0.0", "../beta"},
+		{"1.0.0", "beta.json"},
+	}
+
+	for _, tt := range tests {
+		if _, err := releaseRecordPath(t.TempDir(), tt.version, tt.channel); err == nil {
+			t.Errorf("releaseRecordPath(%q, %q) debería fallar", tt.version, tt.channel)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/bundle"
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/utils"
+)
+
+// Manifest representa la estructura del archivo JSON de manifiesto
+type Manifest struct {
+	Version       string            `json:"version"`
+	Checksum      string            `json:"checksum"`
+	Digests       map[string]string `json:"digests,omitempty"`
+	URL           string            `json:"url,omitempty"`
+	Size          int64             `json:"size,omitempty"`
+	InstalledSize int64             `json:"installed_size,omitempty"`
+	EdSignature   string            `json:"ed_signature,omitempty"`
+}
+
+// releasePayloadPath retorna la ruta versionada del payload,
+// releases/{versión}/{zip}. La versión se valida antes de usarla en la ruta:
+// "../.." o "a/b" sacarían el ZIP de releases/ o cambiarían su key
+func releasePayloadPath(version, zipFileName string) (string, error) {
+	if err := validateReleaseVersion(version); err != nil {
+		return "", err
+	}
+	return path.Join("releases", version, zipFileName), nil
+}
+
+// runBuild empaqueta el bundle: ZIP, notarización opcional, digests y manifiesto.
+// Es el comportamiento original del CLI, que sigue disponible sin subcomando
+func runBuild(args []string) {
+	var zipFileName string
+
+	// Definir flags
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	appPath := fs.String("app-path", "", "Ruta al bundle .app (requerido)")
+	version := fs.String("version", "", "Versión de la actualización (opcional, default: CFBundleShortVersionString del Info.plist)")
+	outputName := fs.String("output-name", "", "Nombre base del archivo de salida (opcional)")
+	keychainProfile := fs.String("keychain-profile", "", "Perfil de Keychain para notarización (opcional)")
+	outputDir := fs.String("output-dir", ".", "Directorio donde guardar los archivos generados (opcional)")
+	reproducible := fs.Bool("reproducible", false, "Generar un ZIP reproducible byte a byte (opcional, implícito si SOURCE_DATE_EPOCH está definido)")
+	jobs := fs.Int("jobs", 0, "Cantidad de archivos a comprimir en paralelo (opcional, default: número de CPUs)")
+	sourceDateEpoch := fs.String("source-date-epoch", "", "Timestamp Unix para las entradas del ZIP reproducible (opcional, default: $SOURCE_DATE_EPOCH)")
+	edKeyFile := fs.String("ed-key-file", "", "Archivo con la clave privada Ed25519 en base64 para firmar el ZIP (opcional)")
+	appcastFile := fs.String("appcast", "", "Appcast de Sparkle a generar con todas las releases de --output-dir (opcional)")
+	downloadURLPrefix := fs.String("download-url-prefix", "", "URL pública donde se publicará el ZIP, para el enclosure del appcast (opcional)")
+	channel := fs.String("channel", "", "Canal de Sparkle de la release en el appcast (opcional)")
+
+	fs.Parse(args)
+
+	// Validar flags requeridos
+	if *appPath == "" {
+		fmt.Println("Error: --app-path es requerido")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if *outputName == "" {
+		// Usar el nombre del .app sin la extensión
+		baseName := filepath.Base(*appPath)
+		*outputName = strings.TrimSuffix(baseName, ".app")
+	}
+
+	// Verificar que el .app existe
+	if _, err := os.Stat(*appPath); os.IsNotExist(err) {
+		fmt.Printf("Error: el bundle .app no existe: %s\n", *appPath)
+		os.Exit(1)
+	}
+
+	// Verificar que es un directorio .app
+	if !strings.HasSuffix(*appPath, ".app") {
+		fmt.Println("Error: --app-path debe apuntar a un bundle .app")
+		os.Exit(1)
+	}
+
+	// Leer el Info.plist (versión por defecto y datos del appcast)
+	info, infoErr := bundle.ReadInfo(*appPath)
+
+	// Tomar la versión del Info.plist si no se especificó
+	if *version == "" {
+		if infoErr != nil {
+			fmt.Printf("Error: --version no especificado y no se pudo leer el Info.plist: %v\n", infoErr)
+			os.Exit(1)
+		}
+		if info.ShortVersion == "" {
+			fmt.Println("Error: --version no especificado y el Info.plist no define CFBundleShortVersionString")
+			os.Exit(1)
+		}
+		*version = info.ShortVersion
+		fmt.Printf("Versión detectada en Info.plist: %s\n", *version)
+	}
+
+	// Crear directorio de salida si no existe
+	if err := os.MkdirAll(*outputDir, 0755); err != nil {
+		fmt.Printf("Error creando directorio de salida: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Resolver opciones de ZIP reproducible
+	zipOptions, err := resolveZipOptions(*reproducible, *sourceDateEpoch)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	zipOptions.Jobs = *jobs
+
+	// Detectar arquitectura
+	arch := runtime.GOARCH
+	fmt.Printf("Arquitectura detectada: %s\n", arch)
+
+	// Paso 1: Crear ZIP con el bundle adentro, bajo una ruta única por versión
+	// (releases/{versión}/{nombre}.zip) para que una CDN nunca sirva un ZIP
+	// cacheado de otra release junto al manifiesto nuevo
+	zipFileName = fmt.Sprintf("%s.zip", *outputName)
+	payloadPath, err := releasePayloadPath(*version, zipFileName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	zipFilePath := filepath.Join(*outputDir, filepath.FromSlash(payloadPath))
+	if err := os.MkdirAll(filepath.Dir(zipFilePath), 0755); err != nil {
+		fmt.Printf("Error creando directorio de salida: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Creando archivo ZIP: %s\n", zipFilePath)
+
+	if zipOptions.Reproducible {
+		fmt.Printf("Modo reproducible activado (timestamp: %s)\n", zipOptions.ModTime.Format(time.RFC3339))
+	}
+
+	if err := utils.ZipDirectoryWithOptions(*appPath, zipFilePath, zipOptions); err != nil {
+		fmt.Printf("Error creando ZIP: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("ZIP creado exitosamente")
+
+	// Paso 2: Notarizar el ZIP (solo si se proporciona keychain-profile)
+	if *keychainProfile != "" {
+		fmt.Println("Iniciando proceso de notarización del ZIP...")
+
+		if err := notarizeZip(zipFilePath, *keychainProfile); err != nil {
+			fmt.Printf("Error en notarización: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Notarización completada exitosamente")
+	}
+
+	// Paso 3: Calcular los digests del ZIP final en una sola lectura
+	fmt.Println("Calculando digests (sha256, sha512, blake3)...")
+	digests, err := utils.CalculateDigests(zipFilePath, utils.HashSHA256, utils.HashSHA512, utils.HashBLAKE3)
+	if err != nil {
+		fmt.Printf("Error calculando checksum: %v\n", err)
+		os.Exit(1)
+	}
+	checksum := digests[utils.HashSHA256]
+	fmt.Printf("Checksum: %s\n", checksum)
+
+	// Firmar el ZIP con EdDSA (compatible con sparkle:edSignature)
+	var edSignature string
+	if *edKeyFile != "" {
+		key, err := loadEdDSAKey(*edKeyFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if edSignature, err = signEdDSA(zipFilePath, key); err != nil {
+			fmt.Printf("Error firmando ZIP: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("ZIP firmado con EdDSA")
+	}
+
+	zipInfo, err := os.Stat(zipFilePath)
+	if err != nil {
+		fmt.Printf("Error leyendo ZIP: %v\n", err)
+		os.Exit(1)
+	}
+
+	installedSize, err := utils.UncompressedSize(zipFilePath)
+	if err != nil {
+		fmt.Printf("Error leyendo ZIP: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Paso 4: Generar manifiesto JSON
+	manifest := Manifest{
+		Version:       *version,
+		Checksum:      checksum,
+		Digests:       digests,
+		URL:           payloadPath,
+		Size:          zipInfo.Size(),
+		InstalledSize: int64(installedSize),
+		EdSignature:   edSignature,
+	}
+
+	manifestFileName := fmt.Sprintf("darwin-%s.json", arch)
+	manifestFilePath := filepath.Join(*outputDir, manifestFileName)
+	fmt.Printf("Generando manifiesto: %s\n", manifestFilePath)
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		fmt.Printf("Error generando JSON: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(manifestFilePath, manifestData, 0644); err != nil {
+		fmt.Printf("Error escribiendo manifiesto: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Manifiesto generado exitosamente")
+
+	// Paso 5: Registrar la release y regenerar el appcast de Sparkle con todas
+	// las releases de --output-dir
+	if *appcastFile != "" {
+		buildVersion, minimumSystemVersion := *version, ""
+		if infoErr == nil {
+			if info.Version != "" {
+				buildVersion = info.Version
+			}
+			minimumSystemVersion = info.MinimumSystemVersion
+		}
+
+		pubDate := time.Now()
+		if zipOptions.Reproducible {
+			pubDate = zipOptions.ModTime
+		}
+
+		title := strings.TrimSuffix(filepath.Base(*appPath), ".app")
+		// Un appcast generado antes de los registros se importa antes de
+		// agregar esta release
+		feed, err := readAppcast(*appcastFile)
+		if err == nil {
+			err = importAppcastReleases(*outputDir, feed)
+		}
+		if err != nil {
+			fmt.Printf("Error leyendo appcast: %v\n", err)
+			os.Exit(1)
+		}
+
+		record := newReleaseRecord(fmt.Sprintf("%s %s", title, *version), *version, buildVersion, minimumSystemVersion, *channel, payloadPath, edSignature, zipInfo.Size(), pubDate)
+		if err := writeReleaseRecord(*outputDir, *version, record); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Generando appcast: %s\n", *appcastFile)
+		if err := generateAppcast(*outputDir, *appcastFile, title, *downloadURLPrefix); err != nil {
+			fmt.Printf("Error generando appcast: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Println("")
+	fmt.Println("========================================")
+	fmt.Println("BUILD COMPLETADO")
+	fmt.Println("========================================")
+	fmt.Printf("   ZIP: %s\n", zipFilePath)
+	fmt.Printf("   Manifiesto: %s\n", manifestFilePath)
+	if *appcastFile != "" {
+		fmt.Printf("   Appcast: %s\n", *appcastFile)
+	}
+	fmt.Printf("   Versión: %s\n", *version)
+	fmt.Printf("   Arquitectura: darwin-%s\n", arch)
+	if *keychainProfile != "" {
+		fmt.Println("   Notarizado: [✓]")
+	} else {
+		fmt.Println("   Notarizado: [✗]")
+	}
+	fmt.Println("========================================")
+}
+
+// resolveZipOptions determina las opciones de ZIP a partir de los flags y de
+// la variable de entorno SOURCE_DATE_EPOCH (https://reproducible-builds.org)
+func resolveZipOptions(reproducible bool, sourceDateEpoch string) (utils.ZipOptions, error) {
+	if sourceDateEpoch == "" {
+		sourceDateEpoch = os.Getenv("SOURCE_DATE_EPOCH")
+	}
+
+	if sourceDateEpoch == "" {
+		if !reproducible {
+			return utils.ZipOptions{}, nil
+		}
+		return utils.ZipOptions{Reproducible: true, ModTime: utils.DefaultZipModTime}, nil
+	}
+
+	seconds, err := strconv.ParseInt(sourceDateEpoch, 10, 64)
+	if err != nil {
+		return utils.ZipOptions{}, fmt.Errorf("SOURCE_DATE_EPOCH inválido: %s", sourceDateEpoch)
+	}
+
+	modTime := time.Unix(seconds, 0).UTC()
+	// El formato ZIP (MS-DOS) no representa fechas anteriores a 1980
+	if modTime.Before(utils.DefaultZipModTime) {
+		modTime = utils.DefaultZipModTime
+	}
+
+	return utils.ZipOptions{Reproducible: true, ModTime: modTime}, nil
+}
+
+// notarizeZip envía el ZIP a Apple para notarización
+func notarizeZip(zipPath, keychainProfile string) error {
+	fmt.Printf("   ⏳ Enviando %s a Apple para notarización (esto puede tomar varios minutos)...\n", zipPath)
+
+	cmd := exec.Command("xcrun", "notarytool", "submit",
+		zipPath,
+		"--keychain-profile", keychainProfile,
+		"--wait",
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("notarización fallida: %w", err)
+	}
+
+	fmt.Println("   ✅ Notarización aprobada por Apple")
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/utils"
+)
+
+func TestResolveZipOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          string
+		reproducible bool
+		flag         string
+		want         utils.ZipOptions
+		wantErr      bool
+	}{
+		{"por defecto", "", false, "", utils.ZipOptions{}, false},
+		{"--reproducible", "", true, "", utils.ZipOptions{Reproducible: true, ModTime: utils.DefaultZipModTime}, false},
+		{"SOURCE_DATE_EPOCH", "1700000000", false, "", utils.ZipOptions{Reproducible: true, ModTime: time.Unix(1700000000, 0).UTC()}, false},
+		{"el flag tiene prioridad", "1700000000", false, "1600000000", utils.ZipOptions{Reproducible: true, ModTime: time.Unix(1600000000, 0).UTC()}, false},
+		{"anterior a 1980", "0", false, "", utils.ZipOptions{Reproducible: true, ModTime: utils.DefaultZipModTime}, false},
+		{"inválido", "ayer", false, "", utils.ZipOptions{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SOURCE_DATE_EPOCH", tt.env)
+
+			got, err := resolveZipOptions(tt.reproducible, tt.flag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveZipOptions error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Reproducible != tt.want.Reproducible || !got.ModTime.Equal(tt.want.ModTime) {
+				t.Errorf("resolveZipOptions = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleasePayloadPath(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+		wantErr bool
+	}{
+		{"1.2.0", "releases/1.2.0/MyApp.zip", false},
+		{"2026.10.3", "releases/2026.10.3/MyApp.zip", false},
+		{"412", "releases/412/MyApp.zip", false},
+		{"../..", "", true},
+		{"a/b", "", true},
+		{"1.0/../../x", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := releasePayloadPath(tt.version, "MyApp.zip")
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("releasePayloadPath(%q) = %q, %v, want %q (error: %v)", tt.version, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSourceDateEpochReproducibleZip(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	opts, err := resolveZipOptions(false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appPath := filepath.Join(t.TempDir(), "MyApp.app")
+	writeFile(t, filepath.Join(appPath, "Contents", "Info.plist"), "<plist/>")
+	writeFile(t, filepath.Join(appPath, "Contents", "MacOS", "MyApp"), "#!/bin/sh\n")
+
+	build := func() []byte {
+		zipPath := filepath.Join(t.TempDir(), "MyApp.zip")
+		if err := utils.ZipDirectoryWithOptions(appPath, zipPath, opts); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(zipPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	first := build()
+
+	// Otra máquina: otros mtimes y otro umask
+	later := time.Now().Add(time.Hour)
+	for _, path := range []string{appPath, filepath.Join(appPath, "Contents", "Info.plist")} {
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(appPath, "Contents", "Info.plist"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(build(), first) {
+		t.Error("con SOURCE_DATE_EPOCH el ZIP debería ser idéntico byte a byte")
+	}
+}
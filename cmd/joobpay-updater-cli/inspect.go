@@ -0,0 +1,250 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/appcast"
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/bundle"
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/codesign"
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/utils"
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/pkg/updater"
+)
+
+// runInspect muestra los detalles de un bundle .app, un ZIP de release o un
+// manifiesto/appcast
+func runInspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	appPath := fs.String("app-path", "", "Bundle .app a inspeccionar")
+	zipPath := fs.String("zip", "", "ZIP de release a inspeccionar")
+	manifestPath := fs.String("manifest", "", "Manifiesto JSON o appcast XML a inspeccionar")
+	fs.Parse(args)
+
+	if *appPath == "" && *zipPath == "" && *manifestPath == "" {
+		fs.Usage()
+		return fmt.Errorf("indique --app-path, --zip o --manifest")
+	}
+
+	if *appPath != "" {
+		if err := inspectApp(*appPath); err != nil {
+			return err
+		}
+	}
+	if *zipPath != "" {
+		if err := inspectZip(*zipPath); err != nil {
+			return err
+		}
+	}
+	if *manifestPath != "" {
+		if err := inspectManifest(*manifestPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inspectApp muestra el Info.plist y la firma de un bundle
+func inspectApp(appPath string) error {
+	info, err := bundle.ReadInfo(appPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Bundle: %s\n", appPath)
+	printBundleInfo(info)
+
+	exePath, err := bundle.ExecutablePath(appPath)
+	if err != nil {
+		return err
+	}
+	signature, err := codesign.ReadSignature(exePath)
+	printSignature(signature, err)
+
+	return nil
+}
+
+// inspectZip muestra el contenido, los digests y el bundle de un ZIP de release
+func inspectZip(zipPath string) error {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("error abriendo zip: %w", err)
+	}
+	defer reader.Close()
+
+	var uncompressed uint64
+	for _, file := range reader.File {
+		uncompressed += file.UncompressedSize64
+	}
+
+	stat, err := os.Stat(zipPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("ZIP: %s\n", zipPath)
+	fmt.Printf("   Entradas: %d\n", len(reader.File))
+	fmt.Printf("   Tamaño: %d bytes (%d descomprimido)\n", stat.Size(), uncompressed)
+
+	digests, err := utils.CalculateDigests(zipPath, utils.SupportedHashAlgorithms()...)
+	if err != nil {
+		return err
+	}
+	for _, algorithm := range utils.SupportedHashAlgorithms() {
+		fmt.Printf("   %s: %s\n", algorithm, digests[algorithm])
+	}
+
+	info, executable, err := readZipBundle(zipPath)
+	if err != nil {
+		return err
+	}
+	printBundleInfo(info)
+
+	signature, err := codesign.ReadSignatureFrom(bytes.NewReader(executable))
+	printSignature(signature, err)
+
+	return nil
+}
+
+// inspectManifest muestra un manifiesto JSON o un appcast
+func inspectManifest(manifestPath string) error {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return fmt.Errorf("error leyendo manifiesto: %w", err)
+	}
+
+	fmt.Printf("Manifiesto: %s\n", manifestPath)
+	if _, err := os.Stat(manifestPath + ".sig"); err == nil {
+		fmt.Println("   Firma: presente (.sig)")
+	} else {
+		fmt.Println("   Firma: ausente")
+	}
+
+	if strings.HasSuffix(manifestPath, ".xml") {
+		feed, err := appcast.Parse(data)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("   Appcast: %s (%d releases)\n", feed.Title, len(feed.Items))
+		for _, item := range feed.Items {
+			channel := item.Channel
+			if channel == "" {
+				channel = "default"
+			}
+			fmt.Printf("   - %s (%s) canal=%s url=%s length=%d edSignature=%t\n",
+				item.ShortVersion, item.Version, channel, item.URL, item.Length, item.EdSignature != "")
+		}
+		return nil
+	}
+
+	manifest, err := updater.ParseManifest(data)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("   Versión: %s\n", manifest.Version)
+	fmt.Printf("   Checksum: %s\n", manifest.Checksum)
+	for algorithm, digest := range manifest.Digests {
+		fmt.Printf("   %s: %s\n", algorithm, digest)
+	}
+	fmt.Printf("   Firma EdDSA del ZIP: %t\n", manifest.EdSignature != "")
+	fmt.Printf("   Rollback: %t\n", manifest.Rollback)
+	fmt.Printf("   Crítica: %t\n", manifest.Critical)
+
+	return nil
+}
+
+// readZipBundle lee, sin descomprimir a disco, el Info.plist y el ejecutable
+// principal del bundle .app contenido en un ZIP de release
+func readZipBundle(zipPath string) (*bundle.Info, []byte, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error abriendo zip: %w", err)
+	}
+	defer reader.Close()
+
+	files := make(map[string]*zip.File, len(reader.File))
+	var infoPlist string
+	for _, file := range reader.File {
+		files[file.Name] = file
+		parts := strings.Split(file.Name, "/")
+		if len(parts) == 3 && strings.HasSuffix(parts[0], ".app") && parts[1] == "Contents" && parts[2] == "Info.plist" {
+			if infoPlist != "" {
+				return nil, nil, fmt.Errorf("el zip contiene más de un bundle .app")
+			}
+			infoPlist = file.Name
+		}
+	}
+	if infoPlist == "" {
+		return nil, nil, fmt.Errorf("no se encontró un bundle .app en el zip")
+	}
+
+	data, err := readZipEntry(files[infoPlist])
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := bundle.ParseInfo(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	appDir := strings.SplitN(infoPlist, "/", 2)[0]
+	executableName := info.Executable
+	if executableName == "" {
+		executableName = strings.TrimSuffix(appDir, ".app")
+	}
+	executable, ok := files[path.Join(appDir, "Contents", "MacOS", executableName)]
+	if !ok {
+		return nil, nil, fmt.Errorf("no se encontró el ejecutable principal %s en el zip", executableName)
+	}
+
+	data, err = readZipEntry(executable)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return info, data, nil
+}
+
+// readZipEntry lee el contenido completo de una entrada del ZIP
+func readZipEntry(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo %s en zip: %w", file.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo %s en zip: %w", file.Name, err)
+	}
+	return data, nil
+}
+
+// printBundleInfo muestra los campos relevantes del Info.plist
+func printBundleInfo(info *bundle.Info) {
+	fmt.Printf("   CFBundleIdentifier: %s\n", info.Identifier)
+	fmt.Printf("   CFBundleShortVersionString: %s\n", info.ShortVersion)
+	fmt.Printf("   CFBundleVersion: %s\n", info.Version)
+	fmt.Printf("   CFBundleExecutable: %s\n", info.Executable)
+	fmt.Printf("   LSMinimumSystemVersion: %s\n", info.MinimumSystemVersion)
+}
+
+// printSignature muestra la firma de código del ejecutable principal
+func printSignature(signature *codesign.Signature, err error) {
+	if err != nil {
+		fmt.Printf("   Firma de código: %v\n", err)
+		return
+	}
+	if signature.AdHoc {
+		fmt.Printf("   Firma de código: ad-hoc (identificador: %s)\n", signature.Identifier)
+		return
+	}
+	fmt.Printf("   Firma de código: Team ID %s (certificado: %s), identificador: %s\n",
+		signature.TeamID, signature.CertificateTeamID, signature.Identifier)
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/s3"
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/pkg/updater"
+)
+
+// payloadCacheControl se aplica a los ZIP versionados: su key es única por
+// versión, así que pueden cachearse indefinidamente. La copia legacy cambia en
+// cada release y usa el Cache-Control de los manifiestos
+const payloadCacheControl = "public, max-age=31536000, immutable"
+
+// publisher sube los archivos de una release a su destino
+type publisher interface {
+	// Upload publica el archivo local bajo la key indicada
+	Upload(key, localPath string, opts s3.PutOptions) error
+
+	// Verify comprueba que el objeto publicado coincide con el archivo local
+	Verify(key, localPath string) error
+}
+
+// runPublish publica una release. El ZIP se sube primero bajo una key
+// versionada (y la key legacy), junto con los ZIP de los componentes, y se
+// verifican; solo entonces se sobrescriben los manifiestos, de modo que ningún
+// cliente ve un manifiesto que apunte a un ZIP inexistente
+func runPublish(args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	zipPath := fs.String("zip", "", "ZIP de la release (requerido)")
+	manifestPath := fs.String("manifest", "", "Manifiesto JSON de la release (requerido)")
+	appcastPath := fs.String("appcast", "", "Appcast de Sparkle a publicar (opcional)")
+	dest := fs.String("dest", "", "Directorio de destino (requerido si no se usa --s3-bucket)")
+	bucket := fs.String("s3-bucket", "", "Bucket S3 de destino (AWS S3, MinIO, R2)")
+	endpoint := fs.String("s3-endpoint", "", "Endpoint S3 (opcional, default: https://s3.{región}.amazonaws.com)")
+	region := fs.String("s3-region", "", "Región S3 (opcional, default: $AWS_REGION o us-east-1; R2 usa auto)")
+	prefix := fs.String("prefix", "", "Prefijo de las keys dentro del destino (opcional)")
+	manifestCacheControl := fs.String("manifest-cache-control", "no-cache", "Cache-Control de manifiestos, appcast y firmas")
+	legacyName := fs.String("legacy-name", "", "Nombre del ZIP para clientes legacy que descargan SourceURL + ZipFileName (default: nombre del ZIP)")
+	noLegacy := fs.Bool("no-legacy", false, "No publicar el ZIP bajo la key legacy")
+	componentsDir := fs.String("components-dir", "", "Directorio donde están los ZIP de los componentes, según su url relativa (default: directorio del manifiesto)")
+	fs.Parse(args)
+
+	if *zipPath == "" || *manifestPath == "" || (*dest == "" && *bucket == "") {
+		fs.Usage()
+		return fmt.Errorf("--zip, --manifest y --dest o --s3-bucket son requeridos")
+	}
+
+	var pub publisher
+	if *bucket != "" {
+		client, err := s3.NewClientFromEnv(*endpoint, *region, *bucket)
+		if err != nil {
+			return err
+		}
+		pub = &s3Publisher{client: client}
+	} else {
+		pub = &dirPublisher{root: *dest}
+	}
+
+	// Validar localmente el par ZIP/manifiesto antes de publicar nada
+	data, err := os.ReadFile(*manifestPath)
+	if err != nil {
+		return fmt.Errorf("error leyendo manifiesto: %w", err)
+	}
+	manifest, err := updater.ParseManifest(data)
+	if err != nil {
+		return err
+	}
+	if err := manifest.VerifyFile(*zipPath, ""); err != nil {
+		return fmt.Errorf("el ZIP no coincide con el manifiesto: %w", err)
+	}
+	if *componentsDir == "" {
+		*componentsDir = filepath.Dir(*manifestPath)
+	}
+	components, err := resolveComponentPayloads(*prefix, *componentsDir, manifest)
+	if err != nil {
+		return err
+	}
+
+	// La copia legacy usa el nombre del ZIP en la raíz del prefijo
+	legacyKey := ""
+	if !*noLegacy {
+		name := *legacyName
+		if name == "" {
+			name = filepath.Base(*zipPath)
+		}
+		if name != path.Base(name) || name == "." || name == ".." {
+			return fmt.Errorf("--legacy-name debe ser un nombre de archivo: %q", name)
+		}
+		legacyKey = path.Join(*prefix, name)
+	}
+
+	// Paso 1: Payload bajo una key versionada, verificado tras la subida
+	payloadKey, err := resolvePayloadKey(*prefix, manifest, *zipPath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Publicando %s...\n", payloadKey)
+	if err := pub.Upload(payloadKey, *zipPath, s3.PutOptions{
+		ContentType:  "application/zip",
+		CacheControl: payloadCacheControl,
+	}); err != nil {
+		return err
+	}
+	if err := pub.Verify(payloadKey, *zipPath); err != nil {
+		return err
+	}
+	fmt.Println("Payload verificado: [✓]")
+
+	// Paso 2: Copia bajo la key legacy (sin versión) para los clientes que
+	// todavía descargan SourceURL + ZipFileName. Se publica antes que el
+	// manifiesto, igual que el payload versionado
+	if legacyKey != "" && legacyKey != payloadKey {
+		fmt.Printf("Publicando %s (clientes legacy)...\n", legacyKey)
+		if err := pub.Upload(legacyKey, *zipPath, s3.PutOptions{
+			ContentType:  "application/zip",
+			CacheControl: *manifestCacheControl,
+		}); err != nil {
+			return err
+		}
+		if err := pub.Verify(legacyKey, *zipPath); err != nil {
+			return err
+		}
+	}
+
+	// Paso 3: Payloads de los componentes, también antes del manifiesto que
+	// los referencia
+	for _, component := range components {
+		fmt.Printf("Publicando %s (componente %s)...\n", component.key, component.name)
+		if err := pub.Upload(component.key, component.localPath, s3.PutOptions{
+			ContentType:  "application/zip",
+			CacheControl: component.cacheControl(manifest.Version, *prefix, *manifestCacheControl),
+		}); err != nil {
+			return err
+		}
+		if err := pub.Verify(component.key, component.localPath); err != nil {
+			return err
+		}
+	}
+	if len(components) > 0 {
+		fmt.Println("Componentes verificados: [✓]")
+	}
+
+	// Paso 4: Manifiestos. La firma de cada uno se sube antes bajo una key
+	// direccionada por contenido (signatures/{sha256}.sig), que no cambia al
+	// publicar otra versión: al sobrescribir el manifiesto el cliente ya
+	// encuentra su firma. {manifiesto}.sig se mantiene para clientes anteriores
+	for _, manifest := range []string{*manifestPath, *appcastPath} {
+		if manifest == "" {
+			continue
+		}
+		if err := publishManifest(pub, *prefix, manifest, *manifestCacheControl); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Release publicada exitosamente")
+
+	return nil
+}
+
+// publishManifest publica un manifiesto o appcast y, si existe, su firma
+// ({manifiesto}.sig local): primero la firma direccionada por contenido,
+// luego la firma legacy y por último el manifiesto
+func publishManifest(pub publisher, prefix, manifestPath, cacheControl string) error {
+	key := path.Join(prefix, filepath.Base(manifestPath))
+	signaturePath := manifestPath + ".sig"
+
+	if _, err := os.Stat(signaturePath); err == nil {
+		data, err := os.ReadFile(manifestPath)
+		if err != nil {
+			return fmt.Errorf("error leyendo %s: %w", manifestPath, err)
+		}
+
+		signatureKey := path.Join(path.Dir(key), updater.ManifestSignaturePath(data))
+		fmt.Printf("Publicando %s...\n", signatureKey)
+		if err := pub.Upload(signatureKey, signaturePath, s3.PutOptions{
+			ContentType:  contentType(signaturePath),
+			CacheControl: payloadCacheControl,
+		}); err != nil {
+			return err
+		}
+		if err := pub.Verify(signatureKey, signaturePath); err != nil {
+			return err
+		}
+
+		fmt.Printf("Publicando %s...\n", key+".sig")
+		if err := pub.Upload(key+".sig", signaturePath, s3.PutOptions{
+			ContentType:  contentType(signaturePath),
+			CacheControl: cacheControl,
+		}); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Publicando %s...\n", key)
+	return pub.Upload(key, manifestPath, s3.PutOptions{
+		ContentType:  contentType(manifestPath),
+		CacheControl: cacheControl,
+	})
+}
+
+// resolvePayloadKey determina la key del ZIP: la ruta relativa que anuncia el
+// manifiesto o, si es absoluta o no existe, releases/{versión}/{zip}
+func resolvePayloadKey(prefix string, manifest *updater.Manifest, zipPath string) (string, error) {
+	if err := validateReleaseVersion(manifest.Version); err != nil {
+		return "", err
+	}
+
+	if manifest.URL != "" {
+		payload, err := url.Parse(manifest.URL)
+		if err != nil {
+			return "", fmt.Errorf("url del manifiesto inválida: %w", err)
+		}
+		if !payload.IsAbs() && !strings.HasPrefix(payload.Path, "/") && !strings.Contains(payload.Path, "..") {
+			return path.Join(prefix, payload.Path), nil
+		}
+	}
+	return path.Join(prefix, "releases", manifest.Version, filepath.Base(zipPath)), nil
+}
+
+// componentPayload es el ZIP local de un componente y la key donde se publica
+type componentPayload struct {
+	name      string
+	key       string
+	localPath string
+}
+
+// resolveComponentPayloads localiza y valida los ZIP de los componentes del
+// manifiesto. Una url relativa se publica bajo {prefix}/{url}, que es donde la
+// resuelve el cliente, y el ZIP local se busca en {componentsDir}/{url}. Una url
+// absoluta apunta fuera del destino y no se publica
+func resolveComponentPayloads(prefix, componentsDir string, manifest *updater.Manifest) ([]componentPayload, error) {
+	var payloads []componentPayload
+	for _, component := range manifest.Components {
+		ref, err := url.Parse(component.URL)
+		if err != nil {
+			return nil, fmt.Errorf("url del componente %s inválida: %w", component.Name, err)
+		}
+		if ref.IsAbs() || ref.Host != "" {
+			fmt.Printf("Componente %s: %s está fuera del destino, no se publica\n", component.Name, component.URL)
+			continue
+		}
+		if ref.Path == "" || strings.HasPrefix(ref.Path, "/") || strings.Contains(ref.Path, "..") {
+			return nil, fmt.Errorf("url del componente %s inválida: %q", component.Name, component.URL)
+		}
+
+		localPath := filepath.Join(componentsDir, filepath.FromSlash(ref.Path))
+		check := &updater.Manifest{
+			Version:     manifest.Version,
+			Checksum:    component.Checksum,
+			Digests:     component.Digests,
+			Size:        component.Size,
+			EdSignature: component.EdSignature,
+		}
+		if err := check.VerifyFile(localPath, ""); err != nil {
+			return nil, fmt.Errorf("el ZIP del componente %s no coincide con el manifiesto: %w", component.Name, err)
+		}
+
+		payloads = append(payloads, componentPayload{
+			name:      component.Name,
+			key:       path.Join(prefix, ref.Path),
+			localPath: localPath,
+		})
+	}
+	return payloads, nil
+}
+
+// cacheControl retorna el Cache-Control del componente: inmutable si su key
+// está bajo releases/{versión}/, como el payload; si no, el de los manifiestos,
+// porque otra release puede sobrescribirla
+func (c componentPayload) cacheControl(version, prefix, manifestCacheControl string) string {
+	if strings.HasPrefix(c.key, path.Join(prefix, "releases", version)+"/") {
+		return payloadCacheControl
+	}
+	return manifestCacheControl
+}
+
+// validateReleaseVersion exige que la versión del manifiesto sea válida en
+// alguno de los esquemas incluidos. Ninguno acepta "/" ni "..", por lo que la
+// versión no puede sacar el payload de releases/
+func validateReleaseVersion(version string) error {
+	for _, comparator := range []updater.VersionComparator{
+		updater.SemverComparator,
+		updater.CalverComparator,
+		updater.BuildNumberComparator,
+	} {
+		if _, err := comparator.Compare(version, version); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("versión del manifiesto inválida: %q", version)
+}
+
+// contentType determina el Content-Type por extensión
+func contentType(file string) string {
+	switch {
+	case strings.HasSuffix(file, ".json"):
+		return "application/json"
+	case strings.HasSuffix(file, ".xml"):
+		return "application/xml"
+	case strings.HasSuffix(file, ".sig"):
+		return "text/plain"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// fileDigests calcula tamaño, SHA-256 y MD5 de un archivo local
+func fileDigests(localPath string) (int64, string, string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("error abriendo %s: %w", localPath, err)
+	}
+	defer file.Close()
+
+	sha := sha256.New()
+	sum := md5.New()
+	size, err := io.Copy(io.MultiWriter(sha, sum), file)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("error leyendo %s: %w", localPath, err)
+	}
+	return size, hex.EncodeToString(sha.Sum(nil)), hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// s3Publisher publica en almacenamiento compatible con S3
+type s3Publisher struct {
+	client *s3.Client
+}
+
+// Upload sube el archivo. El cliente envía su SHA-256 y MD5 firmados, de modo
+// que el servidor rechaza un contenido alterado en tránsito
+func (p *s3Publisher) Upload(key, localPath string, opts s3.PutOptions) error {
+	return p.client.PutFile(key, localPath, opts)
+}
+
+// Verify compara el tamaño del objeto publicado con el archivo local y su
+// contenido: con el ETag si es el MD5 (subidas de una sola parte sin cifrado
+// KMS) o, si no, descargando el objeto y comparando su SHA-256
+func (p *s3Publisher) Verify(key, localPath string) error {
+	size, sha, sum, err := fileDigests(localPath)
+	if err != nil {
+		return err
+	}
+
+	info, err := p.client.HeadObject(key)
+	if err != nil {
+		return err
+	}
+	if info.Size != size {
+		return fmt.Errorf("el objeto %s tiene %d bytes, se esperaban %d", key, info.Size, size)
+	}
+	if info.ETag == sum {
+		return nil
+	}
+
+	body, err := p.client.GetObject(key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	published := sha256.New()
+	if _, err := io.Copy(published, body); err != nil {
+		return fmt.Errorf("error descargando %s: %w", key, err)
+	}
+	if hex.EncodeToString(published.Sum(nil)) != sha {
+		return fmt.Errorf("el contenido del objeto %s no coincide con %s", key, localPath)
+	}
+	return nil
+}
+
+// dirPublisher publica en un directorio local (o un volumen montado)
+type dirPublisher struct {
+	root string
+}
+
+// Upload copia el archivo a un temporal y lo renombra, para que el destino
+// nunca exponga un archivo a medio escribir
+func (p *dirPublisher) Upload(key, localPath string, opts s3.PutOptions) error {
+	destPath := filepath.Join(p.root, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("error creando directorio de destino: %w", err)
+	}
+
+	src, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("error abriendo %s: %w", localPath, err)
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(destPath), "."+filepath.Base(destPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creando archivo temporal: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error copiando %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error copiando %s: %w", key, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("error ajustando permisos de %s: %w", key, err)
+	}
+
+	if err := os.Rename(tmp.Name(), destPath); err != nil {
+		return fmt.Errorf("error publicando %s: %w", key, err)
+	}
+	return nil
+}
+
+// Verify compara tamaño y SHA-256 del archivo publicado con el local
+func (p *dirPublisher) Verify(key, localPath string) error {
+	size, sha, _, err := fileDigests(localPath)
+	if err != nil {
+		return err
+	}
+	publishedSize, publishedSHA, _, err := fileDigests(filepath.Join(p.root, filepath.FromSlash(key)))
+	if err != nil {
+		return err
+	}
+	if publishedSize != size || publishedSHA != sha {
+		return fmt.Errorf("el archivo publicado %s no coincide con %s", key, localPath)
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/s3"
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/s3/s3test"
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/pkg/updater"
+)
+
+// writeRelease genera un ZIP (contenido arbitrario) y su manifiesto
+func writeRelease(t *testing.T, version string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	payload := []byte("zip de la versión " + version)
+	zipPath := filepath.Join(dir, "MyApp.zip")
+	if err := os.WriteFile(zipPath, payload, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(payload)
+	manifest := fmt.Sprintf(`{"version": %q, "checksum": %q}`, version, hex.EncodeToString(sum[:]))
+	manifestPath := filepath.Join(dir, "darwin-arm64.json")
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(manifestPath+".sig", []byte("firma"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return zipPath, manifestPath
+}
+
+// addComponent agrega al manifiesto un componente cuyo ZIP está en
+// {directorio del manifiesto}/{componentURL} y retorna la ruta del ZIP
+func addComponent(t *testing.T, manifestPath, name, componentURL string) string {
+	t.Helper()
+
+	payload := []byte("zip del componente " + name)
+	zipPath := filepath.Join(filepath.Dir(manifestPath), filepath.FromSlash(componentURL))
+	if err := os.MkdirAll(filepath.Dir(zipPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(zipPath, payload, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest updater.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(payload)
+	manifest.Components = append(manifest.Components, updater.Component{
+		Name:        name,
+		URL:         componentURL,
+		InstallPath: "/usr/local/libexec/" + name,
+		Checksum:    hex.EncodeToString(sum[:]),
+		Size:        int64(len(payload)),
+	})
+	if data, err = json.Marshal(manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return zipPath
+}
+
+func TestPublishS3UploadsPayloadBeforeManifest(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	zipPath, manifestPath := writeRelease(t, "1.2.3")
+	addComponent(t, manifestPath, "helper", "releases/1.2.3/helper.zip")
+	err := runPublish([]string{
+		"--zip", zipPath,
+		"--manifest", manifestPath,
+		"--s3-bucket", "updates",
+		"--s3-endpoint", server.URL,
+		"--prefix", "myapp",
+	})
+	if err != nil {
+		t.Fatalf("runPublish: %v", err)
+	}
+
+	manifestData, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatureKey := "updates/myapp/" + updater.ManifestSignaturePath(manifestData)
+
+	want := []string{
+		"updates/myapp/releases/1.2.3/MyApp.zip",
+		"updates/myapp/MyApp.zip",
+		"updates/myapp/releases/1.2.3/helper.zip",
+		signatureKey,
+		"updates/myapp/darwin-arm64.json.sig",
+		"updates/myapp/darwin-arm64.json",
+	}
+	if got := server.Puts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("orden de subida = %v, want %v", got, want)
+	}
+
+	payload := server.Object("updates", "myapp/releases/1.2.3/MyApp.zip")
+	if got := payload.Header.Get("Cache-Control"); got != payloadCacheControl {
+		t.Errorf("Cache-Control del payload = %q", got)
+	}
+	component := server.Object("updates", "myapp/releases/1.2.3/helper.zip")
+	if got := component.Header.Get("Cache-Control"); got != payloadCacheControl {
+		t.Errorf("Cache-Control del componente = %q", got)
+	}
+	manifest := server.Object("updates", "myapp/darwin-arm64.json")
+	if got := manifest.Header.Get("Cache-Control"); got != "no-cache" {
+		t.Errorf("Cache-Control del manifiesto = %q", got)
+	}
+	if got := manifest.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type del manifiesto = %q", got)
+	}
+	signature := server.Object("updates", strings.TrimPrefix(signatureKey, "updates/"))
+	if got := signature.Header.Get("Cache-Control"); got != payloadCacheControl {
+		t.Errorf("Cache-Control de la firma direccionada por contenido = %q", got)
+	}
+	legacy := server.Object("updates", "myapp/MyApp.zip")
+	if got := legacy.Header.Get("Cache-Control"); got != "no-cache" {
+		t.Errorf("Cache-Control del payload legacy = %q", got)
+	}
+}
+
+func TestPublishRejectsMismatchedZip(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	zipPath, manifestPath := writeRelease(t, "1.2.3")
+	if err := os.WriteFile(zipPath, []byte("otro contenido"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runPublish([]string{
+		"--zip", zipPath,
+		"--manifest", manifestPath,
+		"--s3-bucket", "updates",
+		"--s3-endpoint", server.URL,
+	})
+	if err == nil {
+		t.Fatal("runPublish debería fallar con un ZIP que no coincide")
+	}
+	if puts := server.Puts(); len(puts) != 0 {
+		t.Errorf("no debería subirse nada, se subió %v", puts)
+	}
+}
+
+func TestPublishDirUsesVersionedPayloadPath(t *testing.T) {
+	dest := t.TempDir()
+	zipPath, manifestPath := writeRelease(t, "2.0.0")
+	addComponent(t, manifestPath, "helper", "releases/2.0.0/helper.zip")
+	addComponent(t, manifestPath, "cli", "tools/myapp-cli.zip")
+
+	if err := runPublish([]string{"--zip", zipPath, "--manifest", manifestPath, "--dest", dest}); err != nil {
+		t.Fatalf("runPublish: %v", err)
+	}
+
+	for _, name := range []string{"releases/2.0.0/MyApp.zip", "MyApp.zip", "releases/2.0.0/helper.zip", "tools/myapp-cli.zip", "darwin-arm64.json", "darwin-arm64.json.sig"} {
+		if _, err := os.Stat(filepath.Join(dest, name)); err != nil {
+			t.Errorf("falta %s: %v", name, err)
+		}
+	}
+}
+
+func TestPublishRejectsComponents(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(t *testing.T, manifestPath string)
+	}{
+		{
+			name: "ZIP alterado",
+			mutate: func(t *testing.T, manifestPath string) {
+				zipPath := addComponent(t, manifestPath, "helper", "releases/2.0.0/helper.zip")
+				if err := os.WriteFile(zipPath, []byte("otro contenido"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "ZIP ausente",
+			mutate: func(t *testing.T, manifestPath string) {
+				zipPath := addComponent(t, manifestPath, "helper", "releases/2.0.0/helper.zip")
+				if err := os.Remove(zipPath); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "url fuera del prefijo",
+			mutate: func(t *testing.T, manifestPath string) {
+				addComponent(t, manifestPath, "helper", "releases/../helper.zip")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := t.TempDir()
+			zipPath, manifestPath := writeRelease(t, "2.0.0")
+			tt.mutate(t, manifestPath)
+
+			if err := runPublish([]string{"--zip", zipPath, "--manifest", manifestPath, "--dest", dest}); err == nil {
+				t.Fatal("runPublish debería fallar")
+			}
+			if entries, _ := os.ReadDir(dest); len(entries) != 0 {
+				t.Errorf("no debería publicarse nada: %v", entries)
+			}
+		})
+	}
+}
+
+func TestPublishDirLegacyName(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{"nombre legacy propio", []string{"--legacy-name", "update.zip"}, "update.zip", false},
+		{"sin copia legacy", []string{"--no-legacy"}, "", false},
+		{"nombre con ruta", []string{"--legacy-name", "../update.zip"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := t.TempDir()
+			zipPath, manifestPath := writeRelease(t, "2.0.0")
+
+			args := append([]string{"--zip", zipPath, "--manifest", manifestPath, "--dest", dest}, tt.args...)
+			err := runPublish(args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runPublish: err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if _, err := os.Stat(filepath.Join(dest, "darwin-arm64.json")); !os.IsNotExist(err) {
+					t.Errorf("no debería publicarse el manifiesto: %v", err)
+				}
+				return
+			}
+
+			if _, err := os.Stat(filepath.Join(dest, "MyApp.zip")); !os.IsNotExist(err) {
+				t.Errorf("MyApp.zip no debería publicarse en la raíz: %v", err)
+			}
+			if tt.want != "" {
+				if _, err := os.Stat(filepath.Join(dest, tt.want)); err != nil {
+					t.Errorf("falta %s: %v", tt.want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestResolvePayloadKey(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"ruta relativa del manifiesto", "releases/1.2.3/myapp.zip", "myapp/releases/1.2.3/myapp.zip"},
+		{"url absoluta", "https://cdn.example.com/myapp.zip", "myapp/releases/1.2.3/MyApp.zip"},
+		{"sin url (legacy)", "", "myapp/releases/1.2.3/MyApp.zip"},
+		{"ruta que escapa del prefijo", "../otro/myapp.zip", "myapp/releases/1.2.3/MyApp.zip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &updater.Manifest{Version: "1.2.3", URL: tt.url}
+			got, err := resolvePayloadKey("myapp", manifest, "/tmp/dist/MyApp.zip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("resolvePayloadKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePayloadKeyRejectsInvalidVersion(t *testing.T) {
+	for _, version := range []string{"", "../../evil", "1.2.3/../../x", "1.0/..", "latest"} {
+		manifest := &updater.Manifest{Version: version}
+		if key, err := resolvePayloadKey("myapp", manifest, "/tmp/dist/MyApp.zip"); err == nil {
+			t.Errorf("resolvePayloadKey(%q) = %q, debería fallar", version, key)
+		}
+	}
+
+	for _, version := range []string{"1.2.3", "v1.2.3-beta.1", "2026.10.3", "1234"} {
+		manifest := &updater.Manifest{Version: version}
+		if _, err := resolvePayloadKey("myapp", manifest, "/tmp/dist/MyApp.zip"); err != nil {
+			t.Errorf("resolvePayloadKey(%q): %v", version, err)
+		}
+	}
+}
+
+func TestS3PublisherVerify(t *testing.T) {
+	server := s3test.NewServer()
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	client, err := s3.NewClientFromEnv(server.URL, "auto", "updates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &s3Publisher{client: client}
+
+	zipPath, _ := writeRelease(t, "1.2.3")
+	tests := []struct {
+		name    string
+		tamper  func(object *s3test.Object)
+		wantErr bool
+	}{
+		{"ETag de una sola parte", func(object *s3test.Object) {}, false},
+		// Multipart o cifrado KMS: el ETag no es el MD5 y se descarga el objeto
+		{"ETag que no es MD5", func(object *s3test.Object) {
+			object.Header.Set("ETag", `"0123456789abcdef0123456789abcdef-2"`)
+		}, false},
+		{"contenido alterado con el mismo tamaño", func(object *s3test.Object) {
+			object.Data = bytes.ToUpper(object.Data)
+			object.Header.Set("ETag", `"0123456789abcdef0123456789abcdef-2"`)
+		}, true},
+		{"tamaño distinto", func(object *s3test.Object) {
+			object.Data = append(object.Data, '!')
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := pub.Upload("MyApp.zip", zipPath, s3.PutOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			tt.tamper(server.Object("updates", "MyApp.zip"))
+
+			err := pub.Verify("MyApp.zip", zipPath)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify: err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
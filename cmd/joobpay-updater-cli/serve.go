@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/releaseserver"
+)
+
+// runServe sirve un directorio de releases por HTTP para ensayar el flujo de
+// actualización localmente (SourceURL: http://{addr}/)
+func runServe(args []string) error {
+	var faults releaseserver.Faults
+
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dir := fs.String("dir", ".", "Directorio de releases a servir (ej: el --output-dir de build)")
+	addr := fs.String("addr", "127.0.0.1:8080", "Dirección de escucha")
+	cacheControl := fs.String("cache-control", "no-cache", "Cache-Control de las respuestas (ej: max-age=300)")
+	fs.StringVar(&faults.Pattern, "fault-pattern", "*.zip", "Archivos afectados por las fallas (patrón sobre el nombre; vacío = todos)")
+	fs.DurationVar(&faults.Latency, "latency", 0, "Latencia agregada a cada respuesta (ej: 2s)")
+	fs.IntVar(&faults.FailFirst, "fail-first", 0, "Responder 500 a las primeras N peticiones")
+	fs.Float64Var(&faults.ErrorRate, "error-rate", 0, "Probabilidad (0 a 1) de responder 500")
+	fs.Int64Var(&faults.TruncateAfter, "truncate-after", 0, "Cortar la conexión tras enviar N bytes del cuerpo")
+	fs.BoolVar(&faults.Corrupt, "corrupt", false, "Alterar el cuerpo para provocar un checksum incorrecto")
+	fs.Parse(args)
+
+	if stat, err := os.Stat(*dir); err != nil || !stat.IsDir() {
+		return fmt.Errorf("el directorio de releases no existe: %s", *dir)
+	}
+	if err := releaseserver.ParsePattern(faults.Pattern); err != nil {
+		return err
+	}
+	if faults.ErrorRate < 0 || faults.ErrorRate > 1 {
+		return fmt.Errorf("--error-rate debe estar entre 0 y 1")
+	}
+
+	server := releaseserver.New(*dir, faults)
+	server.Logger = log.New(os.Stdout, "", log.LstdFlags)
+	server.CacheControl = *cacheControl
+
+	fmt.Printf("Sirviendo %s en http://%s/\n", *dir, *addr)
+	fmt.Printf("Fallas inyectadas: %s\n", faults)
+
+	return http.ListenAndServe(*addr, server)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runSign firma un manifiesto (o appcast) con Ed25519 y escribe la firma en
+// {manifiesto}.sig, que el updater valida si Config.ManifestPublicKey está definida
+func runSign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	manifestPath := fs.String("manifest", "", "Manifiesto o appcast a firmar (requerido)")
+	keyFile := fs.String("key-file", "", "Archivo con la clave privada Ed25519 en base64 (requerido)")
+	generateKey := fs.String("generate-key", "", "Generar una clave nueva en este archivo y mostrar la clave pública")
+	fs.Parse(args)
+
+	if *generateKey != "" {
+		return generateEdDSAKey(*generateKey)
+	}
+
+	if *manifestPath == "" || *keyFile == "" {
+		fs.Usage()
+		return fmt.Errorf("--manifest y --key-file son requeridos")
+	}
+
+	key, err := loadEdDSAKey(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	signature, err := signEdDSA(*manifestPath, key)
+	if err != nil {
+		return err
+	}
+
+	signaturePath := *manifestPath + ".sig"
+	if err := os.WriteFile(signaturePath, []byte(signature+"\n"), 0644); err != nil {
+		return fmt.Errorf("error escribiendo firma: %w", err)
+	}
+
+	fmt.Printf("Firma generada: %s\n", signaturePath)
+	fmt.Printf("Clave pública (Config.ManifestPublicKey): %s\n", publicKeyString(key))
+
+	return nil
+}
+
+// generateEdDSAKey genera una clave Ed25519 y guarda la semilla en base64
+func generateEdDSAKey(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("el archivo de clave ya existe: %s", path)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("error generando clave: %w", err)
+	}
+
+	seed := base64.StdEncoding.EncodeToString(key.Seed())
+	if err := os.WriteFile(path, []byte(seed+"\n"), 0600); err != nil {
+		return fmt.Errorf("error escribiendo clave: %w", err)
+	}
+
+	fmt.Printf("Clave privada guardada en: %s\n", path)
+	fmt.Printf("Clave pública: %s\n", publicKeyString(key))
+
+	return nil
+}
+
+// publicKeyString retorna la clave pública en base64
+func publicKeyString(key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// loadEdDSAKey lee una clave privada Ed25519 en base64, ya sea la semilla de
+// 32 bytes (formato de `generate_keys -x` de Sparkle) o la clave de 64 bytes
+func loadEdDSAKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo clave EdDSA: %w", err)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("clave EdDSA inválida: %w", err)
+	}
+
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	}
+
+	return nil, fmt.Errorf("clave EdDSA con tamaño inválido: %d bytes", len(raw))
+}
+
+// signEdDSA firma el archivo con la clave Ed25519 y retorna la firma en base64
+func signEdDSA(path string, key ed25519.PrivateKey) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error leyendo archivo a firmar: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)), nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/pkg/updater"
+)
+
+// runVerify valida un par ZIP/manifiesto con las mismas reglas que el cliente:
+// firma del manifiesto, tamaño, digest más fuerte, firma EdDSA del ZIP y
+// versión del Info.plist del bundle contenido
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	manifestPath := fs.String("manifest", "", "Manifiesto JSON (requerido)")
+	zipPath := fs.String("zip", "", "ZIP de la release (requerido)")
+	manifestPublicKey := fs.String("manifest-public-key", "", "Clave pública Ed25519 (base64) para validar {manifiesto}.sig (opcional)")
+	edPublicKey := fs.String("ed-public-key", "", "Clave pública Ed25519 (base64) para validar la firma EdDSA del ZIP (opcional)")
+	versionScheme := fs.String("version-scheme", "semver", "Esquema de versionado del cliente: semver, calver o build")
+	fs.Parse(args)
+
+	if *manifestPath == "" || *zipPath == "" {
+		fs.Usage()
+		return fmt.Errorf("--manifest y --zip son requeridos")
+	}
+
+	comparator, err := versionComparator(*versionScheme)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(*manifestPath)
+	if err != nil {
+		return fmt.Errorf("error leyendo manifiesto: %w", err)
+	}
+
+	// Paso 1: Firma del manifiesto
+	if *manifestPublicKey != "" {
+		signaturePath, err := verifyManifestSignature(*manifestPath, data, *manifestPublicKey)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Firma del manifiesto (%s): [✓]\n", signaturePath)
+	}
+
+	manifest, err := updater.ParseManifest(data)
+	if err != nil {
+		return err
+	}
+
+	// Paso 2: Integridad del ZIP
+	if err := manifest.VerifyFile(*zipPath, *edPublicKey); err != nil {
+		return err
+	}
+	fmt.Println("Integridad del ZIP: [✓]")
+
+	// Paso 3: Versión del bundle contenido en el ZIP
+	info, _, err := readZipBundle(*zipPath)
+	if err != nil {
+		return err
+	}
+	if !matchesVersion(comparator, manifest.Version, info.ShortVersion) && !matchesVersion(comparator, manifest.Version, info.Version) {
+		return fmt.Errorf("el manifiesto anuncia la versión %q pero el bundle es %q (%s)", manifest.Version, info.ShortVersion, info.Version)
+	}
+	fmt.Printf("Bundle: %s %s [✓]\n", info.Identifier, info.ShortVersion)
+
+	fmt.Println("Verificación completada: la release es válida")
+
+	return nil
+}
+
+// verifyManifestSignature busca la firma del manifiesto en el mismo orden que
+// el cliente: primero la direccionada por contenido (signatures/{sha256}.sig,
+// relativa al manifiesto) y después {manifiesto}.sig. Retorna la ruta de la
+// firma válida
+func verifyManifestSignature(manifestPath string, data []byte, publicKey string) (string, error) {
+	candidates := []string{
+		filepath.Join(filepath.Dir(manifestPath), filepath.FromSlash(updater.ManifestSignaturePath(data))),
+		manifestPath + ".sig",
+	}
+
+	var lastErr error
+	for _, signaturePath := range candidates {
+		signature, err := os.ReadFile(signaturePath)
+		if err != nil {
+			lastErr = fmt.Errorf("%w: %v", updater.ErrManifestSignature, err)
+			continue
+		}
+		if err := updater.VerifyManifestSignature(data, signature, publicKey); err != nil {
+			lastErr = err
+			continue
+		}
+		return signaturePath, nil
+	}
+	return "", lastErr
+}
+
+// versionComparator retorna el comparador del esquema de versionado indicado,
+// que debe ser el Config.VersionComparator del cliente
+func versionComparator(scheme string) (updater.VersionComparator, error) {
+	switch scheme {
+	case "semver":
+		return updater.SemverComparator, nil
+	case "calver":
+		return updater.CalverComparator, nil
+	case "build":
+		return updater.BuildNumberComparator, nil
+	}
+	return nil, fmt.Errorf("esquema de versionado inválido: %q (semver, calver o build)", scheme)
+}
+
+// matchesVersion compara la versión del manifiesto con la del bundle como lo
+// hace el cliente con su comparador
+func matchesVersion(comparator updater.VersionComparator, manifestVersion, bundleVersion string) bool {
+	if bundleVersion == "" {
+		return false
+	}
+	cmp, err := comparator.Compare(bundleVersion, manifestVersion)
+	return err == nil && cmp == 0
+}
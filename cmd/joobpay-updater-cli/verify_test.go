@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/utils"
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/pkg/updater"
+)
+
+// signedRelease es una release firmada como la generan build y sign
+type signedRelease struct {
+	dir          string
+	keyPath      string
+	publicKey    string
+	edSignature  string
+	zipPath      string
+	manifestPath string
+}
+
+// newSignedRelease genera un bundle, su ZIP, el manifiesto con la firma EdDSA
+// del ZIP y la firma del manifiesto
+func newSignedRelease(t *testing.T, version string) *signedRelease {
+	t.Helper()
+	r := &signedRelease{dir: t.TempDir()}
+	r.keyPath = filepath.Join(r.dir, "ed25519.key")
+	if err := generateEdDSAKey(r.keyPath); err != nil {
+		t.Fatal(err)
+	}
+	key, err := loadEdDSAKey(r.keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.publicKey = publicKeyString(key)
+
+	appPath := filepath.Join(r.dir, "MyApp.app")
+	writeFile(t, filepath.Join(appPath, "Contents", "Info.plist"), fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>CFBundleIdentifier</key>
+	<string>com.joobpay.cli-test</string>
+	<key>CFBundleShortVersionString</key>
+	<string>%s</string>
+	<key>CFBundleVersion</key>
+	<string>%s</string>
+	<key>CFBundleExecutable</key>
+	<string>MyApp</string>
+</dict>
+</plist>
+`, version, version))
+	writeFile(t, filepath.Join(appPath, "Contents", "MacOS", "MyApp"), "#!/bin/sh\n")
+
+	r.zipPath = filepath.Join(r.dir, "MyApp.zip")
+	if err := utils.ZipDirectory(appPath, r.zipPath); err != nil {
+		t.Fatal(err)
+	}
+	if r.edSignature, err = signEdDSA(r.zipPath, key); err != nil {
+		t.Fatal(err)
+	}
+	r.manifestPath = filepath.Join(r.dir, "darwin-arm64.json")
+	r.writeManifest(t, version, r.edSignature)
+	return r
+}
+
+// writeManifest genera el manifiesto con los digests y el tamaño del ZIP
+// actual y lo firma con sign
+func (r *signedRelease) writeManifest(t *testing.T, version, edSignature string) {
+	t.Helper()
+	digests, err := utils.CalculateDigests(r.zipPath, utils.HashSHA256, utils.HashSHA512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(r.zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(Manifest{
+		Version:     version,
+		Checksum:    digests[utils.HashSHA256],
+		Digests:     digests,
+		Size:        info.Size(),
+		EdSignature: edSignature,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, r.manifestPath, string(data))
+
+	if err := runSign([]string{"--manifest", r.manifestPath, "--key-file", r.keyPath}); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+}
+
+// verify ejecuta el subcomando verify con las claves indicadas
+func (r *signedRelease) verify(manifestKey, edKey string) error {
+	return runVerify([]string{
+		"--manifest", r.manifestPath,
+		"--zip", r.zipPath,
+		"--manifest-public-key", manifestKey,
+		"--ed-public-key", edKey,
+	})
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	r := newSignedRelease(t, "1.1.0")
+
+	if err := r.verify(r.publicKey, r.publicKey); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	// Sin claves solo se validan digests, tamaño y versión
+	if err := runVerify([]string{"--manifest", r.manifestPath, "--zip", r.zipPath}); err != nil {
+		t.Fatalf("verify sin claves: %v", err)
+	}
+}
+
+func TestVerifyContentAddressedSignature(t *testing.T) {
+	r := newSignedRelease(t, "1.1.0")
+	data, err := os.ReadFile(r.manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := os.ReadFile(r.manifestPath + ".sig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contentAddressed := filepath.Join(r.dir, filepath.FromSlash(updater.ManifestSignaturePath(data)))
+
+	// Solo la firma direccionada por contenido, como la publica publish
+	writeFile(t, contentAddressed, string(signature))
+	if err := os.Remove(r.manifestPath + ".sig"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.verify(r.publicKey, r.publicKey); err != nil {
+		t.Fatalf("verify con signatures/{sha256}.sig: %v", err)
+	}
+
+	// Como el cliente, una firma direccionada inválida cae en {manifiesto}.sig
+	writeFile(t, contentAddressed, "inválida")
+	writeFile(t, r.manifestPath+".sig", string(signature))
+	if err := r.verify(r.publicKey, r.publicKey); err != nil {
+		t.Fatalf("verify con {manifiesto}.sig: %v", err)
+	}
+}
+
+func TestVerifyVersionScheme(t *testing.T) {
+	// "2026.10.03" no es semver, pero en calver es la versión del bundle
+	r := newSignedRelease(t, "2026.10.3")
+	r.writeManifest(t, "2026.10.03", r.edSignature)
+
+	verify := func(scheme string) error {
+		return runVerify([]string{"--manifest", r.manifestPath, "--zip", r.zipPath, "--version-scheme", scheme})
+	}
+	if err := verify("calver"); err != nil {
+		t.Errorf("verify con calver: %v", err)
+	}
+	if err := verify("semver"); err == nil || !strings.Contains(err.Error(), "pero el bundle es") {
+		t.Errorf("verify con semver = %v, want versión distinta", err)
+	}
+	if err := verify("romano"); err == nil {
+		t.Error("verify debería rechazar un esquema desconocido")
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	otherKeyPath := filepath.Join(t.TempDir(), "other.key")
+	if err := generateEdDSAKey(otherKeyPath); err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := loadEdDSAKey(otherKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublicKey := publicKeyString(otherKey)
+
+	tests := []struct {
+		name   string
+		mutate func(t *testing.T, r *signedRelease)
+		// Claves con las que se verifica: la de la release si están vacías
+		manifestKey string
+		edKey       string
+		wantErr     string
+	}{
+		{
+			name: "ZIP alterado",
+			mutate: func(t *testing.T, r *signedRelease) {
+				flipByte(t, r.zipPath)
+			},
+			wantErr: "checksum mismatch",
+		},
+		{
+			// Digests y tamaño coinciden: solo la firma EdDSA lo detecta
+			name: "ZIP alterado con manifiesto regenerado",
+			mutate: func(t *testing.T, r *signedRelease) {
+				flipByte(t, r.zipPath)
+				r.writeManifest(t, "1.1.0", r.edSignature)
+			},
+			wantErr: "firma EdDSA inválida",
+		},
+		{
+			name: "manifiesto alterado después de firmarlo",
+			mutate: func(t *testing.T, r *signedRelease) {
+				data, err := os.ReadFile(r.manifestPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeFile(t, r.manifestPath, strings.Replace(string(data), `"1.1.0"`, `"1.1.1"`, 1))
+			},
+			wantErr: "firma del manifiesto inválida",
+		},
+		{
+			name: "firma del manifiesto ausente",
+			mutate: func(t *testing.T, r *signedRelease) {
+				if err := os.Remove(r.manifestPath + ".sig"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: "firma del manifiesto inválida",
+		},
+		{
+			name:        "otra clave para el manifiesto",
+			manifestKey: otherPublicKey,
+			wantErr:     "firma del manifiesto inválida",
+		},
+		{
+			name:    "otra clave para el ZIP",
+			edKey:   otherPublicKey,
+			wantErr: "firma EdDSA inválida",
+		},
+		{
+			name: "versión distinta a la del bundle",
+			mutate: func(t *testing.T, r *signedRelease) {
+				r.writeManifest(t, "1.2.0", r.edSignature)
+			},
+			wantErr: "pero el bundle es",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newSignedRelease(t, "1.1.0")
+			if tt.mutate != nil {
+				tt.mutate(t, r)
+			}
+			manifestKey, edKey := tt.manifestKey, tt.edKey
+			if manifestKey == "" {
+				manifestKey = r.publicKey
+			}
+			if edKey == "" {
+				edKey = r.publicKey
+			}
+
+			err := r.verify(manifestKey, edKey)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("verify = %v, want error con %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	r := newSignedRelease(t, "1.1.0")
+
+	output := captureStdout(t, func() error {
+		return runInspect([]string{"--zip", r.zipPath, "--manifest", r.manifestPath})
+	})
+	for _, want := range []string{
+		"CFBundleIdentifier: com.joobpay.cli-test",
+		"CFBundleShortVersionString: 1.1.0",
+		"Firma: presente (.sig)",
+		"Versión: 1.1.0",
+		"Firma EdDSA del ZIP: true",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("inspect no muestra %q:\n%s", want, output)
+		}
+	}
+
+	if err := runInspect([]string{"--zip", r.manifestPath}); err == nil {
+		t.Error("inspect debería fallar con un archivo que no es un ZIP")
+	}
+	if err := runInspect(nil); err == nil {
+		t.Error("inspect debería fallar sin --app-path, --zip ni --manifest")
+	}
+}
+
+// flipByte altera un byte en el medio del archivo
+func flipByte(t *testing.T, path string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 1
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// captureStdout ejecuta fn y retorna lo que escribió en stdout
+func captureStdout(t *testing.T, fn func() error) string {
+	t.Helper()
+	read, write, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = write
+
+	output := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, read)
+		output <- buf.String()
+	}()
+
+	err = fn()
+	os.Stdout = stdout
+	write.Close()
+	if err != nil {
+		t.Fatalf("inspect: %v", err)
+	}
+	return <-output
+}
@@ -2,4 +2,11 @@ module github.com/gerrandonea-joobpay/joobpay-go-updater
 
 go 1.21
 
-require golang.org/x/mod v0.14.0
+require (
+	filippo.io/edwards25519 v1.1.0
+	golang.org/x/mod v0.14.0
+	golang.org/x/sys v0.30.0
+	lukechampine.com/blake3 v1.2.1
+)
+
+require github.com/klauspost/cpuid/v2 v2.0.12 // indirect
@@ -0,0 +1,215 @@
+// Package appcast lee y genera feeds RSS compatibles con Sparkle (appcast.xml)
+package appcast
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// SparkleNamespace es el namespace XML de las extensiones de Sparkle
+const SparkleNamespace = "http://www.andymatuschak.org/xml-namespaces/sparkle"
+
+// Feed es un appcast con sus releases
+type Feed struct {
+	Title       string
+	Link        string
+	Description string
+	Items       []Item
+}
+
+// Item es una release publicada en el appcast
+type Item struct {
+	Title   string
+	PubDate string
+
+	// ReleaseNotesLink es la URL de las notas de la release (sparkle:releaseNotesLink)
+	ReleaseNotesLink string
+
+	// Version es sparkle:version (CFBundleVersion)
+	Version string
+
+	// ShortVersion es sparkle:shortVersionString (CFBundleShortVersionString)
+	ShortVersion string
+
+	// MinimumSystemVersion es sparkle:minimumSystemVersion
+	MinimumSystemVersion string
+
+	// Channel es sparkle:channel (vacío para el canal por defecto)
+	Channel string
+
+	// Critical indica sparkle:criticalUpdate
+	Critical bool
+
+	// URL, Length, Type y EdSignature corresponden al enclosure
+	URL         string
+	Length      int64
+	Type        string
+	EdSignature string
+}
+
+// Estructuras de decodificación: el parser resuelve el prefijo sparkle: a su namespace
+type rssIn struct {
+	Channel struct {
+		Title       string   `xml:"title"`
+		Link        string   `xml:"link"`
+		Description string   `xml:"description"`
+		Items       []itemIn `xml:"item"`
+	} `xml:"channel"`
+}
+
+type itemIn struct {
+	Title                string       `xml:"title"`
+	PubDate              string       `xml:"pubDate"`
+	ReleaseNotesLink     string       `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle releaseNotesLink"`
+	Version              string       `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle version"`
+	ShortVersion         string       `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle shortVersionString"`
+	MinimumSystemVersion string       `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle minimumSystemVersion"`
+	Channel              string       `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle channel"`
+	CriticalUpdate       *struct{}    `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle criticalUpdate"`
+	Enclosure            *enclosureIn `xml:"enclosure"`
+}
+
+type enclosureIn struct {
+	URL          string `xml:"url,attr"`
+	Length       int64  `xml:"length,attr"`
+	Type         string `xml:"type,attr"`
+	Version      string `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle version,attr"`
+	ShortVersion string `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle shortVersionString,attr"`
+	EdSignature  string `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle edSignature,attr"`
+}
+
+// Parse decodifica un appcast. Acepta tanto el formato actual (versión en
+// elementos sparkle:*) como el antiguo (versión en atributos del enclosure)
+func Parse(data []byte) (*Feed, error) {
+	var rss rssIn
+	if err := xml.Unmarshal(data, &rss); err != nil {
+		return nil, fmt.Errorf("error parseando appcast: %w", err)
+	}
+
+	feed := &Feed{
+		Title:       rss.Channel.Title,
+		Link:        rss.Channel.Link,
+		Description: rss.Channel.Description,
+	}
+
+	for _, in := range rss.Channel.Items {
+		if in.Enclosure == nil || in.Enclosure.URL == "" {
+			// Items sin enclosure (por ejemplo, solo notas) no son instalables
+			continue
+		}
+
+		item := Item{
+			Title:                strings.TrimSpace(in.Title),
+			PubDate:              strings.TrimSpace(in.PubDate),
+			ReleaseNotesLink:     strings.TrimSpace(in.ReleaseNotesLink),
+			Version:              firstNonEmpty(in.Version, in.Enclosure.Version),
+			ShortVersion:         firstNonEmpty(in.ShortVersion, in.Enclosure.ShortVersion),
+			MinimumSystemVersion: strings.TrimSpace(in.MinimumSystemVersion),
+			Channel:              strings.TrimSpace(in.Channel),
+			Critical:             in.CriticalUpdate != nil,
+			URL:                  strings.TrimSpace(in.Enclosure.URL),
+			Length:               in.Enclosure.Length,
+			Type:                 in.Enclosure.Type,
+			EdSignature:          strings.TrimSpace(in.Enclosure.EdSignature),
+		}
+		if item.Version == "" {
+			return nil, fmt.Errorf("item del appcast sin sparkle:version: %s", item.URL)
+		}
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	return feed, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Estructuras de codificación: Sparkle busca los elementos por su nombre
+// prefijado (sparkle:version), por lo que se escriben literalmente
+type rssOut struct {
+	XMLName      xml.Name   `xml:"rss"`
+	Version      string     `xml:"version,attr"`
+	XMLNSSparkle string     `xml:"xmlns:sparkle,attr"`
+	Channel      channelOut `xml:"channel"`
+}
+
+type channelOut struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link,omitempty"`
+	Description string    `xml:"description,omitempty"`
+	Items       []itemOut `xml:"item"`
+}
+
+type itemOut struct {
+	Title                string       `xml:"title"`
+	PubDate              string       `xml:"pubDate,omitempty"`
+	ReleaseNotesLink     string       `xml:"sparkle:releaseNotesLink,omitempty"`
+	Version              string       `xml:"sparkle:version"`
+	ShortVersion         string       `xml:"sparkle:shortVersionString,omitempty"`
+	MinimumSystemVersion string       `xml:"sparkle:minimumSystemVersion,omitempty"`
+	Channel              string       `xml:"sparkle:channel,omitempty"`
+	CriticalUpdate       *struct{}    `xml:"sparkle:criticalUpdate,omitempty"`
+	Enclosure            enclosureOut `xml:"enclosure"`
+}
+
+type enclosureOut struct {
+	URL         string `xml:"url,attr"`
+	Length      int64  `xml:"length,attr"`
+	Type        string `xml:"type,attr"`
+	EdSignature string `xml:"sparkle:edSignature,attr,omitempty"`
+}
+
+// Marshal codifica el appcast como XML
+func (f *Feed) Marshal() ([]byte, error) {
+	out := rssOut{
+		Version:      "2.0",
+		XMLNSSparkle: SparkleNamespace,
+		Channel: channelOut{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Description,
+		},
+	}
+
+	for _, item := range f.Items {
+		itemType := item.Type
+		if itemType == "" {
+			itemType = "application/octet-stream"
+		}
+
+		entry := itemOut{
+			Title:                item.Title,
+			PubDate:              item.PubDate,
+			ReleaseNotesLink:     item.ReleaseNotesLink,
+			Version:              item.Version,
+			ShortVersion:         item.ShortVersion,
+			MinimumSystemVersion: item.MinimumSystemVersion,
+			Channel:              item.Channel,
+			Enclosure: enclosureOut{
+				URL:         item.URL,
+				Length:      item.Length,
+				Type:        itemType,
+				EdSignature: item.EdSignature,
+			},
+		}
+		if item.Critical {
+			entry.CriticalUpdate = &struct{}{}
+		}
+		out.Channel.Items = append(out.Channel.Items, entry)
+	}
+
+	data, err := xml.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error generando appcast: %w", err)
+	}
+
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
@@ -0,0 +1,146 @@
+package appcast
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testFeed = `<?xml version="1.0" encoding="utf-8"?>
+<rss version="2.0" xmlns:sparkle="http://www.andymatuschak.org/xml-namespaces/sparkle">
+  <channel>
+    <title>MyApp</title>
+    <link>https://example.com/appcast.xml</link>
+    <item>
+      <title>MyApp 1.2.0</title>
+      <pubDate>Sat, 17 Oct 2026 10:00:00 +0000</pubDate>
+      <sparkle:version>120</sparkle:version>
+      <sparkle:shortVersionString>1.2.0</sparkle:shortVersionString>
+      <sparkle:minimumSystemVersion>13.0</sparkle:minimumSystemVersion>
+      <sparkle:channel>beta</sparkle:channel>
+      <sparkle:criticalUpdate></sparkle:criticalUpdate>
+      <enclosure url="releases/1.2.0/MyApp.zip" length="1024" type="application/octet-stream" sparkle:edSignature="c2ln"/>
+    </item>
+    <item>
+      <title>MyApp 1.1.0 (formato antiguo)</title>
+      <enclosure url="https://example.com/MyApp-1.1.0.zip" length="512" type="application/octet-stream"
+        sparkle:version="110" sparkle:shortVersionString="1.1.0"/>
+    </item>
+    <item>
+      <title>Solo notas</title>
+      <sparkle:version>100</sparkle:version>
+    </item>
+  </channel>
+</rss>
+`
+
+func TestParse(t *testing.T) {
+	feed, err := Parse([]byte(testFeed))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if feed.Title != "MyApp" || feed.Link != "https://example.com/appcast.xml" {
+		t.Errorf("canal = %q, %q", feed.Title, feed.Link)
+	}
+
+	want := []Item{
+		{
+			Title:                "MyApp 1.2.0",
+			PubDate:              "Sat, 17 Oct 2026 10:00:00 +0000",
+			Version:              "120",
+			ShortVersion:         "1.2.0",
+			MinimumSystemVersion: "13.0",
+			Channel:              "beta",
+			Critical:             true,
+			URL:                  "releases/1.2.0/MyApp.zip",
+			Length:               1024,
+			Type:                 "application/octet-stream",
+			EdSignature:          "c2ln",
+		},
+		{
+			Title:        "MyApp 1.1.0 (formato antiguo)",
+			Version:      "110",
+			ShortVersion: "1.1.0",
+			URL:          "https://example.com/MyApp-1.1.0.zip",
+			Length:       512,
+			Type:         "application/octet-stream",
+		},
+	}
+	if !reflect.DeepEqual(feed.Items, want) {
+		t.Errorf("items =\n%+v\nwant\n%+v", feed.Items, want)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"XML inválido", "<rss><channel>"},
+		{"item sin versión", `<rss><channel><item><enclosure url="MyApp.zip" length="1"/></item></channel></rss>`},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.data)); err == nil {
+			t.Errorf("%s: Parse debería fallar", tt.name)
+		}
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	feed := &Feed{
+		Title: "MyApp",
+		Link:  "https://example.com/appcast.xml",
+		Items: []Item{
+			{
+				Title:                "MyApp 1.2.0",
+				PubDate:              "Sat, 17 Oct 2026 10:00:00 +0000",
+				ReleaseNotesLink:     "https://example.com/notes/1.2.0.html",
+				Version:              "120",
+				ShortVersion:         "1.2.0",
+				MinimumSystemVersion: "13.0",
+				Channel:              "beta",
+				Critical:             true,
+				URL:                  "https://example.com/releases/1.2.0/MyApp.zip",
+				Length:               1024,
+				Type:                 "application/octet-stream",
+				EdSignature:          "c2ln",
+			},
+			{
+				Title:   "MyApp 1.1.0",
+				Version: "110",
+				URL:     "releases/1.1.0/MyApp.zip",
+				Length:  512,
+			},
+		},
+	}
+
+	data, err := feed.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	// Sparkle busca los elementos por su nombre prefijado
+	for _, want := range []string{
+		`xmlns:sparkle="` + SparkleNamespace + `"`,
+		"<sparkle:version>120</sparkle:version>",
+		`sparkle:edSignature="c2ln"`,
+		"<sparkle:criticalUpdate></sparkle:criticalUpdate>",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("el appcast no contiene %s:\n%s", want, data)
+		}
+	}
+
+	parsed, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	// Marshal completa el tipo del enclosure
+	feed.Items[1].Type = "application/octet-stream"
+	if !reflect.DeepEqual(parsed, feed) {
+		t.Errorf("round-trip =\n%+v\nwant\n%+v", parsed, feed)
+	}
+}
@@ -0,0 +1,95 @@
+// Package bundle inspecciona bundles .app de macOS a partir de su Info.plist
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Info contiene los campos de Info.plist relevantes para el updater
+type Info struct {
+	// Identifier es CFBundleIdentifier (ej: "com.joobpay.myapp")
+	Identifier string
+
+	// ShortVersion es CFBundleShortVersionString (versión visible, ej: "1.2.3")
+	ShortVersion string
+
+	// Version es CFBundleVersion (número de build)
+	Version string
+
+	// Executable es CFBundleExecutable (nombre del binario en Contents/MacOS)
+	Executable string
+
+	// MinimumSystemVersion es LSMinimumSystemVersion
+	MinimumSystemVersion string
+}
+
+// InfoPlistPath retorna la ruta del Info.plist de un bundle .app
+func InfoPlistPath(appPath string) string {
+	return filepath.Join(appPath, "Contents", "Info.plist")
+}
+
+// ReadInfo lee y parsea el Info.plist (XML o binario) de un bundle .app
+func ReadInfo(appPath string) (*Info, error) {
+	data, err := os.ReadFile(InfoPlistPath(appPath))
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo Info.plist: %w", err)
+	}
+
+	return ParseInfo(data)
+}
+
+// ParseInfo parsea el contenido de un Info.plist (XML o binario)
+func ParseInfo(data []byte) (*Info, error) {
+	value, err := DecodePlist(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parseando Info.plist: %w", err)
+	}
+
+	dict, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Info.plist no es un diccionario")
+	}
+
+	info := &Info{
+		Identifier:           plistString(dict, "CFBundleIdentifier"),
+		ShortVersion:         plistString(dict, "CFBundleShortVersionString"),
+		Version:              plistString(dict, "CFBundleVersion"),
+		Executable:           plistString(dict, "CFBundleExecutable"),
+		MinimumSystemVersion: plistString(dict, "LSMinimumSystemVersion"),
+	}
+
+	if info.Identifier == "" {
+		return nil, fmt.Errorf("Info.plist no define CFBundleIdentifier")
+	}
+
+	return info, nil
+}
+
+// plistString retorna el valor string de una clave (vacío si no existe o no es string)
+func plistString(dict map[string]interface{}, key string) string {
+	value, _ := dict[key].(string)
+	return strings.TrimSpace(value)
+}
+
+// ExecutablePath retorna la ruta del ejecutable principal del bundle. Usa
+// CFBundleExecutable y, si el Info.plist no lo define, el nombre del bundle
+func ExecutablePath(appPath string) (string, error) {
+	name := strings.TrimSuffix(filepath.Base(appPath), ".app")
+	if info, err := ReadInfo(appPath); err == nil && info.Executable != "" {
+		name = info.Executable
+	}
+
+	exePath := filepath.Join(appPath, "Contents", "MacOS", name)
+	stat, err := os.Stat(exePath)
+	if err != nil {
+		return "", fmt.Errorf("no se encontró el ejecutable principal de %s: %w", appPath, err)
+	}
+	if !stat.Mode().IsRegular() {
+		return "", fmt.Errorf("el ejecutable principal no es un archivo: %s", exePath)
+	}
+
+	return exePath, nil
+}
@@ -0,0 +1,71 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadInfo(t *testing.T) {
+	want := Info{
+		Identifier:           "com.joobpay.my",
+		ShortVersion:         "1.2.3",
+		Version:              "42",
+		Executable:           "My",
+		MinimumSystemVersion: "12.0",
+	}
+
+	for _, fixture := range []string{"Info.xml.plist", "Info.binary.plist"} {
+		t.Run(fixture, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			appPath := filepath.Join(t.TempDir(), "My.app")
+			if err := os.MkdirAll(filepath.Join(appPath, "Contents"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(InfoPlistPath(appPath), data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := ReadInfo(appPath)
+			if err != nil {
+				t.Fatalf("ReadInfo: %v", err)
+			}
+			if *info != want {
+				t.Fatalf("info = %+v, esperado %+v", *info, want)
+			}
+		})
+	}
+}
+
+func TestDecodeBinaryPlistTypes(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "Info.binary.plist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := DecodePlist(data)
+	if err != nil {
+		t.Fatalf("DecodePlist: %v", err)
+	}
+	dict := value.(map[string]interface{})
+
+	if dict["CFBundleDisplayName"] != "Mí App" {
+		t.Errorf("CFBundleDisplayName = %v", dict["CFBundleDisplayName"])
+	}
+	if dict["LSUIElement"] != true {
+		t.Errorf("LSUIElement = %v", dict["LSUIElement"])
+	}
+	if dict["Count"] != int64(300) {
+		t.Errorf("Count = %v", dict["Count"])
+	}
+	if dict["Ratio"] != 1.5 {
+		t.Errorf("Ratio = %v", dict["Ratio"])
+	}
+	if items, ok := dict["Items"].([]interface{}); !ok || len(items) != 2 || items[1] != "b" {
+		t.Errorf("Items = %v", dict["Items"])
+	}
+}
@@ -0,0 +1,367 @@
+package bundle
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// DecodePlist decodifica un property list en formato XML o binario (bplist00).
+// Los valores se devuelven como string, int64, float64, bool, []byte,
+// time.Time, []interface{} o map[string]interface{}
+func DecodePlist(data []byte) (interface{}, error) {
+	if bytes.HasPrefix(data, []byte("bplist00")) {
+		return decodeBinaryPlist(data)
+	}
+	return decodeXMLPlist(data)
+}
+
+// plistEpoch es la referencia de fechas de Core Foundation (2001-01-01)
+var plistEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// decodeXMLPlist decodifica un plist XML
+func decodeXMLPlist(data []byte) (interface{}, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// Los Info.plist declaran un DOCTYPE externo que no debe resolverse
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("plist XML vacío")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error leyendo plist XML: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local == "plist" {
+			continue
+		}
+		return decodeXMLValue(decoder, start)
+	}
+}
+
+// decodeXMLValue decodifica el valor que empieza en start
+func decodeXMLValue(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	switch start.Name.Local {
+	case "dict":
+		dict := map[string]interface{}{}
+		var key string
+		hasKey := false
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, fmt.Errorf("error leyendo dict: %w", err)
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				if t.Name.Local == "key" {
+					if key, err = xmlText(decoder); err != nil {
+						return nil, err
+					}
+					hasKey = true
+					continue
+				}
+				if !hasKey {
+					return nil, fmt.Errorf("valor sin clave en dict")
+				}
+				value, err := decodeXMLValue(decoder, t)
+				if err != nil {
+					return nil, err
+				}
+				dict[key] = value
+				hasKey = false
+			case xml.EndElement:
+				return dict, nil
+			}
+		}
+	case "array":
+		array := []interface{}{}
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, fmt.Errorf("error leyendo array: %w", err)
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				value, err := decodeXMLValue(decoder, t)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, value)
+			case xml.EndElement:
+				return array, nil
+			}
+		}
+	case "true", "false":
+		if err := decoder.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+	}
+
+	text, err := xmlText(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	switch start.Name.Local {
+	case "string":
+		return text, nil
+	case "integer":
+		return strconv.ParseInt(strings.TrimSpace(text), 0, 64)
+	case "real":
+		return strconv.ParseFloat(strings.TrimSpace(text), 64)
+	case "date":
+		return time.Parse(time.RFC3339, strings.TrimSpace(text))
+	case "data":
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	}
+
+	return nil, fmt.Errorf("tipo de plist XML no soportado: %s", start.Name.Local)
+}
+
+// xmlText lee el texto hasta el cierre del elemento actual
+func xmlText(decoder *xml.Decoder) (string, error) {
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("error leyendo plist XML: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			return text.String(), nil
+		case xml.StartElement:
+			return "", fmt.Errorf("elemento inesperado en plist XML: %s", t.Name.Local)
+		}
+	}
+}
+
+// binaryPlist mantiene el estado de decodificación de un bplist00
+type binaryPlist struct {
+	data       []byte
+	offsets    []uint64
+	refSize    int
+	inProgress map[uint64]bool
+}
+
+// decodeBinaryPlist decodifica un plist binario (bplist00)
+func decodeBinaryPlist(data []byte) (interface{}, error) {
+	if len(data) < 8+32 {
+		return nil, fmt.Errorf("plist binario truncado")
+	}
+
+	trailer := data[len(data)-32:]
+	offsetSize := int(trailer[6])
+	refSize := int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:16])
+	topObject := binary.BigEndian.Uint64(trailer[16:24])
+	tableOffset := binary.BigEndian.Uint64(trailer[24:32])
+
+	if offsetSize < 1 || offsetSize > 8 || refSize < 1 || refSize > 8 {
+		return nil, fmt.Errorf("trailer de plist binario inválido")
+	}
+	if numObjects == 0 || topObject >= numObjects ||
+		tableOffset+numObjects*uint64(offsetSize) > uint64(len(data)-32) {
+		return nil, fmt.Errorf("tabla de offsets de plist binario inválida")
+	}
+
+	p := &binaryPlist{
+		data:       data,
+		offsets:    make([]uint64, numObjects),
+		refSize:    refSize,
+		inProgress: map[uint64]bool{},
+	}
+	for i := range p.offsets {
+		start := tableOffset + uint64(i*offsetSize)
+		p.offsets[i] = readUint(data[start : start+uint64(offsetSize)])
+	}
+
+	return p.object(topObject)
+}
+
+// readUint lee un entero big endian de 1 a 8 bytes
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// object decodifica el objeto con índice ref
+func (p *binaryPlist) object(ref uint64) (interface{}, error) {
+	if ref >= uint64(len(p.offsets)) {
+		return nil, fmt.Errorf("referencia de objeto fuera de rango")
+	}
+	// Evitar ciclos en plists malformados
+	if p.inProgress[ref] {
+		return nil, fmt.Errorf("referencia circular en plist binario")
+	}
+	p.inProgress[ref] = true
+	defer delete(p.inProgress, ref)
+
+	offset := p.offsets[ref]
+	if offset >= uint64(len(p.data)-32) {
+		return nil, fmt.Errorf("offset de objeto fuera de rango")
+	}
+
+	marker := p.data[offset]
+	kind, info := marker>>4, marker&0x0f
+	pos := offset + 1
+
+	switch kind {
+	case 0x0:
+		switch info {
+		case 0x8:
+			return false, nil
+		case 0x9:
+			return true, nil
+		}
+		return nil, nil
+	case 0x1:
+		b, err := p.bytes(pos, 1<<info)
+		if err != nil {
+			return nil, err
+		}
+		return int64(readUint(b)), nil
+	case 0x2:
+		b, err := p.bytes(pos, 1<<info)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) == 4 {
+			return float64(math.Float32frombits(uint32(readUint(b)))), nil
+		}
+		return math.Float64frombits(readUint(b)), nil
+	case 0x3:
+		b, err := p.bytes(pos, 8)
+		if err != nil {
+			return nil, err
+		}
+		seconds := math.Float64frombits(readUint(b))
+		return plistEpoch.Add(time.Duration(seconds * float64(time.Second))), nil
+	}
+
+	length, pos, err := p.length(info, pos)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case 0x4:
+		return p.bytes(pos, length)
+	case 0x5:
+		b, err := p.bytes(pos, length)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case 0x6:
+		b, err := p.bytes(pos, length*2)
+		if err != nil {
+			return nil, err
+		}
+		units := make([]uint16, length)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(b[i*2:])
+		}
+		return string(utf16.Decode(units)), nil
+	case 0xA:
+		refs, err := p.refs(pos, length)
+		if err != nil {
+			return nil, err
+		}
+		array := make([]interface{}, 0, length)
+		for _, r := range refs {
+			value, err := p.object(r)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		return array, nil
+	case 0xD:
+		refs, err := p.refs(pos, length*2)
+		if err != nil {
+			return nil, err
+		}
+		dict := make(map[string]interface{}, length)
+		for i := uint64(0); i < length; i++ {
+			key, err := p.object(refs[i])
+			if err != nil {
+				return nil, err
+			}
+			keyString, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("clave de dict no es string en plist binario")
+			}
+			value, err := p.object(refs[i+length])
+			if err != nil {
+				return nil, err
+			}
+			dict[keyString] = value
+		}
+		return dict, nil
+	}
+
+	return nil, fmt.Errorf("tipo de objeto de plist binario no soportado: 0x%x", marker)
+}
+
+// length resuelve la longitud de un objeto: si info es 0xF la longitud es un
+// entero que sigue al marcador
+func (p *binaryPlist) length(info byte, pos uint64) (uint64, uint64, error) {
+	if info != 0x0f {
+		return uint64(info), pos, nil
+	}
+
+	marker, err := p.bytes(pos, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if marker[0]>>4 != 0x1 {
+		return 0, 0, fmt.Errorf("longitud inválida en plist binario")
+	}
+	size := uint64(1) << (marker[0] & 0x0f)
+	b, err := p.bytes(pos+1, size)
+	if err != nil {
+		return 0, 0, err
+	}
+	return readUint(b), pos + 1 + size, nil
+}
+
+// refs lee count referencias a objetos
+func (p *binaryPlist) refs(pos, count uint64) ([]uint64, error) {
+	b, err := p.bytes(pos, count*uint64(p.refSize))
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]uint64, count)
+	for i := range refs {
+		refs[i] = readUint(b[i*p.refSize : (i+1)*p.refSize])
+	}
+	return refs, nil
+}
+
+// bytes retorna n bytes a partir de pos validando los límites
+func (p *binaryPlist) bytes(pos, n uint64) ([]byte, error) {
+	if n > uint64(len(p.data)) || pos > uint64(len(p.data))-n {
+		return nil, fmt.Errorf("plist binario truncado")
+	}
+	return p.data[pos : pos+n], nil
+}
@@ -0,0 +1,630 @@
+// Package codesign lee y valida la firma de código (LC_CODE_SIGNATURE) de
+// binarios Mach-O sin depender de herramientas de macOS, para poder validar
+// la identidad de un bundle antes de instalarlo (y testearlo en Linux)
+package codesign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"debug/macho"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"os"
+	"time"
+)
+
+// Constantes del formato de firma de Apple (cs_blobs.h)
+const (
+	loadCmdCodeSignature = 0x1d
+
+	magicEmbeddedSignature = 0xfade0cc0
+	magicCodeDirectory     = 0xfade0c02
+	magicBlobWrapper       = 0xfade0b01
+
+	slotCodeDirectory = 0
+	slotCMSSignature  = 0x10000
+
+	// Versión de CodeDirectory a partir de la cual existe el campo teamOffset
+	codeDirectoryTeamVersion = 0x20200
+
+	// Versión de CodeDirectory a partir de la cual existe codeLimit64
+	codeDirectoryCodeLimit64Version = 0x20300
+)
+
+// Tipos de hash de página del CodeDirectory
+const (
+	hashTypeSHA1            = 1
+	hashTypeSHA256          = 2
+	hashTypeSHA256Truncated = 3
+	hashTypeSHA384          = 4
+)
+
+// ErrNotSigned indica que el binario no tiene firma de código
+var ErrNotSigned = errors.New("el binario no tiene firma de código")
+
+// ErrInvalidSignature indica que la firma no corresponde al binario, que la
+// firma CMS no es válida o que el certificado firmante no es de confianza
+var ErrInvalidSignature = errors.New("la firma de código no es válida")
+
+// Signature contiene la identidad de la firma de un binario Mach-O
+type Signature struct {
+	// Identifier es el identificador de firma (normalmente el bundle identifier)
+	Identifier string
+
+	// TeamID es el Team ID del CodeDirectory (vacío en firmas ad-hoc)
+	TeamID string
+
+	// CertificateTeamID es el Team ID (OU) del certificado firmante del blob
+	// CMS. Solo es confiable si la firma se obtuvo con Verify
+	CertificateTeamID string
+
+	// AdHoc indica que la firma no tiene blob CMS (firma ad-hoc)
+	AdHoc bool
+}
+
+// VerifyOptions configura Verify
+type VerifyOptions struct {
+	// Roots son las raíces de confianza del certificado firmante (ej: Apple
+	// Root CA). Es obligatorio
+	Roots *x509.CertPool
+}
+
+// ReadSignature lee la firma de un binario Mach-O (thin o universal). En binarios
+// universales todas las arquitecturas deben tener la misma identidad.
+//
+// Solo decodifica la firma: no valida que corresponda al binario ni que el
+// certificado sea de confianza. Para decidir si instalar un binario se debe
+// usar Verify
+func ReadSignature(path string) (*Signature, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo binario: %w", err)
+	}
+	defer file.Close()
+
+	return ReadSignatureFrom(file)
+}
+
+// ReadSignatureFrom lee la firma de un binario Mach-O (thin o universal) desde
+// un io.ReaderAt, por ejemplo un ejecutable leído desde un ZIP
+func ReadSignatureFrom(file io.ReaderAt) (*Signature, error) {
+	return eachArch(file, readSignature)
+}
+
+// Verify lee la firma de un binario Mach-O (thin o universal) y la valida:
+//   - los hashes de página del CodeDirectory coinciden con todo el binario
+//     (una firma genuina injertada en otro binario no pasa)
+//   - la firma CMS corresponde al CodeDirectory
+//   - el certificado firmante encadena a opts.Roots con uso de firma de código
+//
+// El Team ID del certificado se toma del firmante verificado. No valida los
+// recursos del bundle (CodeResources), que solo verifica codesign
+func Verify(path string, opts VerifyOptions) (*Signature, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo binario: %w", err)
+	}
+	defer file.Close()
+
+	return VerifyFrom(file, opts)
+}
+
+// VerifyFrom valida la firma de un binario Mach-O leído desde un io.ReaderAt
+func VerifyFrom(file io.ReaderAt, opts VerifyOptions) (*Signature, error) {
+	if opts.Roots == nil {
+		return nil, fmt.Errorf("%w: no hay raíces de confianza", ErrInvalidSignature)
+	}
+	return eachArch(file, func(arch *macho.File, r io.ReaderAt) (*Signature, error) {
+		return verifySignature(arch, r, opts)
+	})
+}
+
+// eachArch aplica read a cada arquitectura de un binario universal (que deben
+// tener la misma identidad) o al binario thin
+func eachArch(file io.ReaderAt, read func(*macho.File, io.ReaderAt) (*Signature, error)) (*Signature, error) {
+	if fat, err := macho.NewFatFile(file); err == nil {
+		var first *Signature
+		for _, arch := range fat.Arches {
+			sig, err := read(arch.File, io.NewSectionReader(file, int64(arch.Offset), int64(arch.Size)))
+			if err != nil {
+				return nil, fmt.Errorf("arquitectura %s: %w", arch.Cpu, err)
+			}
+			if first == nil {
+				first = sig
+			} else if *sig != *first {
+				return nil, fmt.Errorf("las arquitecturas del binario universal tienen firmas distintas")
+			}
+		}
+		if first == nil {
+			return nil, fmt.Errorf("binario universal sin arquitecturas")
+		}
+		return first, nil
+	}
+
+	thin, err := macho.NewFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo Mach-O: %w", err)
+	}
+	return read(thin, file)
+}
+
+// embeddedSignature son las partes de la firma embebida en un Mach-O
+type embeddedSignature struct {
+	// offset es donde empieza la firma: el código firmado termina ahí
+	offset uint32
+
+	codeDirectory []byte
+
+	// cms es el SignedData (vacío en firmas ad-hoc)
+	cms []byte
+}
+
+// readSignature decodifica la firma sin validarla
+func readSignature(file *macho.File, r io.ReaderAt) (*Signature, error) {
+	embedded, err := readEmbeddedSignature(file, r)
+	if err != nil {
+		return nil, err
+	}
+	return embedded.signature()
+}
+
+// verifySignature decodifica y valida la firma
+func verifySignature(file *macho.File, r io.ReaderAt, opts VerifyOptions) (*Signature, error) {
+	embedded, err := readEmbeddedSignature(file, r)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := embedded.signature()
+	if err != nil {
+		return nil, err
+	}
+	if sig.AdHoc {
+		return nil, fmt.Errorf("%w: firma ad-hoc", ErrInvalidSignature)
+	}
+
+	if err := verifyCodePages(embedded.codeDirectory, embedded.offset, r); err != nil {
+		return nil, err
+	}
+
+	parsed, err := parseCMS(embedded.cms)
+	if err != nil {
+		return nil, err
+	}
+	if err := parsed.verify(embedded.codeDirectory, opts.Roots); err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+// readEmbeddedSignature localiza LC_CODE_SIGNATURE y decodifica el superblob
+func readEmbeddedSignature(file *macho.File, r io.ReaderAt) (*embeddedSignature, error) {
+	for _, load := range file.Loads {
+		raw := load.Raw()
+		if len(raw) < 16 || file.ByteOrder.Uint32(raw[0:4]) != loadCmdCodeSignature {
+			continue
+		}
+
+		dataOff := file.ByteOrder.Uint32(raw[8:12])
+		dataSize := file.ByteOrder.Uint32(raw[12:16])
+
+		blob := make([]byte, dataSize)
+		if _, err := r.ReadAt(blob, int64(dataOff)); err != nil {
+			return nil, fmt.Errorf("error leyendo firma: %w", err)
+		}
+		embedded, err := parseSuperBlob(blob)
+		if err != nil {
+			return nil, err
+		}
+		embedded.offset = dataOff
+		return embedded, nil
+	}
+
+	return nil, ErrNotSigned
+}
+
+// parseSuperBlob decodifica el EmbeddedSignature (big endian)
+func parseSuperBlob(blob []byte) (*embeddedSignature, error) {
+	be := binary.BigEndian
+	if len(blob) < 12 || be.Uint32(blob[0:4]) != magicEmbeddedSignature {
+		return nil, fmt.Errorf("superblob de firma inválido")
+	}
+
+	count := be.Uint32(blob[8:12])
+	if uint64(count)*8+12 > uint64(len(blob)) {
+		return nil, fmt.Errorf("índice de superblob inválido")
+	}
+
+	embedded := &embeddedSignature{}
+	for i := uint32(0); i < count; i++ {
+		entry := blob[12+i*8:]
+		slot := be.Uint32(entry[0:4])
+		offset := be.Uint32(entry[4:8])
+
+		sub, err := subBlob(blob, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		switch slot {
+		case slotCodeDirectory:
+			embedded.codeDirectory = sub
+		case slotCMSSignature:
+			if be.Uint32(sub[0:4]) != magicBlobWrapper {
+				return nil, fmt.Errorf("blob CMS inválido")
+			}
+			// Las firmas ad-hoc tienen un blob CMS vacío
+			embedded.cms = sub[8:]
+		}
+	}
+
+	if embedded.codeDirectory == nil {
+		return nil, fmt.Errorf("la firma no contiene CodeDirectory")
+	}
+
+	return embedded, nil
+}
+
+// signature extrae la identidad del CodeDirectory y del certificado firmante
+func (e *embeddedSignature) signature() (*Signature, error) {
+	sig := &Signature{AdHoc: true}
+	if err := parseCodeDirectory(e.codeDirectory, sig); err != nil {
+		return nil, err
+	}
+
+	if len(e.cms) > 0 {
+		parsed, err := parseCMS(e.cms)
+		if err != nil {
+			return nil, err
+		}
+		if ou := parsed.signerCert.Subject.OrganizationalUnit; len(ou) > 0 {
+			sig.CertificateTeamID = ou[0]
+		}
+		sig.AdHoc = false
+	}
+
+	return sig, nil
+}
+
+// subBlob retorna el blob que empieza en offset, acotado por su longitud
+func subBlob(blob []byte, offset uint32) ([]byte, error) {
+	if uint64(offset)+8 > uint64(len(blob)) {
+		return nil, fmt.Errorf("offset de blob fuera de rango")
+	}
+	length := binary.BigEndian.Uint32(blob[offset+4 : offset+8])
+	if length < 8 || uint64(offset)+uint64(length) > uint64(len(blob)) {
+		return nil, fmt.Errorf("longitud de blob fuera de rango")
+	}
+	return blob[offset : offset+length], nil
+}
+
+// parseCodeDirectory extrae el identificador y el Team ID del CodeDirectory
+func parseCodeDirectory(cd []byte, sig *Signature) error {
+	be := binary.BigEndian
+	if len(cd) < 44 || be.Uint32(cd[0:4]) != magicCodeDirectory {
+		return fmt.Errorf("CodeDirectory inválido")
+	}
+
+	version := be.Uint32(cd[8:12])
+	identifier, err := cString(cd, be.Uint32(cd[20:24]))
+	if err != nil {
+		return err
+	}
+	sig.Identifier = identifier
+
+	if version >= codeDirectoryTeamVersion && len(cd) >= 52 {
+		if teamOffset := be.Uint32(cd[48:52]); teamOffset != 0 {
+			teamID, err := cString(cd, teamOffset)
+			if err != nil {
+				return err
+			}
+			sig.TeamID = teamID
+		}
+	}
+
+	return nil
+}
+
+// cString lee un string terminado en NUL dentro del blob
+func cString(blob []byte, offset uint32) (string, error) {
+	if uint64(offset) >= uint64(len(blob)) {
+		return "", fmt.Errorf("offset de string fuera de rango")
+	}
+	for i := offset; i < uint32(len(blob)); i++ {
+		if blob[i] == 0 {
+			return string(blob[offset:i]), nil
+		}
+	}
+	return "", fmt.Errorf("string sin terminar en CodeDirectory")
+}
+
+// verifyCodePages compara los hashes de página del CodeDirectory con el
+// binario. El código firmado debe llegar hasta la propia firma, de modo que
+// no quede contenido del binario fuera de los hashes
+func verifyCodePages(cd []byte, signatureOffset uint32, r io.ReaderAt) error {
+	be := binary.BigEndian
+	version := be.Uint32(cd[8:12])
+	hashOffset := uint64(be.Uint32(cd[16:20]))
+	nCodeSlots := uint64(be.Uint32(cd[28:32]))
+	codeLimit := uint64(be.Uint32(cd[32:36]))
+	hashSize := uint64(cd[36])
+	hashType := cd[37]
+	pageShift := cd[39]
+
+	if version >= codeDirectoryCodeLimit64Version && len(cd) >= 64 {
+		if limit64 := be.Uint64(cd[56:64]); limit64 != 0 {
+			codeLimit = limit64
+		}
+	}
+	if codeLimit != uint64(signatureOffset) {
+		return fmt.Errorf("%w: el CodeDirectory cubre %d bytes pero la firma empieza en %d", ErrInvalidSignature, codeLimit, signatureOffset)
+	}
+
+	newHash, size, err := pageHash(hashType)
+	if err != nil {
+		return err
+	}
+	if hashSize != size {
+		return fmt.Errorf("%w: tamaño de hash de página %d inválido", ErrInvalidSignature, hashSize)
+	}
+
+	pageSize := codeLimit
+	if pageShift != 0 {
+		if pageShift > 30 {
+			return fmt.Errorf("%w: tamaño de página inválido", ErrInvalidSignature)
+		}
+		pageSize = 1 << pageShift
+	}
+	if pageSize == 0 || nCodeSlots != (codeLimit+pageSize-1)/pageSize {
+		return fmt.Errorf("%w: el CodeDirectory tiene %d páginas para %d bytes", ErrInvalidSignature, nCodeSlots, codeLimit)
+	}
+	if hashOffset+nCodeSlots*hashSize > uint64(len(cd)) {
+		return fmt.Errorf("%w: hashes de página fuera de rango", ErrInvalidSignature)
+	}
+
+	h := newHash()
+	for i := uint64(0); i < nCodeSlots; i++ {
+		start := i * pageSize
+		length := pageSize
+		if start+length > codeLimit {
+			length = codeLimit - start
+		}
+
+		h.Reset()
+		n, err := io.Copy(h, io.NewSectionReader(r, int64(start), int64(length)))
+		if err != nil {
+			return fmt.Errorf("error leyendo binario: %w", err)
+		}
+		if uint64(n) != length {
+			return fmt.Errorf("%w: el binario es más corto que el código firmado", ErrInvalidSignature)
+		}
+
+		want := cd[hashOffset+i*hashSize : hashOffset+(i+1)*hashSize]
+		if !bytes.Equal(h.Sum(nil)[:hashSize], want) {
+			return fmt.Errorf("%w: la página %d no coincide con el CodeDirectory", ErrInvalidSignature, i)
+		}
+	}
+
+	return nil
+}
+
+// pageHash retorna el hash de página del tipo indicado y su tamaño en el
+// CodeDirectory
+func pageHash(hashType byte) (func() hash.Hash, uint64, error) {
+	switch hashType {
+	case hashTypeSHA1:
+		return sha1.New, sha1.Size, nil
+	case hashTypeSHA256:
+		return sha256.New, sha256.Size, nil
+	case hashTypeSHA256Truncated:
+		return sha256.New, 20, nil
+	case hashTypeSHA384:
+		return sha512.New384, sha512.Size384, nil
+	}
+	return nil, 0, fmt.Errorf("%w: tipo de hash de página %d no soportado", ErrInvalidSignature, hashType)
+}
+
+// Estructuras ASN.1 mínimas de PKCS#7 / CMS SignedData (RFC 5652)
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+type signerInfo struct {
+	Version            int
+	IssuerAndSerial    issuerAndSerial
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// OIDs de los atributos firmados y de los algoritmos de digest
+var (
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// parsedCMS es el SignedData con su único firmante
+type parsedCMS struct {
+	certs      []*x509.Certificate
+	signer     signerInfo
+	signerCert *x509.Certificate
+}
+
+// parseCMS decodifica el SignedData y localiza el certificado del firmante
+// por emisor y número de serie (no por su posición en la lista)
+func parseCMS(der []byte) (*parsedCMS, error) {
+	var info contentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("error decodificando CMS: %w", err)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("error decodificando SignedData: %w", err)
+	}
+
+	if len(sd.Certificates.Bytes) == 0 {
+		return nil, fmt.Errorf("el blob CMS no contiene certificados")
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parseando certificados: %w", err)
+	}
+
+	parsed := &parsedCMS{certs: certs}
+	rest, err := asn1.Unmarshal(sd.SignerInfos.Bytes, &parsed.signer)
+	if err != nil {
+		return nil, fmt.Errorf("error decodificando SignerInfo: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("el blob CMS tiene más de un firmante")
+	}
+
+	for _, cert := range certs {
+		if cert.SerialNumber.Cmp(parsed.signer.IssuerAndSerial.Serial) == 0 &&
+			bytes.Equal(cert.RawIssuer, parsed.signer.IssuerAndSerial.Issuer.FullBytes) {
+			parsed.signerCert = cert
+			break
+		}
+	}
+	if parsed.signerCert == nil {
+		return nil, fmt.Errorf("no se encontró el certificado firmante en el blob CMS")
+	}
+
+	return parsed, nil
+}
+
+// verify valida que la firma CMS corresponde al CodeDirectory (atributo
+// messageDigest) y que el certificado firmante encadena a roots
+func (p *parsedCMS) verify(codeDirectory []byte, roots *x509.CertPool) error {
+	digest, err := digestHash(p.signer.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	if len(p.signer.SignedAttrs.FullBytes) == 0 {
+		return fmt.Errorf("%w: la firma CMS no tiene atributos firmados", ErrInvalidSignature)
+	}
+
+	var messageDigest []byte
+	attrs := p.signer.SignedAttrs.Bytes
+	for len(attrs) > 0 {
+		var attr attribute
+		rest, err := asn1.Unmarshal(attrs, &attr)
+		if err != nil {
+			return fmt.Errorf("%w: atributo firmado inválido: %v", ErrInvalidSignature, err)
+		}
+		attrs = rest
+
+		if attr.Type.Equal(oidMessageDigest) {
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &messageDigest); err != nil {
+				return fmt.Errorf("%w: messageDigest inválido: %v", ErrInvalidSignature, err)
+			}
+		}
+	}
+
+	h := digest.New()
+	h.Write(codeDirectory)
+	if messageDigest == nil || !bytes.Equal(messageDigest, h.Sum(nil)) {
+		return fmt.Errorf("%w: la firma CMS no corresponde al CodeDirectory", ErrInvalidSignature)
+	}
+
+	// La firma cubre los atributos codificados como SET OF (RFC 5652, 5.4)
+	signed := append([]byte{}, p.signer.SignedAttrs.FullBytes...)
+	signed[0] = 0x31
+	algorithm, err := signatureAlgorithm(p.signerCert.PublicKeyAlgorithm, digest)
+	if err != nil {
+		return err
+	}
+	if err := p.signerCert.CheckSignature(algorithm, signed, p.signer.Signature); err != nil {
+		return fmt.Errorf("%w: firma CMS: %v", ErrInvalidSignature, err)
+	}
+
+	// La cadena se valida en el momento actual. El atributo signingTime lo
+	// elige el firmante: con una clave vencida o revocada se podría fechar la
+	// firma hacia atrás, y no hay sello de tiempo RFC 3161 que lo respalde
+	intermediates := x509.NewCertPool()
+	for _, cert := range p.certs {
+		if cert != p.signerCert {
+			intermediates.AddCert(cert)
+		}
+	}
+	_, err = p.signerCert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: certificado firmante no confiable: %v", ErrInvalidSignature, err)
+	}
+
+	return nil
+}
+
+// digestHash retorna el hash del algoritmo de digest del firmante
+func digestHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("%w: algoritmo de digest %s no soportado", ErrInvalidSignature, oid)
+}
+
+// signatureAlgorithm combina el tipo de clave del certificado con el digest
+func signatureAlgorithm(key x509.PublicKeyAlgorithm, digest crypto.Hash) (x509.SignatureAlgorithm, error) {
+	algorithms := map[x509.PublicKeyAlgorithm]map[crypto.Hash]x509.SignatureAlgorithm{
+		x509.RSA: {
+			crypto.SHA256: x509.SHA256WithRSA,
+			crypto.SHA384: x509.SHA384WithRSA,
+			crypto.SHA512: x509.SHA512WithRSA,
+		},
+		x509.ECDSA: {
+			crypto.SHA256: x509.ECDSAWithSHA256,
+			crypto.SHA384: x509.ECDSAWithSHA384,
+			crypto.SHA512: x509.ECDSAWithSHA512,
+		},
+	}
+	if algorithm, ok := algorithms[key][digest]; ok {
+		return algorithm, nil
+	}
+	return 0, fmt.Errorf("%w: algoritmo de firma %s con %s no soportado", ErrInvalidSignature, key, digest)
+}
@@ -0,0 +1,485 @@
+package codesign
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fixtureSignatureSize es el espacio reservado para la firma en los Mach-O de
+// prueba, para que el código firmado no dependa del tamaño de la firma
+const fixtureSignatureSize = 8192
+
+// machOCode genera el código firmado de un Mach-O x86_64: el header y
+// LC_CODE_SIGNATURE apuntando a la firma que empieza a continuación
+func machOCode() []byte {
+	le := binary.LittleEndian
+
+	header := make([]byte, 32)
+	le.PutUint32(header[0:], 0xfeedfacf)
+	le.PutUint32(header[4:], 0x01000007)
+	le.PutUint32(header[8:], 3)
+	le.PutUint32(header[12:], 2)
+	le.PutUint32(header[16:], 1)
+	le.PutUint32(header[20:], 16)
+
+	cmd := make([]byte, 16)
+	le.PutUint32(cmd[0:], loadCmdCodeSignature)
+	le.PutUint32(cmd[4:], 16)
+	le.PutUint32(cmd[8:], 48)
+	le.PutUint32(cmd[12:], fixtureSignatureSize)
+
+	return append(header, cmd...)
+}
+
+// buildCodeDirectory genera un CodeDirectory con identificador, Team ID y los
+// hashes SHA-256 de las páginas de code
+func buildCodeDirectory(identifier, teamID string, code []byte) []byte {
+	be := binary.BigEndian
+	const pageShift = 12
+	pageSize := 1 << pageShift
+	pages := (len(code) + pageSize - 1) / pageSize
+
+	cd := make([]byte, 52)
+	be.PutUint32(cd[0:], magicCodeDirectory)
+	be.PutUint32(cd[8:], codeDirectoryTeamVersion)
+	be.PutUint32(cd[20:], uint32(len(cd)))
+	be.PutUint32(cd[28:], uint32(pages))
+	be.PutUint32(cd[32:], uint32(len(code)))
+	cd[36] = sha256.Size
+	cd[37] = hashTypeSHA256
+	cd[39] = pageShift
+	cd = append(cd, identifier...)
+	cd = append(cd, 0)
+	if teamID != "" {
+		be.PutUint32(cd[48:], uint32(len(cd)))
+		cd = append(cd, teamID...)
+		cd = append(cd, 0)
+	}
+
+	be.PutUint32(cd[16:], uint32(len(cd)))
+	for i := 0; i < pages; i++ {
+		end := (i + 1) * pageSize
+		if end > len(code) {
+			end = len(code)
+		}
+		sum := sha256.Sum256(code[i*pageSize : end])
+		cd = append(cd, sum[:]...)
+	}
+
+	be.PutUint32(cd[4:], uint32(len(cd)))
+	return cd
+}
+
+// testIdentity es un certificado firmante con OU = Team ID y las raíces de
+// confianza con las que se valida
+type testIdentity struct {
+	roots *x509.CertPool
+	certs [][]byte
+	leaf  *x509.Certificate
+	key   *ecdsa.PrivateKey
+
+	// ca y caKey emiten nuevos certificados con las mismas raíces
+	ca    *x509.Certificate
+	caKey *ecdsa.PrivateKey
+
+	// signingTime, si no es cero, se incluye como atributo firmado
+	signingTime time.Time
+}
+
+// newTestIdentity crea una CA de prueba y un certificado de firma de código
+// emitido por ella. Si selfSigned, el certificado firmante se firma a sí
+// mismo, aunque la CA genuina viaja en el blob
+func newTestIdentity(t *testing.T, teamID string, selfSigned bool) *testIdentity {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Now().Add(-72 * time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject: pkix.Name{
+			CommonName:         "Developer ID Application: Test (" + teamID + ")",
+			OrganizationalUnit: []string{teamID},
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	parent, parentKey := ca, caKey
+	if selfSigned {
+		parent, parentKey = leafTemplate, key
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return &testIdentity{roots: roots, certs: [][]byte{caDER, leafDER}, leaf: leaf, key: key, ca: ca, caKey: caKey}
+}
+
+// expired retorna una identidad de la misma CA cuyo certificado venció hace un
+// día, con un signingTime de cuando todavía era válido
+func (id *testIdentity) expired(t *testing.T) *testIdentity {
+	t.Helper()
+
+	template := *id.leaf
+	template.SerialNumber = big.NewInt(3)
+	template.NotBefore = time.Now().Add(-48 * time.Hour)
+	template.NotAfter = time.Now().Add(-24 * time.Hour)
+	leafDER, err := x509.CreateCertificate(rand.Reader, &template, id.ca, &id.key.PublicKey, id.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := *id
+	expired.certs = [][]byte{id.certs[0], leafDER}
+	expired.leaf = leaf
+	expired.signingTime = time.Now().Add(-36 * time.Hour).UTC().Truncate(time.Second)
+	return &expired
+}
+
+// buildCMS genera un SignedData de id sobre el CodeDirectory cd, como el que
+// genera codesign: atributos firmados contentType y messageDigest
+func buildCMS(t *testing.T, id *testIdentity, cd []byte) []byte {
+	t.Helper()
+
+	mustMarshal := func(v any) []byte {
+		der, err := asn1.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	dataOID := asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	sha256Alg := pkix.AlgorithmIdentifier{Algorithm: oidSHA256}
+
+	type attr struct {
+		Type   asn1.ObjectIdentifier
+		Values asn1.RawValue
+	}
+	digest := sha256.Sum256(cd)
+	attrs := append(
+		mustMarshal(attr{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}, asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: mustMarshal(dataOID)}}),
+		mustMarshal(attr{oidMessageDigest, asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: mustMarshal(digest[:])}})...,
+	)
+	if !id.signingTime.IsZero() {
+		signingTimeOID := asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+		attrs = append(attrs, mustMarshal(attr{signingTimeOID, asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: mustMarshal(id.signingTime)}})...)
+	}
+	signedAttrs := mustMarshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: attrs})
+	signedDigest := sha256.Sum256(signedAttrs)
+	signature, err := ecdsa.SignASN1(rand.Reader, id.key, signedDigest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := mustMarshal(struct {
+		Version         int
+		IssuerAndSerial struct {
+			Issuer asn1.RawValue
+			Serial *big.Int
+		}
+		DigestAlgorithm    pkix.AlgorithmIdentifier
+		SignedAttrs        asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		Signature          []byte
+	}{
+		Version: 1,
+		IssuerAndSerial: struct {
+			Issuer asn1.RawValue
+			Serial *big.Int
+		}{asn1.RawValue{FullBytes: id.leaf.RawIssuer}, id.leaf.SerialNumber},
+		DigestAlgorithm:    sha256Alg,
+		SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          signature,
+	})
+
+	var certs []byte
+	for _, cert := range id.certs {
+		certs = append(certs, cert...)
+	}
+	sd := mustMarshal(struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue
+		SignerInfos      asn1.RawValue
+	}{
+		Version:          1,
+		DigestAlgorithms: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: mustMarshal(sha256Alg)},
+		ContentInfo:      asn1.RawValue{FullBytes: mustMarshal(struct{ ContentType asn1.ObjectIdentifier }{dataOID})},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos:      asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: signer},
+	})
+
+	return mustMarshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{
+		ContentType: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2},
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
+
+// buildMachO genera un Mach-O x86_64 cuya firma es un superblob con los
+// blobs indicados (slot -> blob). Sin blobs no hay firma
+func buildMachO(blobs map[uint32][]byte) []byte {
+	be := binary.BigEndian
+
+	if len(blobs) == 0 {
+		header := machOCode()[:32]
+		binary.LittleEndian.PutUint32(header[16:], 0)
+		binary.LittleEndian.PutUint32(header[20:], 0)
+		return header
+	}
+
+	var slots []uint32
+	for _, slot := range []uint32{slotCodeDirectory, slotCMSSignature} {
+		if _, ok := blobs[slot]; ok {
+			slots = append(slots, slot)
+		}
+	}
+
+	// Superblob: header, índice (slot, offset) y blobs a continuación
+	superBlob := make([]byte, 12+8*len(slots))
+	pos := uint32(len(superBlob))
+	for i, slot := range slots {
+		be.PutUint32(superBlob[12+i*8:], slot)
+		be.PutUint32(superBlob[16+i*8:], pos)
+		pos += uint32(len(blobs[slot]))
+	}
+	for _, slot := range slots {
+		superBlob = append(superBlob, blobs[slot]...)
+	}
+	be.PutUint32(superBlob[0:], magicEmbeddedSignature)
+	be.PutUint32(superBlob[8:], uint32(len(slots)))
+	be.PutUint32(superBlob[4:], uint32(len(superBlob)))
+
+	padded := make([]byte, fixtureSignatureSize)
+	copy(padded, superBlob)
+	return append(machOCode(), padded...)
+}
+
+// signedMachO genera un Mach-O firmado por id
+func signedMachO(t *testing.T, identifier, teamID string, id *testIdentity) []byte {
+	t.Helper()
+	cd := buildCodeDirectory(identifier, teamID, machOCode())
+	return buildMachO(map[uint32][]byte{
+		slotCodeDirectory: cd,
+		slotCMSSignature:  wrapCMS(buildCMS(t, id, cd)),
+	})
+}
+
+// wrapCMS envuelve el CMS en un BlobWrapper
+func wrapCMS(cms []byte) []byte {
+	blob := make([]byte, 8)
+	binary.BigEndian.PutUint32(blob[0:], magicBlobWrapper)
+	binary.BigEndian.PutUint32(blob[4:], uint32(8+len(cms)))
+	return append(blob, cms...)
+}
+
+func writeFixture(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "MyApp")
+	if err := os.WriteFile(path, data, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadSignatureDeveloperID(t *testing.T) {
+	id := newTestIdentity(t, "ABCDE12345", false)
+	path := writeFixture(t, signedMachO(t, "com.joobpay.myapp", "ABCDE12345", id))
+
+	sig, err := ReadSignature(path)
+	if err != nil {
+		t.Fatalf("ReadSignature: %v", err)
+	}
+
+	want := Signature{Identifier: "com.joobpay.myapp", TeamID: "ABCDE12345", CertificateTeamID: "ABCDE12345"}
+	if *sig != want {
+		t.Fatalf("firma = %+v, esperada %+v", *sig, want)
+	}
+}
+
+func TestReadSignatureAdHoc(t *testing.T) {
+	path := writeFixture(t, buildMachO(map[uint32][]byte{
+		slotCodeDirectory: buildCodeDirectory("myapp-55554944", "", machOCode()),
+		slotCMSSignature:  wrapCMS(nil),
+	}))
+
+	sig, err := ReadSignature(path)
+	if err != nil {
+		t.Fatalf("ReadSignature: %v", err)
+	}
+	if !sig.AdHoc || sig.TeamID != "" {
+		t.Fatalf("firma = %+v, esperada ad-hoc sin Team ID", *sig)
+	}
+}
+
+func TestReadSignatureUnsigned(t *testing.T) {
+	path := writeFixture(t, buildMachO(nil))
+
+	if _, err := ReadSignature(path); !errors.Is(err, ErrNotSigned) {
+		t.Fatalf("error = %v, esperado ErrNotSigned", err)
+	}
+}
+
+func TestVerifyDeveloperID(t *testing.T) {
+	id := newTestIdentity(t, "ABCDE12345", false)
+	path := writeFixture(t, signedMachO(t, "com.joobpay.myapp", "ABCDE12345", id))
+
+	sig, err := Verify(path, VerifyOptions{Roots: id.roots})
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	want := Signature{Identifier: "com.joobpay.myapp", TeamID: "ABCDE12345", CertificateTeamID: "ABCDE12345"}
+	if *sig != want {
+		t.Fatalf("firma = %+v, esperada %+v", *sig, want)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	genuine := newTestIdentity(t, "ABCDE12345", false)
+
+	tests := []struct {
+		name  string
+		build func() []byte
+		roots *x509.CertPool
+	}{
+		{
+			// Un atacante con control del bucket firma con un certificado
+			// propio cuyo OU es el Team ID esperado
+			name: "certificado autofirmado",
+			build: func() []byte {
+				return signedMachO(t, "com.joobpay.myapp", "ABCDE12345", newTestIdentity(t, "ABCDE12345", true))
+			},
+		},
+		{
+			name: "certificado de otra CA",
+			build: func() []byte {
+				return signedMachO(t, "com.joobpay.myapp", "ABCDE12345", newTestIdentity(t, "ABCDE12345", false))
+			},
+		},
+		{
+			// La firma genuina de un binario injertada en otro binario
+			name: "firma injertada en otro binario",
+			build: func() []byte {
+				data := signedMachO(t, "com.joobpay.myapp", "ABCDE12345", genuine)
+				data[28] ^= 0xff // campo reservado del header, dentro del código firmado
+				return data
+			},
+		},
+		{
+			name: "CodeDirectory alterado",
+			build: func() []byte {
+				cd := buildCodeDirectory("com.joobpay.myapp", "ABCDE12345", machOCode())
+				forged := buildCodeDirectory("com.joobpay.evil", "ABCDE12345", machOCode())
+				return buildMachO(map[uint32][]byte{
+					slotCodeDirectory: forged,
+					slotCMSSignature:  wrapCMS(buildCMS(t, genuine, cd)),
+				})
+			},
+		},
+		{
+			name: "firmado con otra clave",
+			build: func() []byte {
+				other := newTestIdentity(t, "ABCDE12345", false)
+				forger := *genuine
+				forger.key = other.key
+				return signedMachO(t, "com.joobpay.myapp", "ABCDE12345", &forger)
+			},
+		},
+		{
+			// Con la clave de un certificado vencido se fecha la firma en
+			// signingTime cuando el certificado todavía era válido
+			name: "certificado vencido con signingTime retroactivo",
+			build: func() []byte {
+				return signedMachO(t, "com.joobpay.myapp", "ABCDE12345", genuine.expired(t))
+			},
+		},
+		{
+			name: "ad-hoc",
+			build: func() []byte {
+				return buildMachO(map[uint32][]byte{
+					slotCodeDirectory: buildCodeDirectory("com.joobpay.myapp", "", machOCode()),
+					slotCMSSignature:  wrapCMS(nil),
+				})
+			},
+		},
+		{
+			name: "sin raíces de confianza",
+			build: func() []byte {
+				return signedMachO(t, "com.joobpay.myapp", "ABCDE12345", genuine)
+			},
+			roots: x509.NewCertPool(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roots := genuine.roots
+			if tt.roots != nil {
+				roots = tt.roots
+			}
+			path := writeFixture(t, tt.build())
+			sig, err := Verify(path, VerifyOptions{Roots: roots})
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Fatalf("Verify = %+v, %v, esperado ErrInvalidSignature", sig, err)
+			}
+		})
+	}
+
+	if _, err := Verify(writeFixture(t, signedMachO(t, "com.joobpay.myapp", "ABCDE12345", genuine)), VerifyOptions{}); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Verify sin Roots = %v, esperado ErrInvalidSignature", err)
+	}
+}
@@ -0,0 +1,279 @@
+// Package releaseserver sirve un directorio de releases por HTTP (Range y
+// ETag) con inyección opcional de fallas, para ensayar el flujo de
+// actualización sin S3 y para los tests de integración
+package releaseserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Faults describe las fallas a inyectar en las respuestas
+type Faults struct {
+	// Pattern limita las fallas a los archivos cuyo nombre coincide (path.Match
+	// sobre el nombre base, ej: "*.zip"). Vacío aplica a todos
+	Pattern string
+
+	// Latency es la demora agregada antes de responder
+	Latency time.Duration
+
+	// FailFirst responde 500 a las primeras N peticiones afectadas
+	FailFirst int
+
+	// ErrorRate es la probabilidad (0 a 1) de responder 500
+	ErrorRate float64
+
+	// TruncateAfter corta la conexión tras enviar N bytes del cuerpo, con el
+	// Content-Length completo ya anunciado (0 desactiva)
+	TruncateAfter int64
+
+	// Corrupt altera el primer byte del cuerpo para provocar un checksum incorrecto
+	Corrupt bool
+}
+
+// Server sirve un directorio de releases
+type Server struct {
+	root   string
+	faults Faults
+
+	// Logger registra cada petición y las fallas inyectadas (nil no registra)
+	Logger *log.Logger
+
+	// CacheControl es el Cache-Control de las respuestas (vacío usa no-cache)
+	CacheControl string
+
+	mu       sync.Mutex
+	failed   int
+	rand     *rand.Rand
+	etags    map[string]etagEntry
+	requests map[string]int
+}
+
+type etagEntry struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// New crea un servidor para el directorio root
+func New(root string, faults Faults) *Server {
+	return &Server{
+		root:     root,
+		faults:   faults,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		etags:    make(map[string]etagEntry),
+		requests: make(map[string]int),
+	}
+}
+
+// Requests retorna cuántas peticiones recibió la ruta indicada
+func (s *Server) Requests(urlPath string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[urlPath]
+}
+
+// ServeHTTP implementa http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := path.Clean("/" + r.URL.Path)
+	s.mu.Lock()
+	s.requests[name]++
+	s.mu.Unlock()
+
+	filePath := filepath.Join(s.root, filepath.FromSlash(name))
+	file, err := os.Open(filePath)
+	if err != nil {
+		s.logf("%s %s -> 404", r.Method, name)
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil || stat.IsDir() {
+		s.logf("%s %s -> 404", r.Method, name)
+		http.NotFound(w, r)
+		return
+	}
+
+	etag, err := s.etag(filePath, stat)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	cacheControl := s.CacheControl
+	if cacheControl == "" {
+		cacheControl = "no-cache"
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+
+	if s.affects(name) {
+		if s.faults.Latency > 0 {
+			s.logf("%s %s: latencia %s", r.Method, name, s.faults.Latency)
+			time.Sleep(s.faults.Latency)
+		}
+		if s.shouldFail() {
+			s.logf("%s %s -> 500 (falla inyectada)", r.Method, name)
+			http.Error(w, "falla inyectada", http.StatusInternalServerError)
+			return
+		}
+		if s.faults.TruncateAfter > 0 || s.faults.Corrupt {
+			s.logf("%s %s: cuerpo alterado (truncado tras %d bytes, corrupto: %t)", r.Method, name, s.faults.TruncateAfter, s.faults.Corrupt)
+			w = &faultyWriter{ResponseWriter: w, remaining: s.faults.TruncateAfter, corrupt: s.faults.Corrupt}
+		}
+	}
+
+	s.logf("%s %s %s", r.Method, name, r.Header.Get("Range"))
+	http.ServeContent(w, r, stat.Name(), stat.ModTime(), file)
+
+	if fw, ok := w.(*faultyWriter); ok && fw.truncated {
+		// Cortar la conexión sin completar el cuerpo anunciado
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// affects indica si las fallas aplican a la ruta
+func (s *Server) affects(name string) bool {
+	if s.faults.Pattern == "" {
+		return true
+	}
+	matched, err := path.Match(s.faults.Pattern, path.Base(name))
+	return err == nil && matched
+}
+
+// shouldFail decide si la petición responde con un 500
+func (s *Server) shouldFail() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failed < s.faults.FailFirst {
+		s.failed++
+		return true
+	}
+	return s.faults.ErrorRate > 0 && s.rand.Float64() < s.faults.ErrorRate
+}
+
+// etag calcula un ETag fuerte a partir del SHA-256 del archivo, cacheado
+// mientras no cambien el tamaño ni la fecha de modificación
+func (s *Server) etag(filePath string, stat os.FileInfo) (string, error) {
+	s.mu.Lock()
+	entry, ok := s.etags[filePath]
+	s.mu.Unlock()
+	if ok && entry.size == stat.Size() && entry.modTime.Equal(stat.ModTime()) {
+		return entry.etag, nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("error calculando ETag: %w", err)
+	}
+	etag := `"` + hex.EncodeToString(hasher.Sum(nil)) + `"`
+
+	s.mu.Lock()
+	s.etags[filePath] = etagEntry{size: stat.Size(), modTime: stat.ModTime(), etag: etag}
+	s.mu.Unlock()
+
+	return etag, nil
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, args...)
+	}
+}
+
+// faultyWriter trunca y/o corrompe el cuerpo de la respuesta
+type faultyWriter struct {
+	http.ResponseWriter
+	remaining int64
+	corrupt   bool
+	written   int64
+	truncated bool
+}
+
+func (w *faultyWriter) Write(p []byte) (int, error) {
+	if w.truncated {
+		return 0, io.ErrShortWrite
+	}
+
+	n := len(p)
+	if w.remaining > 0 && int64(len(p)) > w.remaining-w.written {
+		p = p[:w.remaining-w.written]
+		w.truncated = true
+	}
+
+	if w.corrupt && w.written == 0 && len(p) > 0 {
+		p = append([]byte{p[0] ^ 0xff}, p[1:]...)
+	}
+
+	written, err := w.ResponseWriter.Write(p)
+	w.written += int64(written)
+	if err != nil {
+		return written, err
+	}
+	if w.truncated {
+		if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		return written, io.ErrShortWrite
+	}
+	return n, nil
+}
+
+// ParsePattern valida un patrón de Faults.Pattern
+func ParsePattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("patrón inválido %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// String resume las fallas activas
+func (f Faults) String() string {
+	var parts []string
+	if f.Latency > 0 {
+		parts = append(parts, "latencia "+f.Latency.String())
+	}
+	if f.FailFirst > 0 {
+		parts = append(parts, fmt.Sprintf("500 en las primeras %d peticiones", f.FailFirst))
+	}
+	if f.ErrorRate > 0 {
+		parts = append(parts, fmt.Sprintf("500 con probabilidad %.2f", f.ErrorRate))
+	}
+	if f.TruncateAfter > 0 {
+		parts = append(parts, fmt.Sprintf("truncar tras %d bytes", f.TruncateAfter))
+	}
+	if f.Corrupt {
+		parts = append(parts, "cuerpo corrupto")
+	}
+	if len(parts) == 0 {
+		return "ninguna"
+	}
+	if f.Pattern != "" {
+		return strings.Join(parts, ", ") + " (solo " + f.Pattern + ")"
+	}
+	return strings.Join(parts, ", ")
+}
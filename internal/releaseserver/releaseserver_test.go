@@ -0,0 +1,134 @@
+package releaseserver
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var payload = bytes.Repeat([]byte("0123456789"), 1000)
+
+func newTestServer(t *testing.T, faults Faults) (*Server, *httptest.Server) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "releases", "1.0.0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "releases", "1.0.0", "app.zip"), payload, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "darwin-arm64.json"), []byte(`{"version":"1.0.0"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := New(dir, faults)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return srv, ts
+}
+
+func get(t *testing.T, url string, header http.Header) (*http.Response, []byte, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp, body, err
+}
+
+func TestRangeAndETag(t *testing.T) {
+	_, ts := newTestServer(t, Faults{})
+
+	resp, body, err := get(t, ts.URL+"/releases/1.0.0/app.zip", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(body, payload) {
+		t.Fatal("el cuerpo no coincide con el archivo")
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("falta ETag")
+	}
+
+	resp, body, err = get(t, ts.URL+"/releases/1.0.0/app.zip", http.Header{"Range": {"bytes=100-199"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(body, payload[100:200]) {
+		t.Errorf("Range: status %d, %d bytes", resp.StatusCode, len(body))
+	}
+
+	resp, _, err = get(t, ts.URL+"/releases/1.0.0/app.zip", http.Header{"If-None-Match": {etag}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("If-None-Match: status %d, want 304", resp.StatusCode)
+	}
+}
+
+func TestFailFirstOnlyAffectsPattern(t *testing.T) {
+	srv, ts := newTestServer(t, Faults{Pattern: "*.zip", FailFirst: 2})
+
+	for i, want := range []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusOK} {
+		resp, _, err := get(t, ts.URL+"/releases/1.0.0/app.zip", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("petición %d: status %d, want %d", i+1, resp.StatusCode, want)
+		}
+	}
+
+	resp, _, err := get(t, ts.URL+"/darwin-arm64.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("manifiesto: status %d, want 200", resp.StatusCode)
+	}
+	if got := srv.Requests("/releases/1.0.0/app.zip"); got != 3 {
+		t.Errorf("Requests = %d, want 3", got)
+	}
+}
+
+func TestTruncateAfter(t *testing.T) {
+	_, ts := newTestServer(t, Faults{TruncateAfter: 1000})
+
+	resp, body, err := get(t, ts.URL+"/releases/1.0.0/app.zip", nil)
+	if err == nil {
+		t.Fatalf("se esperaba un cuerpo truncado, se leyeron %d bytes", len(body))
+	}
+	if resp.ContentLength != int64(len(payload)) {
+		t.Errorf("Content-Length = %d, want %d", resp.ContentLength, len(payload))
+	}
+	if len(body) > 1000 {
+		t.Errorf("se leyeron %d bytes, want <= 1000", len(body))
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	_, ts := newTestServer(t, Faults{Corrupt: true})
+
+	_, body, err := get(t, ts.URL+"/releases/1.0.0/app.zip", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != len(payload) || bytes.Equal(body, payload) {
+		t.Error("el cuerpo debería tener el mismo tamaño pero contenido distinto")
+	}
+}
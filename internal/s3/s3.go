@@ -0,0 +1,333 @@
+// Package s3 implementa un cliente mínimo para almacenamiento compatible con
+// S3 (AWS S3, MinIO, Cloudflare R2) firmado con AWS Signature Version 4
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound indica que el objeto no existe en el bucket
+var ErrNotFound = errors.New("objeto no encontrado")
+
+// Client es un cliente S3 con direccionamiento path-style
+// ({endpoint}/{bucket}/{key}), soportado por AWS, MinIO y R2
+type Client struct {
+	// Endpoint es la URL base del servicio (ej: https://s3.us-east-1.amazonaws.com)
+	Endpoint string
+
+	// Region es la región usada en la firma (R2 usa "auto")
+	Region string
+
+	// Bucket es el bucket de destino
+	Bucket string
+
+	// AccessKeyID, SecretAccessKey y SessionToken son las credenciales
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// HTTPClient permite inyectar un cliente HTTP (default: http.DefaultClient)
+	HTTPClient *http.Client
+
+	// now permite fijar el reloj en tests
+	now func() time.Time
+}
+
+// PutOptions son los headers opcionales de un PutObject
+type PutOptions struct {
+	ContentType  string
+	CacheControl string
+	// Metadata se envía como headers x-amz-meta-{clave}
+	Metadata map[string]string
+}
+
+// ObjectInfo describe un objeto existente
+type ObjectInfo struct {
+	Size         int64
+	ETag         string
+	CacheControl string
+	Metadata     map[string]string
+}
+
+// NewClientFromEnv crea un cliente con las credenciales estándar de AWS
+// (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY y AWS_SESSION_TOKEN)
+func NewClientFromEnv(endpoint, region, bucket string) (*Client, error) {
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+
+	client := &Client{
+		Endpoint:        strings.TrimSuffix(endpoint, "/"),
+		Region:          region,
+		Bucket:          bucket,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if client.AccessKeyID == "" || client.SecretAccessKey == "" {
+		return nil, fmt.Errorf("credenciales S3 no definidas (AWS_ACCESS_KEY_ID y AWS_SECRET_ACCESS_KEY)")
+	}
+	return client, nil
+}
+
+// PutFile sube un archivo local. El SHA-256 del contenido viaja firmado en
+// x-amz-content-sha256 y el MD5 en Content-MD5, de modo que el servidor
+// rechaza el objeto si llega alterado
+func (c *Client) PutFile(key, localPath string, opts PutOptions) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("error abriendo %s: %w", localPath, err)
+	}
+	defer file.Close()
+
+	sha := sha256.New()
+	sum := md5.New()
+	size, err := io.Copy(io.MultiWriter(sha, sum), file)
+	if err != nil {
+		return fmt.Errorf("error leyendo %s: %w", localPath, err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error leyendo %s: %w", localPath, err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, c.objectURL(key), file)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum.Sum(nil)))
+	if opts.ContentType != "" {
+		req.Header.Set("Content-Type", opts.ContentType)
+	}
+	if opts.CacheControl != "" {
+		req.Header.Set("Cache-Control", opts.CacheControl)
+	}
+	for name, value := range opts.Metadata {
+		req.Header.Set("X-Amz-Meta-"+name, value)
+	}
+
+	resp, err := c.do(req, hex.EncodeToString(sha.Sum(nil)))
+	if err != nil {
+		return fmt.Errorf("error subiendo %s: %w", key, err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// HeadObject obtiene los metadatos de un objeto
+func (c *Client) HeadObject(key string) (*ObjectInfo, error) {
+	req, err := http.NewRequest(http.MethodHead, c.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando %s: %w", key, err)
+	}
+	resp.Body.Close()
+
+	info := &ObjectInfo{
+		Size:         resp.ContentLength,
+		ETag:         strings.Trim(resp.Header.Get("ETag"), `"`),
+		CacheControl: resp.Header.Get("Cache-Control"),
+		Metadata:     make(map[string]string),
+	}
+	if length := resp.Header.Get("Content-Length"); length != "" {
+		if size, err := strconv.ParseInt(length, 10, 64); err == nil {
+			info.Size = size
+		}
+	}
+	for name, values := range resp.Header {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") && len(values) > 0 {
+			info.Metadata[strings.ToLower(strings.TrimPrefix(strings.ToLower(name), "x-amz-meta-"))] = values[0]
+		}
+	}
+
+	return info, nil
+}
+
+// GetObject descarga el contenido de un objeto. El llamador debe cerrarlo
+func (c *Client) GetObject(key string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, c.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, fmt.Errorf("error descargando %s: %w", key, err)
+	}
+	return resp.Body, nil
+}
+
+// do firma y ejecuta la petición, convirtiendo las respuestas no 2xx en error
+func (c *Client) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	c.sign(req, payloadHash)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("error HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
+
+// objectURL arma la URL path-style del objeto
+func (c *Client) objectURL(key string) string {
+	return c.Endpoint + "/" + c.Bucket + "/" + escapePath(strings.TrimPrefix(key, "/"))
+}
+
+// emptyPayloadHash es el SHA-256 de un cuerpo vacío
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign agrega los headers de AWS Signature Version 4
+// (https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html)
+func (c *Client) sign(req *http.Request, payloadHash string) {
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	canonicalHeaders, signedHeaders := canonicalizeHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), date)
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalizeHeaders firma host, Content-*, Cache-Control y todos los x-amz-*
+func canonicalizeHeaders(req *http.Request) (string, string) {
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "authorization" {
+			continue
+		}
+		if strings.HasPrefix(lower, "x-amz-") || strings.HasPrefix(lower, "content-") || lower == "cache-control" || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return canonical.String(), strings.Join(names, ";")
+}
+
+// canonicalQuery ordena y codifica los parámetros de la query
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath codifica cada segmento de la key según las reglas de SigV4
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// escape aplica URI encoding de SigV4 (solo A-Z a-z 0-9 - _ . ~ sin codificar)
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
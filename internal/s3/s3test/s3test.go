@@ -0,0 +1,112 @@
+// Package s3test provee un servidor S3 en memoria para tests, al estilo de
+// net/http/httptest. Implementa PUT, HEAD y GET path-style ({bucket}/{key}) y
+// valida el payload contra x-amz-content-sha256 y Content-MD5
+package s3test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Object es un objeto almacenado
+type Object struct {
+	Data   []byte
+	Header http.Header
+}
+
+// Server es un servidor S3 en memoria
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string]*Object
+	puts    []string
+}
+
+// NewServer inicia un servidor S3 en memoria. Debe cerrarse con Close
+func NewServer() *Server {
+	s := &Server{objects: make(map[string]*Object)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Object retorna el objeto bucket/key, o nil si no existe
+func (s *Server) Object(bucket, key string) *Object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.objects[bucket+"/"+key]
+}
+
+// Puts retorna las keys ({bucket}/{key}) subidas, en orden de llegada
+func (s *Server) Puts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.puts...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/")
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sha := sha256.Sum256(data)
+		if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sha[:]) {
+			http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+			return
+		}
+		sum := md5.Sum(data)
+		if md5Header := r.Header.Get("Content-MD5"); md5Header != "" && md5Header != base64.StdEncoding.EncodeToString(sum[:]) {
+			http.Error(w, "BadDigest", http.StatusBadRequest)
+			return
+		}
+
+		header := http.Header{}
+		for key, values := range r.Header {
+			if key == "Content-Type" || key == "Cache-Control" || strings.HasPrefix(key, "X-Amz-Meta-") {
+				header[key] = values
+			}
+		}
+		header.Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+
+		s.mu.Lock()
+		s.objects[name] = &Object{Data: data, Header: header}
+		s.puts = append(s.puts, name)
+		s.mu.Unlock()
+
+		w.Header().Set("ETag", header.Get("ETag"))
+	case http.MethodHead, http.MethodGet:
+		s.mu.Lock()
+		object := s.objects[name]
+		s.mu.Unlock()
+		if object == nil {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		for key, values := range object.Header {
+			w.Header()[key] = values
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.Data)))
+		if r.Method == http.MethodGet {
+			w.Write(object.Data)
+		}
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
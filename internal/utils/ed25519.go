@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"crypto/subtle"
+	"fmt"
+	"hash"
+
+	"filippo.io/edwards25519"
+)
+
+// Ed25519Verifier valida una firma Ed25519 (RFC 8032, sin prehash, como la
+// sparkle:edSignature de Sparkle) de un contenido que se recibe por partes, sin
+// mantenerlo en memoria. El mensaje solo interviene en SHA-512(R || A || M),
+// que se calcula a medida que se escribe; el resto es la misma verificación
+// que crypto/ed25519.Verify
+type Ed25519Verifier struct {
+	publicKey *edwards25519.Point
+	r         []byte
+	s         *edwards25519.Scalar
+	digest    hash.Hash
+}
+
+// NewEd25519Verifier prepara la verificación de signature con publicKey.
+// Retorna error si la clave no es un punto válido o la firma no es canónica
+func NewEd25519Verifier(publicKey ed25519.PublicKey, signature []byte) (*Ed25519Verifier, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("clave pública Ed25519 inválida: %d bytes", len(publicKey))
+	}
+	if len(signature) != ed25519.SignatureSize || signature[63]&224 != 0 {
+		return nil, fmt.Errorf("firma Ed25519 inválida")
+	}
+
+	point, err := new(edwards25519.Point).SetBytes(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("clave pública Ed25519 inválida: %w", err)
+	}
+	s, err := edwards25519.NewScalar().SetCanonicalBytes(signature[32:])
+	if err != nil {
+		return nil, fmt.Errorf("firma Ed25519 inválida: %w", err)
+	}
+
+	digest := sha512.New()
+	digest.Write(signature[:32])
+	digest.Write(publicKey)
+
+	return &Ed25519Verifier{
+		publicKey: point,
+		r:         append([]byte(nil), signature[:32]...),
+		s:         s,
+		digest:    digest,
+	}, nil
+}
+
+// Write agrega p al mensaje firmado
+func (v *Ed25519Verifier) Write(p []byte) (int, error) {
+	return v.digest.Write(p)
+}
+
+// Verify indica si la firma corresponde al mensaje escrito hasta ahora:
+// R == [S]B - [k]A con k = SHA-512(R || A || M)
+func (v *Ed25519Verifier) Verify() bool {
+	k, err := edwards25519.NewScalar().SetUniformBytes(v.digest.Sum(nil))
+	if err != nil {
+		return false
+	}
+
+	minusA := new(edwards25519.Point).Negate(v.publicKey)
+	r := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(k, minusA, v.s)
+	return subtle.ConstantTimeCompare(v.r, r.Bytes()) == 1
+}
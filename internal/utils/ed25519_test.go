@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+// writeChunks escribe data en el verificador en partes de tamaño chunk
+func writeChunks(v *Ed25519Verifier, data []byte, chunk int) {
+	for len(data) > 0 {
+		n := chunk
+		if n > len(data) {
+			n = len(data)
+		}
+		v.Write(data[:n])
+		data = data[n:]
+	}
+}
+
+func TestEd25519VerifierRFC8032(t *testing.T) {
+	// RFC 8032, sección 7.1, TEST 1 (mensaje vacío) y TEST 2 (un byte)
+	tests := []struct {
+		publicKey string
+		message   string
+		signature string
+	}{
+		{
+			"d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+			"",
+			"e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b",
+		},
+		{
+			"3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c",
+			"72",
+			"92a009a9f0d4cab8720e820b5f642540a2b27b5416503f8fb3762223ebdb69da085ac1e43e15996e458f3613d0f11d8c387b2eaeb4302aeeb00d291612bb0c00",
+		},
+	}
+
+	for _, tt := range tests {
+		publicKey, _ := hex.DecodeString(tt.publicKey)
+		message, _ := hex.DecodeString(tt.message)
+		signature, _ := hex.DecodeString(tt.signature)
+
+		v, err := NewEd25519Verifier(publicKey, signature)
+		if err != nil {
+			t.Fatalf("NewEd25519Verifier: %v", err)
+		}
+		v.Write(message)
+		if !v.Verify() {
+			t.Errorf("la firma del vector %s debería ser válida", tt.publicKey[:8])
+		}
+	}
+}
+
+func TestEd25519VerifierMatchesStdlib(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := make([]byte, 3<<20+17)
+	if _, err := rand.Read(message); err != nil {
+		t.Fatal(err)
+	}
+	signature := ed25519.Sign(privateKey, message)
+	tampered := bytes.Clone(message)
+	tampered[len(tampered)/2] ^= 1
+
+	tests := []struct {
+		name      string
+		publicKey ed25519.PublicKey
+		message   []byte
+		want      bool
+	}{
+		{"firma válida", publicKey, message, true},
+		{"contenido alterado", publicKey, tampered, false},
+		{"contenido truncado", publicKey, message[:len(message)-1], false},
+		{"otra clave", otherKey, message, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if want := ed25519.Verify(tt.publicKey, tt.message, signature); want != tt.want {
+				t.Fatalf("ed25519.Verify = %t, want %t", want, tt.want)
+			}
+			for _, chunk := range []int{1 << 20, 32 << 10, 4093} {
+				v, err := NewEd25519Verifier(tt.publicKey, signature)
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeChunks(v, tt.message, chunk)
+				if got := v.Verify(); got != tt.want {
+					t.Errorf("Verify (partes de %d bytes) = %t, want %t", chunk, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewEd25519VerifierRejectsMalformed(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := ed25519.Sign(privateKey, []byte("payload"))
+
+	// S + L: misma firma módulo L, pero no canónica (maleable)
+	nonCanonical := bytes.Clone(signature)
+	order := []byte{0xed, 0xd3, 0xf5, 0x5c, 0x1a, 0x63, 0x12, 0x58, 0xd6, 0x9c, 0xf7, 0xa2, 0xde, 0xf9, 0xde, 0x14,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x10}
+	carry := 0
+	for i := 0; i < 32; i++ {
+		sum := int(nonCanonical[32+i]) + int(order[i]) + carry
+		nonCanonical[32+i] = byte(sum)
+		carry = sum >> 8
+	}
+
+	tests := []struct {
+		name      string
+		publicKey []byte
+		signature []byte
+	}{
+		{"clave corta", publicKey[:31], signature},
+		{"firma corta", publicKey, signature[:63]},
+		{"S no canónico", publicKey, nonCanonical},
+		// y = 2 no tiene x en la curva
+		{"clave que no es un punto", append([]byte{2}, make([]byte, 31)...), signature},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewEd25519Verifier(tt.publicKey, tt.signature); err == nil {
+			t.Errorf("%s: NewEd25519Verifier debería fallar", tt.name)
+		}
+	}
+}
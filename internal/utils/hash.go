@@ -2,34 +2,167 @@ package utils
 
 import (
 	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"lukechampine.com/blake3"
 )
 
-// CalculateSHA256 calcula el hash SHA-256 de un archivo
-func CalculateSHA256(filePath string) (string, error) {
+// Algoritmos de hash soportados por defecto
+const (
+	HashSHA256 = "sha256"
+	HashSHA512 = "sha512"
+	HashBLAKE3 = "blake3"
+)
+
+// hashAlgorithm describe un algoritmo registrado
+type hashAlgorithm struct {
+	// strength ordena los algoritmos: el verificador exige el más fuerte disponible
+	strength int
+	new      func() hash.Hash
+}
+
+var (
+	hashMu         sync.RWMutex
+	hashAlgorithms = map[string]hashAlgorithm{
+		HashSHA256: {strength: 10, new: sha256.New},
+		HashSHA512: {strength: 20, new: sha512.New},
+		HashBLAKE3: {strength: 30, new: func() hash.Hash { return blake3.New(32, nil) }},
+	}
+)
+
+// RegisterHashAlgorithm registra (o reemplaza) un algoritmo de hash.
+// strength define la prioridad al elegir el algoritmo más fuerte de un manifiesto
+func RegisterHashAlgorithm(name string, strength int, newHash func() hash.Hash) {
+	hashMu.Lock()
+	defer hashMu.Unlock()
+	hashAlgorithms[strings.ToLower(name)] = hashAlgorithm{strength: strength, new: newHash}
+}
+
+// NewHasher crea un hash.Hash para el algoritmo indicado
+func NewHasher(algorithm string) (hash.Hash, error) {
+	hashMu.RLock()
+	defer hashMu.RUnlock()
+
+	alg, ok := hashAlgorithms[strings.ToLower(algorithm)]
+	if !ok {
+		return nil, fmt.Errorf("algoritmo de hash no soportado: %s", algorithm)
+	}
+	return alg.new(), nil
+}
+
+// SupportedHashAlgorithms retorna los algoritmos registrados, del más fuerte al
+// más débil. A igual strength se ordenan por nombre, para que la elección no
+// dependa del orden del mapa
+func SupportedHashAlgorithms() []string {
+	hashMu.RLock()
+	defer hashMu.RUnlock()
+
+	names := make([]string, 0, len(hashAlgorithms))
+	for name := range hashAlgorithms {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := hashAlgorithms[names[i]].strength, hashAlgorithms[names[j]].strength
+		if a != b {
+			return a > b
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// StrongestDigest elige, de un mapa algoritmo -> digest, el algoritmo soportado
+// más fuerte. Los algoritmos desconocidos se ignoran
+func StrongestDigest(digests map[string]string) (string, string, error) {
+	// Recorrer las claves ordenadas: "SHA256" y "sha256" son el mismo algoritmo
+	keys := make([]string, 0, len(digests))
+	for algorithm := range digests {
+		keys = append(keys, algorithm)
+	}
+	sort.Strings(keys)
+
+	for _, name := range SupportedHashAlgorithms() {
+		for _, algorithm := range keys {
+			if strings.ToLower(algorithm) == name && digests[algorithm] != "" {
+				return name, digests[algorithm], nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("ningún algoritmo de hash soportado en el manifiesto")
+}
+
+// CalculateDigests calcula en una sola lectura los digests de un archivo para
+// los algoritmos indicados
+func CalculateDigests(filePath string, algorithms ...string) (map[string]string, error) {
+	hashers := make(map[string]hash.Hash, len(algorithms))
+	writers := make([]io.Writer, 0, len(algorithms))
+	for _, algorithm := range algorithms {
+		h, err := NewHasher(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		hashers[strings.ToLower(algorithm)] = h
+		writers = append(writers, h)
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
-		return "", fmt.Errorf("error abriendo archivo para hash: %w", err)
+		return nil, fmt.Errorf("error abriendo archivo para hash: %w", err)
 	}
 	defer file.Close()
 
-	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return "", fmt.Errorf("error calculando hash: %w", err)
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return nil, fmt.Errorf("error calculando hash: %w", err)
 	}
 
-	return hex.EncodeToString(hash.Sum(nil)), nil
+	digests := make(map[string]string, len(hashers))
+	for algorithm, h := range hashers {
+		digests[algorithm] = hex.EncodeToString(h.Sum(nil))
+	}
+	return digests, nil
 }
 
-// VerifyChecksum verifica que el checksum de un archivo coincida con el esperado
+// CalculateSHA256 calcula el hash SHA-256 de un archivo
+func CalculateSHA256(filePath string) (string, error) {
+	digests, err := CalculateDigests(filePath, HashSHA256)
+	if err != nil {
+		return "", err
+	}
+	return digests[HashSHA256], nil
+}
+
+// DigestsEqual compara dos digests hexadecimales en tiempo constante y sin
+// distinguir mayúsculas de minúsculas
+func DigestsEqual(actual, expected string) bool {
+	a, errA := hex.DecodeString(strings.TrimSpace(actual))
+	b, errB := hex.DecodeString(strings.TrimSpace(expected))
+	if errA != nil || errB != nil || len(a) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// VerifyChecksum verifica que el checksum SHA-256 de un archivo coincida con el esperado
 func VerifyChecksum(filePath, expectedChecksum string) (bool, error) {
-	actualChecksum, err := CalculateSHA256(filePath)
+	return VerifyDigest(filePath, HashSHA256, expectedChecksum)
+}
+
+// VerifyDigest verifica que el digest de un archivo coincida con el esperado
+// para el algoritmo indicado
+func VerifyDigest(filePath, algorithm, expectedDigest string) (bool, error) {
+	digests, err := CalculateDigests(filePath, algorithm)
 	if err != nil {
 		return false, err
 	}
 
-	return actualChecksum == expectedChecksum, nil
+	return DigestsEqual(digests[strings.ToLower(algorithm)], expectedDigest), nil
 }
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"crypto/md5"
+	"hash"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// registerTestHash registra un algoritmo durante el test y lo elimina al terminar
+func registerTestHash(t *testing.T, name string, strength int, newHash func() hash.Hash) {
+	t.Helper()
+	RegisterHashAlgorithm(name, strength, newHash)
+	t.Cleanup(func() {
+		hashMu.Lock()
+		defer hashMu.Unlock()
+		delete(hashAlgorithms, strings.ToLower(name))
+	})
+}
+
+// patternInput genera la entrada de los vectores oficiales de BLAKE3: bytes
+// 0, 1, ..., 250, 0, 1, ...
+func patternInput(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+func TestCalculateDigestsVectors(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     []byte
+		algorithm string
+		want      string
+	}{
+		{"sha256 abc", []byte("abc"), HashSHA256, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"sha512 abc", []byte("abc"), HashSHA512, "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f"},
+		{"sha256 vacío", nil, HashSHA256, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+
+		// Vectores oficiales de BLAKE3 (test_vectors.json, hash de 32 bytes)
+		{"blake3 vacío", patternInput(0), HashBLAKE3, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+		{"blake3 1 byte", patternInput(1), HashBLAKE3, "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213"},
+		{"blake3 1023 bytes", patternInput(1023), HashBLAKE3, "10108970eeda3eb932baac1428c7a2163b0e924c9a9e25b35bba72b28f70bd11"},
+		{"blake3 1024 bytes", patternInput(1024), HashBLAKE3, "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7"},
+		{"blake3 1025 bytes", patternInput(1025), HashBLAKE3, "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444"},
+		{"blake3 8192 bytes", patternInput(8192), HashBLAKE3, "aae792484c8efe4f19e2ca7d371d8c467ffb10748d8a5a1ae579948f718a2a63"},
+		{"blake3 abc", []byte("abc"), HashBLAKE3, "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "payload")
+			if err := os.WriteFile(path, tt.input, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// Todos los algoritmos en una sola lectura, con el nombre en mayúsculas
+			digests, err := CalculateDigests(path, strings.ToUpper(tt.algorithm), HashSHA256, HashSHA512, HashBLAKE3)
+			if err != nil {
+				t.Fatalf("CalculateDigests: %v", err)
+			}
+			if got := digests[tt.algorithm]; got != tt.want {
+				t.Errorf("%s = %s, want %s", tt.algorithm, got, tt.want)
+			}
+
+			ok, err := VerifyDigest(path, tt.algorithm, strings.ToUpper(tt.want))
+			if err != nil || !ok {
+				t.Errorf("VerifyDigest = %t, %v", ok, err)
+			}
+		})
+	}
+
+	if _, err := CalculateDigests(filepath.Join(t.TempDir(), "payload"), "md4"); err == nil {
+		t.Error("CalculateDigests debería rechazar un algoritmo no registrado")
+	}
+}
+
+func TestStrongestDigest(t *testing.T) {
+	tests := []struct {
+		name          string
+		digests       map[string]string
+		wantAlgorithm string
+		wantDigest    string
+		wantErr       bool
+	}{
+		{"el más fuerte", map[string]string{"sha256": "a", "sha512": "b", "blake3": "c"}, HashBLAKE3, "c", false},
+		{"sin blake3", map[string]string{"sha256": "a", "sha512": "b"}, HashSHA512, "b", false},
+		{"mayúsculas", map[string]string{"SHA512": "b", "sha256": "a"}, HashSHA512, "b", false},
+		{"ignora desconocidos", map[string]string{"md4": "x", "sha256": "a"}, HashSHA256, "a", false},
+		{"ignora digests vacíos", map[string]string{"blake3": "", "sha256": "a"}, HashSHA256, "a", false},
+		{"mismo algoritmo con distinta capitalización", map[string]string{"SHA256": "A", "Sha256": "B", "sha256": "C"}, HashSHA256, "A", false},
+		{"ninguno soportado", map[string]string{"md4": "x"}, "", "", true},
+		{"vacío", nil, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			algorithm, digest, err := StrongestDigest(tt.digests)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StrongestDigest error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if algorithm != tt.wantAlgorithm || digest != tt.wantDigest {
+				t.Errorf("StrongestDigest = %s, %s, want %s, %s", algorithm, digest, tt.wantAlgorithm, tt.wantDigest)
+			}
+		})
+	}
+}
+
+func TestRegisterHashAlgorithm(t *testing.T) {
+	registerTestHash(t, "MD5-Test", 40, md5.New)
+
+	if got := SupportedHashAlgorithms()[0]; got != "md5-test" {
+		t.Errorf("algoritmo más fuerte = %s, want md5-test", got)
+	}
+	algorithm, digest, err := StrongestDigest(map[string]string{"sha256": "a", "md5-test": "m"})
+	if err != nil || algorithm != "md5-test" || digest != "m" {
+		t.Errorf("StrongestDigest = %s, %s, %v", algorithm, digest, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "payload")
+	if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	digests, err := CalculateDigests(path, "md5-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "900150983cd24fb0d6963f7d28e17f72"; digests["md5-test"] != want {
+		t.Errorf("md5-test = %s, want %s", digests["md5-test"], want)
+	}
+}
+
+func TestSupportedHashAlgorithmsTies(t *testing.T) {
+	// Tres algoritmos con la misma strength que blake3: el orden es por nombre
+	registerTestHash(t, "zeta", 30, md5.New)
+	registerTestHash(t, "alfa", 30, md5.New)
+	registerTestHash(t, "beta", 30, md5.New)
+
+	want := []string{"alfa", "beta", HashBLAKE3, "zeta", HashSHA512, HashSHA256}
+	for i := 0; i < 20; i++ {
+		if got := SupportedHashAlgorithms(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("SupportedHashAlgorithms = %v, want %v", got, want)
+		}
+	}
+
+	digests := map[string]string{"zeta": "z", "blake3": "b", "beta": "e"}
+	for i := 0; i < 20; i++ {
+		algorithm, _, err := StrongestDigest(digests)
+		if err != nil || algorithm != "beta" {
+			t.Fatalf("StrongestDigest = %s, %v, want beta", algorithm, err)
+		}
+	}
+}
+
+func TestDigestsEqual(t *testing.T) {
+	tests := []struct {
+		name     string
+		actual   string
+		expected string
+		want     bool
+	}{
+		{"iguales", "abcdef01", "abcdef01", true},
+		{"mayúsculas", "abcdef01", "ABCDEF01", true},
+		{"espacios", "abcdef01", " abcdef01\n", true},
+		{"distintos", "abcdef01", "abcdef02", false},
+		{"distinta longitud", "abcdef01", "abcdef", false},
+		{"hex inválido", "abcdef0g", "abcdef0g", false},
+		{"longitud impar", "abc", "abc", false},
+		{"vacíos", "", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := DigestsEqual(tt.actual, tt.expected); got != tt.want {
+			t.Errorf("%s: DigestsEqual(%q, %q) = %t, want %t", tt.name, tt.actual, tt.expected, got, tt.want)
+		}
+	}
+}
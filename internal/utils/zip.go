@@ -2,15 +2,101 @@ package utils
 
 import (
 	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
+// ZipOptions controla cómo se genera el archivo ZIP
+type ZipOptions struct {
+	// Reproducible genera un ZIP byte a byte idéntico para el mismo contenido:
+	// entradas ordenadas, timestamps fijos, permisos normalizados y sin
+	// extra fields dependientes del sistema de archivos
+	Reproducible bool
+
+	// ModTime es el timestamp que se asigna a todas las entradas en modo
+	// reproducible. Si es cero se usa DefaultZipModTime
+	ModTime time.Time
+
+	// Jobs es la cantidad de archivos que se comprimen en paralelo.
+	// Si es 0 o negativo se usa runtime.NumCPU()
+	Jobs int
+
+	// MaxBufferedBytes acota el tamaño total de las entradas comprimidas que
+	// esperan su turno para escribirse (en memoria o en archivos temporales).
+	// Si es 0 o negativo se usa DefaultZipMaxBufferedBytes
+	MaxBufferedBytes int64
+}
+
+// DefaultZipModTime es el timestamp usado en modo reproducible cuando no se
+// especifica uno (el mínimo representable en formato MS-DOS)
+var DefaultZipModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// DefaultZipMaxBufferedBytes es el tamaño máximo de las entradas en vuelo
+// cuando no se especifica MaxBufferedBytes
+const DefaultZipMaxBufferedBytes = 128 << 20
+
+// storedExtensions son extensiones de archivos que ya vienen comprimidos;
+// se guardan sin compresión porque Deflate no reduce su tamaño
+var storedExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".heic": true, ".webp": true,
+	".car": true, ".zip": true, ".gz": true, ".tgz": true, ".bz2": true, ".xz": true,
+	".7z": true, ".dmg": true, ".pkg": true, ".jar": true,
+	".mp3": true, ".m4a": true, ".mp4": true, ".mov": true,
+}
+
+// zipSpillThreshold es el tamaño a partir del cual un archivo comprimido se
+// guarda en un archivo temporal en lugar de mantenerse en memoria
+const zipSpillThreshold = 32 << 20
+
+// zipEntry representa un archivo o directorio a incluir en el ZIP
+type zipEntry struct {
+	path string
+	name string
+	info os.FileInfo
+}
+
+// compressedEntry es el resultado de comprimir una entrada, listo para
+// escribirse en el ZIP con CreateRaw
+type compressedEntry struct {
+	header *zip.FileHeader
+	data   []byte
+	spill  *os.File
+	err    error
+}
+
 // ZipDirectory comprime un directorio (como un .app bundle) en un archivo ZIP
 func ZipDirectory(sourcePath, destPath string) error {
+	return ZipDirectoryWithOptions(sourcePath, destPath, ZipOptions{})
+}
+
+// ZipDirectoryWithOptions comprime un directorio aplicando las opciones indicadas.
+// Los archivos se comprimen en paralelo con un pool de workers y se escriben en
+// el ZIP en orden, por lo que el resultado no depende de la cantidad de workers
+func ZipDirectoryWithOptions(sourcePath, destPath string, opts ZipOptions) error {
+	// Recolectar entradas antes de escribir para poder ordenarlas
+	entries, err := collectZipEntries(sourcePath)
+	if err != nil {
+		return fmt.Errorf("error recorriendo directorio: %w", err)
+	}
+
+	if opts.Reproducible {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].name < entries[j].name
+		})
+	}
+
 	// Crear archivo ZIP
 	zipFile, err := os.Create(destPath)
 	if err != nil {
@@ -19,21 +105,160 @@ func ZipDirectory(sourcePath, destPath string) error {
 	defer zipFile.Close()
 
 	writer := zip.NewWriter(zipFile)
-	defer writer.Close()
 
-	// Obtener el nombre base del directorio para preservar la estructura
-	baseName := filepath.Base(sourcePath)
+	if err := writeZipEntries(writer, entries, opts); err != nil {
+		writer.Close()
+		return err
+	}
 
-	// Recorrer el directorio y agregar archivos
-	err = filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error cerrando zip: %w", err)
+	}
+
+	return nil
+}
+
+// writeZipEntries comprime las entradas con un pool de workers y las escribe
+// en el orden original. El tamaño total de las entradas en vuelo está acotado
+// por MaxBufferedBytes para no cargar el bundle completo en memoria
+func writeZipEntries(writer *zip.Writer, entries []zipEntry, opts ZipOptions) error {
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	maxBuffered := opts.MaxBufferedBytes
+	if maxBuffered <= 0 {
+		maxBuffered = DefaultZipMaxBufferedBytes
+	}
+
+	results := make([]chan compressedEntry, len(entries))
+	for i := range results {
+		results[i] = make(chan compressedEntry, 1)
+	}
+
+	work := make(chan int)
+	budget := newZipBudget(maxBuffered)
+
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results[i] <- compressZipEntry(entries[i], opts)
+			}
+		}()
+	}
+
+	// Productor: reparte las entradas respetando el presupuesto de bytes en vuelo
+	go func() {
+		defer close(work)
+		for i := range entries {
+			if !budget.acquire(entries[i].bufferedSize()) {
+				return
+			}
+			work <- i
+		}
+	}()
+
+	var writeErr error
+	written := 0
+	for i := range entries {
+		result := <-results[i]
+		written++
+
+		writeErr = result.err
+		if writeErr == nil {
+			writeErr = writeCompressedEntry(writer, result)
+		}
+		result.release()
+		budget.release(entries[i].bufferedSize())
+
+		if writeErr != nil {
+			break
+		}
+	}
+
+	// Detener el productor y esperar a los workers antes de liberar lo pendiente.
+	// Los workers siguen consumiendo work hasta que el productor lo cierra
+	budget.close()
+	wg.Wait()
+	for _, ch := range results[written:] {
+		select {
+		case result := <-ch:
+			result.release()
+		default:
 		}
+	}
+
+	return writeErr
+}
+
+// bufferedSize es lo que ocupa la entrada comprimida mientras espera su turno.
+// Se usa el tamaño sin comprimir como cota: Deflate rara vez lo supera
+func (e zipEntry) bufferedSize() int64 {
+	if e.info.IsDir() {
+		return 0
+	}
+	return e.info.Size()
+}
+
+// zipBudget limita la cantidad de bytes en vuelo entre el productor y el
+// escritor del ZIP
+type zipBudget struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int64
+	used   int64
+	closed bool
+}
 
-		// Crear header del archivo
-		header, err := zip.FileInfoHeader(info)
+func newZipBudget(limit int64) *zipBudget {
+	b := &zipBudget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire reserva n bytes y espera mientras no entren en el límite. Una
+// entrada mayor que el límite se admite cuando no queda nada en vuelo.
+// Retorna false si el presupuesto se cerró
+func (b *zipBudget) acquire(n int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for !b.closed && b.used > 0 && b.used+n > b.limit {
+		b.cond.Wait()
+	}
+	if b.closed {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// release devuelve n bytes al presupuesto
+func (b *zipBudget) release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// close despierta al productor para que deje de repartir entradas
+func (b *zipBudget) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// collectZipEntries recorre el directorio y devuelve sus entradas con la ruta
+// relativa que tendrán dentro del ZIP (incluyendo el nombre del directorio raíz)
+func collectZipEntries(sourcePath string) ([]zipEntry, error) {
+	var entries []zipEntry
+
+	err := filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			return fmt.Errorf("error creando header: %w", err)
+			return err
 		}
 
 		// Calcular la ruta relativa dentro del ZIP
@@ -41,55 +266,226 @@ func ZipDirectory(sourcePath, destPath string) error {
 		if err != nil {
 			return fmt.Errorf("error calculando ruta relativa: %w", err)
 		}
-		header.Name = relPath
 
+		name := filepath.ToSlash(relPath)
 		// Si es directorio, agregar slash al final
 		if info.IsDir() {
-			header.Name += "/"
-		} else {
-			// Usar método de compresión Deflate para archivos
-			header.Method = zip.Deflate
+			name += "/"
 		}
 
-		// Preservar permisos de ejecución
-		header.SetMode(info.Mode())
+		entries = append(entries, zipEntry{path: path, name: name, info: info})
+		return nil
+	})
 
-		// Crear entrada en el ZIP
-		entryWriter, err := writer.CreateHeader(header)
-		if err != nil {
-			return fmt.Errorf("error creando entrada zip: %w", err)
-		}
+	return entries, err
+}
 
-		// Si es directorio, no hay contenido que escribir
-		if info.IsDir() {
-			return nil
-		}
+// compressZipEntry lee y comprime una entrada, calculando CRC y tamaños para
+// poder escribirla luego con CreateRaw
+func compressZipEntry(entry zipEntry, opts ZipOptions) compressedEntry {
+	header, err := newZipHeader(entry, opts)
+	if err != nil {
+		return compressedEntry{err: err}
+	}
+
+	// Si es directorio, no hay contenido que comprimir
+	if entry.info.IsDir() {
+		header.Method = zip.Store
+		header.UncompressedSize64 = 0
+		return compressedEntry{header: header}
+	}
+
+	file, err := os.Open(entry.path)
+	if err != nil {
+		return compressedEntry{err: fmt.Errorf("error abriendo archivo: %w", err)}
+	}
+	defer file.Close()
 
-		// Copiar contenido del archivo
-		file, err := os.Open(path)
+	// Usar método de compresión Deflate salvo para archivos ya comprimidos
+	header.Method = zip.Deflate
+	if storedExtensions[strings.ToLower(filepath.Ext(entry.name))] {
+		header.Method = zip.Store
+	}
+
+	result := compressedEntry{header: header}
+	var buf bytes.Buffer
+	var out io.Writer = &buf
+	if entry.info.Size() > zipSpillThreshold {
+		result.spill, err = os.CreateTemp("", "zip-entry-*")
 		if err != nil {
-			return fmt.Errorf("error abriendo archivo: %w", err)
+			return compressedEntry{err: fmt.Errorf("error creando archivo temporal: %w", err)}
 		}
-		defer file.Close()
+		out = result.spill
+	}
 
-		_, err = io.Copy(entryWriter, file)
+	counter := &countingWriter{w: out}
+	crc := crc32.NewIEEE()
+	var dest io.WriteCloser = nopWriteCloser{counter}
+	if header.Method == zip.Deflate {
+		dest, err = flate.NewWriter(counter, flate.DefaultCompression)
 		if err != nil {
-			return fmt.Errorf("error copiando contenido: %w", err)
+			result.release()
+			return compressedEntry{err: fmt.Errorf("error creando compresor: %w", err)}
 		}
+	}
 
-		return nil
-	})
+	size, err := io.Copy(io.MultiWriter(dest, crc), file)
+	if err == nil {
+		err = dest.Close()
+	}
+	if err != nil {
+		result.release()
+		return compressedEntry{err: fmt.Errorf("error copiando contenido: %w", err)}
+	}
+
+	header.CRC32 = crc.Sum32()
+	header.UncompressedSize64 = uint64(size)
+	header.CompressedSize64 = uint64(counter.n)
+	result.data = buf.Bytes()
 
+	return result
+}
+
+// writeCompressedEntry escribe una entrada ya comprimida en el ZIP
+func writeCompressedEntry(writer *zip.Writer, entry compressedEntry) error {
+	entryWriter, err := writer.CreateRaw(entry.header)
 	if err != nil {
-		return fmt.Errorf("error recorriendo directorio: %w", err)
+		return fmt.Errorf("error creando entrada zip: %w", err)
+	}
+
+	if entry.spill != nil {
+		if _, err := entry.spill.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("error leyendo archivo temporal: %w", err)
+		}
+		if _, err := io.Copy(entryWriter, entry.spill); err != nil {
+			return fmt.Errorf("error copiando contenido: %w", err)
+		}
+		return nil
 	}
 
-	// Ignoramos baseName ya que lo usamos en relPath
-	_ = baseName
+	if _, err := entryWriter.Write(entry.data); err != nil {
+		return fmt.Errorf("error copiando contenido: %w", err)
+	}
 
 	return nil
 }
 
+// release libera el archivo temporal de la entrada, si lo hay
+func (e compressedEntry) release() {
+	if e.spill != nil {
+		e.spill.Close()
+		os.Remove(e.spill.Name())
+	}
+}
+
+// newZipHeader construye el header de una entrada según las opciones.
+// Como las entradas se escriben con CreateRaw, aquí se completan los campos
+// que CreateHeader calcularía automáticamente
+func newZipHeader(entry zipEntry, opts ZipOptions) (*zip.FileHeader, error) {
+	header, err := zip.FileInfoHeader(entry.info)
+	if err != nil {
+		return nil, fmt.Errorf("error creando header: %w", err)
+	}
+	header.Name = entry.name
+	header.ReaderVersion = zipVersion20
+	if !isASCII(entry.name) {
+		header.Flags |= zipFlagUTF8
+	}
+
+	if !opts.Reproducible {
+		// Preservar permisos de ejecución y el timestamp con precisión de segundos
+		header.SetMode(entry.info.Mode())
+		header.CreatorVersion |= zipVersion20
+		header.Extra = extendedTimestamp(entry.info.ModTime())
+		return header, nil
+	}
+
+	modTime := opts.ModTime
+	if modTime.IsZero() {
+		modTime = DefaultZipModTime
+	}
+	header.SetModTime(modTime.UTC())
+	header.Extra = nil
+	header.SetMode(normalizeMode(entry.info.Mode()))
+	header.CreatorVersion |= zipVersion20
+
+	return header, nil
+}
+
+const (
+	zipVersion20 = 20
+	zipFlagUTF8  = 0x800
+
+	// zipExtTimeExtraID es el extra field "extended timestamp" (0x5455)
+	zipExtTimeExtraID = 0x5455
+)
+
+// extendedTimestamp genera el extra field con la fecha de modificación Unix,
+// igual al que agrega zip.Writer.CreateHeader
+func extendedTimestamp(modTime time.Time) []byte {
+	extra := make([]byte, 9)
+	binary.LittleEndian.PutUint16(extra[0:], zipExtTimeExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 5)
+	extra[4] = 1 // Flags: ModTime
+	binary.LittleEndian.PutUint32(extra[5:], uint32(modTime.Unix()))
+	return extra
+}
+
+// normalizeMode reduce los permisos a 0755/0644 conservando el tipo de archivo
+// y el bit de ejecución, para que el ZIP no dependa del umask de la máquina
+func normalizeMode(mode os.FileMode) os.FileMode {
+	perm := os.FileMode(0644)
+	if mode.IsDir() || mode&0111 != 0 {
+		perm = 0755
+	}
+	return mode.Type() | perm
+}
+
+// isASCII indica si un nombre puede guardarse sin el flag UTF-8
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// countingWriter cuenta los bytes escritos (tamaño comprimido)
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// nopWriteCloser adapta un io.Writer para entradas sin compresión
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// UncompressedSize retorna el tamaño descomprimido de un ZIP según su
+// directorio central, sin extraerlo
+func UncompressedSize(zipPath string) (uint64, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return 0, fmt.Errorf("error abriendo zip: %w", err)
+	}
+	defer reader.Close()
+
+	var total uint64
+	for _, file := range reader.File {
+		total += file.UncompressedSize64
+	}
+	return total, nil
+}
+
 // UnzipFile descomprime un archivo ZIP en un directorio destino
 func UnzipFile(zipPath, destPath string) error {
 	// Abrir archivo ZIP
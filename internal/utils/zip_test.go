@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// makeBundle genera un bundle .app falso con archivos compresibles y assets
+// ya comprimidos (aleatorios) para medir el empaquetado
+func makeBundle(tb testing.TB, files int, size int) string {
+	tb.Helper()
+
+	appPath := filepath.Join(tb.TempDir(), "Bench.app")
+	resources := filepath.Join(appPath, "Contents", "Resources")
+	if err := os.MkdirAll(resources, 0755); err != nil {
+		tb.Fatal(err)
+	}
+
+	text := bytes.Repeat([]byte("joobpay updater bundle content\n"), size/31+1)[:size]
+	random := make([]byte, size)
+	if _, err := rand.Read(random); err != nil {
+		tb.Fatal(err)
+	}
+
+	for i := 0; i < files; i++ {
+		if err := os.WriteFile(filepath.Join(resources, fmt.Sprintf("data-%03d.bin", i)), text, 0644); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(resources, fmt.Sprintf("image-%03d.png", i)), random, 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	return appPath
+}
+
+func TestZipDirectoryReproducibleIndependentOfJobs(t *testing.T) {
+	appPath := makeBundle(t, 8, 64<<10)
+	outDir := t.TempDir()
+
+	var previous []byte
+	for _, jobs := range []int{1, 4} {
+		zipPath := filepath.Join(outDir, fmt.Sprintf("jobs-%d.zip", jobs))
+		if err := ZipDirectoryWithOptions(appPath, zipPath, ZipOptions{Reproducible: true, Jobs: jobs}); err != nil {
+			t.Fatalf("ZipDirectoryWithOptions(jobs=%d): %v", jobs, err)
+		}
+
+		data, err := os.ReadFile(zipPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if previous != nil && !bytes.Equal(previous, data) {
+			t.Fatalf("el ZIP con jobs=%d difiere del generado con jobs=1", jobs)
+		}
+		previous = data
+	}
+
+	extractPath := filepath.Join(outDir, "extracted")
+	if err := UnzipFile(filepath.Join(outDir, "jobs-4.zip"), extractPath); err != nil {
+		t.Fatalf("UnzipFile: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(extractPath, "Bench.app", "Contents", "Resources", "data-000.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 64<<10 {
+		t.Fatalf("tamaño extraído = %d, esperado %d", len(got), 64<<10)
+	}
+}
+
+// zipBytes genera el ZIP de appPath con las opciones indicadas y retorna su contenido
+func zipBytes(t *testing.T, appPath string, opts ZipOptions) []byte {
+	t.Helper()
+	zipPath := filepath.Join(t.TempDir(), "out.zip")
+	if err := ZipDirectoryWithOptions(appPath, zipPath, opts); err != nil {
+		t.Fatalf("ZipDirectoryWithOptions: %v", err)
+	}
+	data, err := os.ReadFile(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// touchTree asigna modTime a todos los archivos y directorios del árbol
+func touchTree(t *testing.T, root string, modTime time.Time) {
+	t.Helper()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, modTime, modTime)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestZipDirectoryReproducibleIgnoresMetadata(t *testing.T) {
+	appPath := makeBundle(t, 4, 16<<10)
+	executable := filepath.Join(appPath, "Contents", "MacOS", "Bench")
+	if err := os.MkdirAll(filepath.Dir(executable), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(executable, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	opts := ZipOptions{Reproducible: true, ModTime: time.Unix(1700000000, 0)}
+
+	touchTree(t, appPath, time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC))
+	want := zipBytes(t, appPath, opts)
+	plain := zipBytes(t, appPath, ZipOptions{})
+
+	tests := []struct {
+		name   string
+		mutate func(t *testing.T)
+	}{
+		{"mtimes distintos", func(t *testing.T) {
+			touchTree(t, appPath, time.Now())
+		}},
+		{"permisos según el umask", func(t *testing.T) {
+			// 0600/0700/0750 se normalizan a 0644/0755 conservando el bit de ejecución
+			for path, mode := range map[string]os.FileMode{
+				filepath.Join(appPath, "Contents", "Resources", "data-000.bin"): 0600,
+				executable: 0700,
+				filepath.Join(appPath, "Contents", "Resources"): 0750,
+			} {
+				if err := os.Chmod(path, mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mutate(t)
+			if got := zipBytes(t, appPath, opts); !bytes.Equal(got, want) {
+				t.Error("el ZIP reproducible cambió")
+			}
+		})
+	}
+
+	// Sin modo reproducible los mtimes sí cambian el ZIP
+	if bytes.Equal(zipBytes(t, appPath, ZipOptions{}), plain) {
+		t.Error("el ZIP no reproducible debería reflejar los mtimes")
+	}
+
+	// El timestamp de las entradas es el de ZipOptions.ModTime
+	other := opts
+	other.ModTime = opts.ModTime.Add(time.Hour)
+	if bytes.Equal(zipBytes(t, appPath, other), want) {
+		t.Error("ZipOptions.ModTime debería cambiar el ZIP")
+	}
+	reader, err := zip.NewReader(bytes.NewReader(want), int64(len(want)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range reader.File {
+		if !file.Modified.Equal(opts.ModTime) {
+			t.Fatalf("%s: Modified = %v, want %v", file.Name, file.Modified, opts.ModTime)
+		}
+		if file.Name == "Bench.app/Contents/MacOS/Bench" && file.Mode().Perm() != 0755 {
+			t.Errorf("%s: permisos = %v, want 0755", file.Name, file.Mode().Perm())
+		}
+	}
+
+	// Quitar el bit de ejecución sí cambia el contenido
+	if err := os.Chmod(executable, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(zipBytes(t, appPath, opts), want) {
+		t.Error("el bit de ejecución debería reflejarse en el ZIP")
+	}
+}
+
+func TestZipDirectoryMaxBufferedBytes(t *testing.T) {
+	appPath := makeBundle(t, 8, 64<<10)
+	want := zipBytes(t, appPath, ZipOptions{Reproducible: true, Jobs: 1})
+
+	// Presupuestos menores que una entrada, para pocas entradas y sin límite:
+	// el contenido no cambia y no se bloquea con entradas mayores al límite
+	for _, maxBuffered := range []int64{1, 100 << 10, 1 << 30} {
+		opts := ZipOptions{Reproducible: true, Jobs: 4, MaxBufferedBytes: maxBuffered}
+		if !bytes.Equal(zipBytes(t, appPath, opts), want) {
+			t.Errorf("el ZIP con MaxBufferedBytes=%d difiere del generado con jobs=1", maxBuffered)
+		}
+	}
+}
+
+func TestZipBudget(t *testing.T) {
+	budget := newZipBudget(100)
+
+	// Una entrada mayor que el límite entra si no hay nada en vuelo
+	if !budget.acquire(150) {
+		t.Fatal("acquire(150) con el presupuesto libre debería admitirse")
+	}
+
+	acquired := make(chan bool)
+	go func() { acquired <- budget.acquire(10) }()
+	select {
+	case <-acquired:
+		t.Fatal("acquire(10) no debería admitirse con 150 bytes en vuelo")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	budget.release(150)
+	if !<-acquired {
+		t.Fatal("acquire(10) debería admitirse después de release")
+	}
+	if !budget.acquire(90) {
+		t.Fatal("acquire(90) entra justo en el límite")
+	}
+
+	go func() { acquired <- budget.acquire(1) }()
+	budget.close()
+	if <-acquired {
+		t.Error("acquire debería fallar después de close")
+	}
+}
+
+func benchmarkZipDirectory(b *testing.B, jobs int) {
+	appPath := makeBundle(b, 32, 1<<20)
+	zipPath := filepath.Join(b.TempDir(), "bench.zip")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ZipDirectoryWithOptions(appPath, zipPath, ZipOptions{Jobs: jobs}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkZipDirectorySerial(b *testing.B) {
+	benchmarkZipDirectory(b, 1)
+}
+
+func BenchmarkZipDirectoryParallel(b *testing.B) {
+	benchmarkZipDirectory(b, runtime.GOMAXPROCS(0))
+}
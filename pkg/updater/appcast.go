@@ -0,0 +1,105 @@
+package updater
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/appcast"
+)
+
+// fetchAppcast descarga el appcast de Sparkle y lo convierte en un Manifest
+// con la release más nueva aplicable. Retorna nil si no hay ninguna
+func (u *Updater) fetchAppcast() (*Manifest, error) {
+	body, err := u.fetchFeed(u.config.AppcastURL)
+	if err != nil {
+		return nil, err
+	}
+
+	feed, err := appcast.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	item := u.selectAppcastItem(feed.Items)
+	if item == nil {
+		return nil, nil
+	}
+
+	// Resolver URLs relativas del enclosure respecto del appcast
+	base, err := url.Parse(u.config.AppcastURL)
+	if err != nil {
+		return nil, fmt.Errorf("AppcastURL inválida: %w", err)
+	}
+	enclosure, err := url.Parse(item.URL)
+	if err != nil {
+		return nil, fmt.Errorf("URL de enclosure inválida: %w", err)
+	}
+
+	return &Manifest{
+		Version:     u.appcastVersion(*item),
+		URL:         base.ResolveReference(enclosure).String(),
+		Size:        item.Length,
+		EdSignature: item.EdSignature,
+		Critical:    item.Critical,
+	}, nil
+}
+
+// selectAppcastItem elige la release más nueva de los canales aceptados y
+// compatible con la versión de macOS. Los items con versión inválida se ignoran
+func (u *Updater) selectAppcastItem(items []appcast.Item) *appcast.Item {
+	systemVersion := u.config.SystemVersion
+	if systemVersion == "" {
+		systemVersion = currentSystemVersion()
+	}
+
+	var best *appcast.Item
+	for i := range items {
+		item := &items[i]
+
+		if !u.acceptsChannel(item.Channel) {
+			continue
+		}
+		if item.MinimumSystemVersion != "" && systemVersion != "" {
+			if cmp, err := BuildNumberComparator.Compare(systemVersion, item.MinimumSystemVersion); err == nil && cmp < 0 {
+				continue
+			}
+		}
+
+		// Validar la versión con el esquema configurado
+		version := u.appcastVersion(*item)
+		if _, err := u.compareVersions(version, version); err != nil {
+			continue
+		}
+
+		if best == nil {
+			best = item
+		} else if cmp, _ := u.compareVersions(version, u.appcastVersion(*best)); cmp > 0 {
+			best = item
+		}
+	}
+
+	return best
+}
+
+// appcastVersion retorna la versión de un item que corresponde al esquema
+// configurado: sparkle:version (CFBundleVersion) con BuildNumberComparator y
+// sparkle:shortVersionString en los demás casos
+func (u *Updater) appcastVersion(item appcast.Item) string {
+	if u.versionComparator() == BuildNumberComparator || item.ShortVersion == "" {
+		return item.Version
+	}
+	return item.ShortVersion
+}
+
+// acceptsChannel indica si un canal del appcast está habilitado
+func (u *Updater) acceptsChannel(channel string) bool {
+	if channel == "" {
+		return true
+	}
+	for _, c := range u.config.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/appcast"
+)
+
+func TestSelectAppcastItem(t *testing.T) {
+	items := []appcast.Item{
+		{Version: "100", ShortVersion: "1.0.0"},
+		{Version: "130", ShortVersion: "1.3.0", MinimumSystemVersion: "15.0"},
+		{Version: "120", ShortVersion: "1.2.0", Channel: "beta"},
+		{Version: "110", ShortVersion: "1.1.0"},
+		{Version: "999", ShortVersion: "no-es-una-versión"},
+	}
+
+	tests := []struct {
+		name          string
+		channels      []string
+		systemVersion string
+		comparator    VersionComparator
+		want          string
+	}{
+		{"canal por defecto", nil, "14.2", nil, "1.1.0"},
+		{"canal beta", []string{"beta"}, "14.2", nil, "1.2.0"},
+		{"macOS suficiente", nil, "15.1", nil, "1.3.0"},
+		// Con números de build la versión es sparkle:version: 999 es válida
+		{"números de build", nil, "14.2", BuildNumberComparator, "no-es-una-versión"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := New(Config{
+				Channels:          tt.channels,
+				SystemVersion:     tt.systemVersion,
+				VersionComparator: tt.comparator,
+			})
+
+			item := u.selectAppcastItem(items)
+			if item == nil {
+				t.Fatal("selectAppcastItem = nil")
+			}
+			if item.ShortVersion != tt.want {
+				t.Errorf("selectAppcastItem = %s, want %s", item.ShortVersion, tt.want)
+			}
+		})
+	}
+
+	t.Run("sin items aplicables", func(t *testing.T) {
+		u := New(Config{SystemVersion: "14.2"})
+		if item := u.selectAppcastItem(items[1:3]); item != nil {
+			t.Errorf("selectAppcastItem = %+v, want nil", item)
+		}
+	})
+}
@@ -1,38 +1,124 @@
 package updater
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
-	"syscall"
 	"time"
 
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/bundle"
 	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/utils"
 )
 
-// ApplyUpdate aplica la actualización descargada
+// Target describe la app que se actualiza y los procesos que deben terminar
+// antes del swap
+type Target struct {
+	// AppPath es el bundle .app instalado que se reemplaza
+	AppPath string
+
+	// PIDs son los procesos que deben terminar antes del swap. Vacío si no hay
+	// que esperar a ningún proceso concreto
+	PIDs []int
+
+	// BundleIdentifier, si se indica, debe coincidir con el CFBundleIdentifier
+	// de la app instalada, y el instalador espera además a que terminen todos
+	// los procesos que se ejecutan desde el bundle
+	BundleIdentifier string
+}
+
+// ApplyUpdate aplica la actualización descargada a la app actual
 // Este método:
-//  1. Descomprime el ZIP en el directorio de descarga
-//  2. Genera un script de shell para el reemplazo atómico
-//  3. Ejecuta el script como proceso detached
+//  1. Descomprime el ZIP en el directorio de descarga y valida el bundle:
+//     identificador y versión del Info.plist, y la firma de código si
+//     Config.VerifyCodeSignature está activo
+//  2. Genera el plan de instalación (copia junto a la app instalada, swap
+//     atómico y rollback)
+//  3. Relanza el ejecutable actual como instalador detached, que espera a
+//     que la aplicación termine y ejecuta el plan
 //
 // Después de llamar a este método, la aplicación debe salir con os.Exit(0)
-// para permitir que el script complete el reemplazo.
+// para permitir que el instalador complete el reemplazo.
 func (u *Updater) ApplyUpdate() error {
+	appPath, err := u.config.AppLocator.AppPath()
+	if err != nil {
+		return fmt.Errorf("error obteniendo ruta de la app actual: %w", err)
+	}
+
+	fmt.Println("La aplicación debe cerrarse para completar la actualización.")
+
+	return u.applyUpdate(Target{
+		AppPath: appPath,
+		PIDs:    []int{u.config.ProcessLauncher.Getpid()},
+	})
+}
+
+// ApplyUpdateTo aplica la actualización descargada a otra app (por ejemplo,
+// una app auxiliar o un login item administrado por un launcher). Usa el
+// mismo pipeline que ApplyUpdate, pero el proceso actual no necesita salir:
+// el instalador solo espera a target.PIDs y, si se indica
+// target.BundleIdentifier, a los procesos del bundle
+func (u *Updater) ApplyUpdateTo(target Target) error {
+	if target.AppPath == "" {
+		return fmt.Errorf("Target.AppPath es requerido")
+	}
+
+	if target.BundleIdentifier != "" {
+		info, err := bundle.ReadInfo(target.AppPath)
+		if err != nil {
+			return fmt.Errorf("error leyendo Info.plist de la app instalada: %w", err)
+		}
+		if info.Identifier != target.BundleIdentifier {
+			return fmt.Errorf("%w: la app en %s es %q, se esperaba %q", ErrBundleMismatch, target.AppPath, info.Identifier, target.BundleIdentifier)
+		}
+	}
+
+	return u.applyUpdate(target)
+}
+
+// applyUpdate descomprime y valida la actualización e inicia el instalador
+func (u *Updater) applyUpdate(target Target) error {
+	currentAppPath := target.AppPath
+
+	// Todo el proceso usa el mismo manifiesto aunque otra goroutine vuelva a
+	// llamar a CheckForUpdate
+	manifest := u.currentManifest()
+	zipPath := u.zipPath(manifest)
+
 	// Validar pre-condiciones
-	if err := u.validateForApply(); err != nil {
+	if err := u.validateForApply(currentAppPath, zipPath); err != nil {
 		return err
 	}
 
-	// Descomprimir el ZIP
+	// Evitar que otra instancia descargue o aplique a la vez. El instalador
+	// toma el mismo lock cuando la app lo libera
+	lock, err := u.lock()
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	// Verificar permisos de escritura antes de descomprimir nada
+	denied := u.deniedPaths(manifest, currentAppPath)
+	if len(denied) > 0 && u.config.Elevator == nil {
+		return fmt.Errorf("%w: sin permisos de escritura en %s", ErrNeedsPrivileges, strings.Join(denied, ", "))
+	}
+
+	// Limpiar directorio de extracción si existe (su espacio vuelve a estar
+	// disponible para la verificación siguiente)
 	extractPath := filepath.Join(u.config.DownloadPath, "extracted")
-	fmt.Printf("Descomprimiendo actualización en: %s\n", extractPath)
+	u.config.FileSystem.RemoveAll(extractPath)
+
+	// Verificar el espacio libre para descomprimir y copiar
+	if err := u.checkApplySpace(manifest, zipPath, currentAppPath); err != nil {
+		return err
+	}
 
-	// Limpiar directorio de extracción si existe
-	os.RemoveAll(extractPath)
-	zipPath := u.GetZipPath()
+	// Descomprimir el ZIP
+	fmt.Printf("Descomprimiendo actualización en: %s\n", extractPath)
 	if err := utils.UnzipFile(zipPath, extractPath); err != nil {
 		return fmt.Errorf("error descomprimiendo actualización: %w", err)
 	}
@@ -45,55 +131,140 @@ func (u *Updater) ApplyUpdate() error {
 
 	fmt.Printf("Bundle encontrado: %s\n", newAppPath)
 
-	// Obtener información del proceso actual
-	pid := os.Getpid()
-	currentAppPath, err := getCurrentAppPath()
+	// Validar identificador y versión del nuevo bundle
+	version, err := u.verifyBundleInfo(manifest, newAppPath, currentAppPath)
 	if err != nil {
-		return fmt.Errorf("error obteniendo ruta de la app actual: %w", err)
+		return err
+	}
+
+	// Validar la firma de código antes de reemplazar la app instalada
+	if u.config.VerifyCodeSignature {
+		if err := u.verifyCodeSignature(newAppPath, currentAppPath); err != nil {
+			return err
+		}
 	}
 
-	// Generar ruta para backup
-	backupPath := filepath.Join(u.config.DownloadPath, fmt.Sprintf("backup-%d.app", time.Now().Unix()))
+	// Preparar los componentes adicionales (misma transacción que la app)
+	timestamp := time.Now().Unix()
+	var components []installItem
+	if manifest == nil {
+		// Sin manifiesto (por ejemplo, después de reiniciar la app) no se sabe
+		// dónde instalar los componentes descargados: no se instala la app sin
+		// ellos. Las descargas parciales (.part) no cuentan
+		if zips, err := filepath.Glob(filepath.Join(u.componentsPath(), "*.zip")); err == nil && len(zips) > 0 {
+			return fmt.Errorf("hay componentes descargados pero no hay manifiesto: llame a CheckForUpdate antes de ApplyUpdate")
+		}
+	} else if len(manifest.Components) > 0 {
+		if err := u.validateComponents(manifest.Components); err != nil {
+			return err
+		}
+		if components, err = u.stageComponents(manifest, extractPath, currentAppPath, timestamp); err != nil {
+			return err
+		}
+	}
 
-	// Generar script
-	script := generateUpdateScript(
-		pid,
-		newAppPath,
-		currentAppPath,
-		backupPath,
-		zipPath,
-		u.config.AfterUpdateCommand,
-		u.config.BeforeUpdateCommand,
-		u.config.StartAutomatically,
-	)
+	// Generar plan de instalación
+	plan := installPlan{
+		PIDs:                 target.PIDs,
+		WaitForApp:           target.BundleIdentifier != "",
+		WaitTimeout:          u.config.WaitTimeout,
+		WaitTimeoutAction:    u.config.WaitTimeoutAction,
+		TerminateGracePeriod: u.config.TerminateGracePeriod,
+		StatePath:            u.statePath(),
+		LockPath:             u.lockPath(),
+		ExtractPath:          extractPath,
+		NewAppPath:           newAppPath,
+		CurrentAppPath:       currentAppPath,
+		StagingPath:          siblingPath(currentAppPath, fmt.Sprintf("staging-%d", timestamp)),
+		BackupPath:           siblingPath(currentAppPath, fmt.Sprintf("backup-%d", timestamp)),
+		ZipPath:              zipPath,
+		BeforeCommand:        u.config.BeforeUpdateCommand,
+		AfterCommand:         u.config.AfterUpdateCommand,
+		Relaunch:             u.config.StartAutomatically,
+		Components:           components,
+		Version:              version,
+	}
+	if len(denied) > 0 {
+		plan.UserID = os.Getuid()
 
-	// Escribir script a archivo temporal
-	scriptPath := filepath.Join(u.config.DownloadPath, "update-script.sh")
-	if err := os.WriteFile(scriptPath, []byte(script), 0755); err != nil {
-		return fmt.Errorf("error escribiendo script de actualización: %w", err)
+		// El instalador elevado verifica que copia exactamente lo validado
+		if plan.NewAppDigest, err = treeDigest(newAppPath); err != nil {
+			return err
+		}
+		for n := range plan.Components {
+			if plan.Components[n].Digest, err = treeDigest(plan.Components[n].NewPath); err != nil {
+				return err
+			}
+		}
+	}
+	logPath := filepath.Join(u.config.DownloadPath, "update.log")
+	plan.LogPath = logPath
+	if len(components) > 0 {
+		plan.ComponentsPath = u.componentsPath()
 	}
 
-	fmt.Printf("Script de actualización creado: %s\n", scriptPath)
+	planData, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializando plan de instalación: %w", err)
+	}
 
-	// Ejecutar script como proceso detached
-	if err := executeDetached(scriptPath); err != nil {
-		return fmt.Errorf("error ejecutando script de actualización: %w", err)
+	planPath := filepath.Join(u.config.DownloadPath, installPlanFileName)
+	if err := u.config.FileSystem.WriteFile(planPath, planData, 0600); err != nil {
+		return fmt.Errorf("error escribiendo plan de instalación: %w", err)
 	}
 
-	fmt.Println("Script de actualización iniciado. La aplicación debe cerrarse ahora.")
+	fmt.Printf("Plan de instalación creado: %s\n", planPath)
+
+	// Relanzar el ejecutable actual como instalador detached
+	exePath, err := u.config.ProcessLauncher.Executable()
+	if err != nil {
+		return fmt.Errorf("error obteniendo ejecutable actual: %w", err)
+	}
+
+	planDigest := sha256.Sum256(planData)
+	env := []string{
+		installPlanEnv + "=" + planPath,
+		installPlanDigestEnv + "=" + hex.EncodeToString(planDigest[:]),
+	}
+
+	// Sin permisos de escritura el instalador se ejecuta con privilegios
+	if len(denied) > 0 {
+		fmt.Printf("Se requieren privilegios de administrador para: %s\n", strings.Join(denied, ", "))
+		err := u.config.Elevator.Elevate(ElevationRequest{
+			Executable: exePath,
+			Env:        env,
+			LogPath:    logPath,
+			Paths:      denied,
+		})
+		if err != nil {
+			u.config.FileSystem.Remove(planPath)
+			return fmt.Errorf("error ejecutando instalador con privilegios: %w", err)
+		}
+		fmt.Printf("Instalador iniciado con privilegios\n")
+		fmt.Printf("Log de actualización: %s\n", logPath)
+		return nil
+	}
+
+	installerPID, err := u.config.ProcessLauncher.StartDetached(exePath, env, logPath)
+	if err != nil {
+		return fmt.Errorf("error ejecutando instalador: %w", err)
+	}
+
+	fmt.Printf("Instalador iniciado con PID: %d\n", installerPID)
+	fmt.Printf("Log de actualización: %s\n", logPath)
 
 	return nil
 }
 
-// validateForApply valida que se puede aplicar la actualización
-func (u *Updater) validateForApply() error {
+// validateForApply valida que se puede aplicar la actualización de zipPath
+// sobre currentAppPath
+func (u *Updater) validateForApply(currentAppPath, zipPath string) error {
 	// Verificar que existe el directorio de descarga
 	if _, err := os.Stat(u.config.DownloadPath); os.IsNotExist(err) {
 		return fmt.Errorf("directorio de descarga no existe: %s", u.config.DownloadPath)
 	}
 
 	// Verificar que existe el ZIP descargado
-	zipPath := u.GetZipPath()
 	if zipPath == "" {
 		return fmt.Errorf("no hay actualización descargada")
 	}
@@ -102,51 +273,18 @@ func (u *Updater) validateForApply() error {
 		return fmt.Errorf("archivo de actualización no existe: %s", zipPath)
 	}
 
-	// Verificar que existe la app actual
-	currentAppPath, err := getCurrentAppPath()
-	if err != nil {
-		return fmt.Errorf("error obteniendo ruta de la app actual: %w", err)
-	}
-	if _, err := os.Stat(currentAppPath); os.IsNotExist(err) {
+	// Verificar que existe la app a actualizar
+	if _, err := u.config.FileSystem.Lstat(currentAppPath); os.IsNotExist(err) {
 		return fmt.Errorf("aplicación actual no existe: %s", currentAppPath)
 	}
 
 	return nil
 }
 
-// getCurrentAppPath busca el directorio .app ascendiendo desde el ejecutable
-func getCurrentAppPath() (string, error) {
-	exePath, err := os.Executable()
-	if err != nil {
-		return "", err
-	}
-
-	// Resolver symlinks por si acaso
-	exePath, err = filepath.EvalSymlinks(exePath)
-	if err != nil {
-		return "", err
-	}
-
-	// Recorrer hacia arriba buscando el directorio .app
-	path := exePath
-	for {
-		if strings.HasSuffix(path, ".app") {
-			return path, nil
-		}
-		parent := filepath.Dir(path)
-		if parent == path || parent == "." {
-			break
-		}
-		path = parent
-	}
-
-	// Si no encontramos un .app, devolvemos el directorio del ejecutable (fallback)
-	return filepath.Dir(exePath), nil
-}
-
-// findAppBundle busca el bundle .app dentro de un directorio
+// findAppBundle busca el bundle .app dentro de un directorio. Solo considera
+// directorios .app con Contents/Info.plist y falla si hay más de uno
 func findAppBundle(searchPath string) (string, error) {
-	var appPath string
+	var candidates []string
 
 	entries, err := os.ReadDir(searchPath)
 	if err != nil {
@@ -154,65 +292,21 @@ func findAppBundle(searchPath string) (string, error) {
 	}
 
 	for _, entry := range entries {
-		if entry.IsDir() && strings.HasSuffix(entry.Name(), ".app") {
-			appPath = filepath.Join(searchPath, entry.Name())
-			break
+		if !entry.IsDir() || !strings.HasSuffix(entry.Name(), ".app") {
+			continue
+		}
+		appPath := filepath.Join(searchPath, entry.Name())
+		if _, err := os.Stat(bundle.InfoPlistPath(appPath)); err == nil {
+			candidates = append(candidates, appPath)
 		}
 	}
 
-	if appPath == "" {
+	if len(candidates) == 0 {
 		return "", fmt.Errorf("no se encontró bundle .app en: %s", searchPath)
 	}
-
-	return appPath, nil
-}
-
-// generateUpdateScript genera el script de actualización con las variables inyectadas
-func generateUpdateScript(pid int, newAppPath, currentAppPath, backupPath, zipPath, afterUpdateCommand, beforeUpdateCommand string, startAutomatically bool) string {
-	script := getScript(startAutomatically, afterUpdateCommand, beforeUpdateCommand)
-
-	// Reemplazar variables
-	script = strings.ReplaceAll(script, "{{PID}}", fmt.Sprintf("%d", pid))
-	script = strings.ReplaceAll(script, "{{NEW_APP_PATH}}", newAppPath)
-	script = strings.ReplaceAll(script, "{{CURRENT_APP_PATH}}", currentAppPath)
-	script = strings.ReplaceAll(script, "{{OLD_APP_BACKUP}}", backupPath)
-	script = strings.ReplaceAll(script, "{{ZIP_PATH}}", zipPath)
-
-	return script
-}
-
-// executeDetached ejecuta un script como proceso completamente independiente
-func executeDetached(scriptPath string) error {
-	cmd := exec.Command("/bin/bash", scriptPath)
-
-	// Configurar para que el proceso sea completamente independiente
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setsid: true, // Crear nueva sesión para que sobreviva al padre
-	}
-
-	// Redirigir salida a un archivo de log
-	logPath := filepath.Join(filepath.Dir(scriptPath), "update.log")
-	logFile, err := os.Create(logPath)
-	if err != nil {
-		return fmt.Errorf("error creando archivo de log: %w", err)
-	}
-
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
-
-	// Iniciar el proceso (no esperamos a que termine)
-	if err := cmd.Start(); err != nil {
-		logFile.Close()
-		return fmt.Errorf("error iniciando script: %w", err)
-	}
-
-	// Liberar el proceso para que continúe independientemente
-	if err := cmd.Process.Release(); err != nil {
-		return fmt.Errorf("error liberando proceso: %w", err)
+	if len(candidates) > 1 {
+		return "", fmt.Errorf("se encontró más de un bundle .app en: %s", searchPath)
 	}
 
-	fmt.Printf("Script iniciado con PID: %d\n", cmd.Process.Pid)
-	fmt.Printf("Log de actualización: %s\n", logPath)
-
-	return nil
+	return candidates[0], nil
 }
@@ -0,0 +1,85 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/bundle"
+)
+
+// ErrBundleMismatch indica que el bundle descargado no corresponde a la app
+// instalada o a la versión anunciada en el manifiesto
+var ErrBundleMismatch = errors.New("el bundle descargado no coincide con la app esperada")
+
+// verifyBundleInfo compara el Info.plist del nuevo bundle con el de la app
+// instalada (CFBundleIdentifier) y con el manifiesto (CFBundleShortVersionString).
+// Retorna la versión que se instala
+func (u *Updater) verifyBundleInfo(manifest *Manifest, newAppPath, currentAppPath string) (string, error) {
+	newInfo, err := bundle.ReadInfo(newAppPath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBundleMismatch, err)
+	}
+
+	currentInfo, err := bundle.ReadInfo(currentAppPath)
+	if err != nil {
+		return "", fmt.Errorf("error leyendo Info.plist de la app instalada: %w", err)
+	}
+
+	if newInfo.Identifier != currentInfo.Identifier {
+		return "", fmt.Errorf("%w: identificador esperado %q, encontrado %q", ErrBundleMismatch, currentInfo.Identifier, newInfo.Identifier)
+	}
+
+	version := ""
+	if manifest != nil {
+		// La versión del manifiesto puede corresponder a CFBundleShortVersionString
+		// o, con esquemas de número de build, a CFBundleVersion
+		if !u.sameVersion(newInfo.ShortVersion, manifest.Version) && !u.sameVersion(newInfo.Version, manifest.Version) {
+			return "", fmt.Errorf("%w: el manifiesto anuncia la versión %q pero el bundle es %q (%s)", ErrBundleMismatch, manifest.Version, newInfo.ShortVersion, newInfo.Version)
+		}
+		version = manifest.Version
+	} else if version, err = u.verifyBundleVersion(newInfo); err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Bundle validado: %s %s\n", newInfo.Identifier, newInfo.ShortVersion)
+
+	return version, nil
+}
+
+// verifyBundleVersion valida la versión del bundle cuando no hay manifiesto
+// (por ejemplo, si la app se reinició entre la descarga y ApplyUpdate): debe
+// ser mayor a la actual y no menor al high-water mark. Sin manifiesto no hay
+// rollbacks
+func (u *Updater) verifyBundleVersion(info *bundle.Info) (string, error) {
+	version := info.ShortVersion
+	if _, err := u.compareVersions(version, u.config.CurrentVersion); err != nil {
+		// Esquemas de número de build: la versión es CFBundleVersion
+		version = info.Version
+	}
+
+	comparison, err := u.compareVersions(version, u.config.CurrentVersion)
+	if err != nil {
+		return "", fmt.Errorf("%w: versión del bundle %q (%s): %v", ErrInvalidVersion, info.ShortVersion, info.Version, err)
+	}
+	if comparison <= 0 {
+		return "", fmt.Errorf("%w: el bundle es la versión %s y la actual es %s", ErrDowngradeRejected, version, u.config.CurrentVersion)
+	}
+
+	state, err := u.loadState()
+	if err != nil {
+		return "", err
+	}
+	if err := u.checkHighestVersion(state, version); err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+// sameVersion indica si dos versiones son equivalentes según el esquema configurado
+func (u *Updater) sameVersion(a, b string) bool {
+	if a == "" {
+		return false
+	}
+	comparison, err := u.compareVersions(a, b)
+	return err == nil && comparison == 0
+}
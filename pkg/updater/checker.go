@@ -1,88 +1,305 @@
 package updater
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"runtime"
-
-	"golang.org/x/mod/semver"
+	"time"
 )
 
+// ErrInvalidVersion indica que la versión del manifiesto o la actual no es válida
+var ErrInvalidVersion = errors.New("versión inválida")
+
+// ErrDowngradeRejected indica que el manifiesto anuncia una versión anterior
+// no permitida (downgrade no autorizado o replay de un manifiesto viejo)
+var ErrDowngradeRejected = errors.New("downgrade rechazado")
+
 // CheckForUpdate verifica si hay una actualización disponible
 // Retorna:
 //   - hasUpdate: true si hay una versión nueva disponible
 //   - newVersion: string con la nueva versión (vacío si no hay actualización)
 //   - error: error si hubo problemas descargando o parseando el manifiesto
+//
+// Si ya hay una verificación en curso (por ejemplo, el Scheduler y un "Buscar
+// ahora" manual), espera a que termine y retorna su resultado en lugar de
+// descargar el manifiesto otra vez
 func (u *Updater) CheckForUpdate() (bool, string, error) {
+	u.checkMu.Lock()
+	if call := u.check; call != nil {
+		u.checkMu.Unlock()
+		<-call.done
+		return call.hasUpdate, call.version, call.err
+	}
+	call := &checkCall{done: make(chan struct{})}
+	u.check = call
+	u.checkMu.Unlock()
+
+	// Liberar a los que esperan aunque checkForUpdate entre en panic; en ese
+	// caso reciben errCheckPanicked en lugar de un resultado vacío
+	defer func() {
+		u.checkMu.Lock()
+		u.check = nil
+		u.checkMu.Unlock()
+		close(call.done)
+	}()
+
+	call.err = errCheckPanicked
+	call.hasUpdate, call.version, call.err = u.checkForUpdate()
+	return call.hasUpdate, call.version, call.err
+}
+
+// errCheckPanicked es el resultado de las llamadas que esperaban una
+// verificación que terminó en panic
+var errCheckPanicked = errors.New("la verificación de actualizaciones en curso falló inesperadamente")
+
+// checkCall es una verificación en curso compartida por las llamadas concurrentes
+type checkCall struct {
+	done      chan struct{}
+	hasUpdate bool
+	version   string
+	err       error
+}
+
+// checkForUpdate descarga el manifiesto y decide si es una actualización
+func (u *Updater) checkForUpdate() (bool, string, error) {
+	// Descargar el manifiesto del feed configurado (JSON o appcast)
+	manifest, err := u.fetchManifest()
+	if err != nil {
+		return false, "", err
+	}
+
+	// Descartar cualquier manifiesto previo: solo se guarda si se acepta
+	u.setManifest(nil)
+
+	// Un appcast sin releases aplicables no ofrece actualización
+	if manifest == nil {
+		return false, "", nil
+	}
+
+	accepted, err := u.acceptManifest(manifest)
+	if err != nil || !accepted {
+		return false, "", err
+	}
+
+	// Guardar manifiesto para uso posterior
+	u.setManifest(manifest)
+
+	return true, manifest.Version, nil
+}
+
+// fetchManifest descarga el manifiesto del feed configurado: un appcast de
+// Sparkle si Config.AppcastURL está definida, o SourceURL/darwin-{arch}.json
+func (u *Updater) fetchManifest() (*Manifest, error) {
+	if u.config.AppcastURL != "" {
+		return u.fetchAppcast()
+	}
+
 	// Construir URL del manifiesto según la arquitectura
 	arch := runtime.GOARCH
 	manifestURL := fmt.Sprintf("%sdarwin-%s.json", u.config.SourceURL, arch)
 
-	// Descargar manifiesto
-	resp, err := http.Get(manifestURL)
+	body, err := u.fetchFeed(manifestURL)
 	if err != nil {
-		return false, "", fmt.Errorf("error descargando manifiesto: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return false, "", fmt.Errorf("error HTTP %d descargando manifiesto", resp.StatusCode)
+	// Parsear JSON
+	return ParseManifest(body)
+}
+
+// fetchFeed descarga un manifiesto o appcast y, si Config.ManifestPublicKey
+// está definida, valida su firma separada (ver fetchFeedSignature).
+//
+// La última copia se guarda en el estado con su ETag y Last-Modified. Mientras
+// no venza el max-age de Cache-Control se reutiliza sin consultar al servidor
+// (también sin conexión); después se revalida con If-None-Match e
+// If-Modified-Since y, si el servidor responde 304, se reutiliza la copia
+func (u *Updater) fetchFeed(feedURL string) ([]byte, error) {
+	now := time.Now()
+	cached := u.cachedFeed(feedURL)
+	if cached != nil && now.Before(cached.Expires) {
+		return []byte(cached.Body), nil
 	}
 
-	// Leer contenido
-	body, err := io.ReadAll(resp.Body)
+	response, err := requestFeed(feedURL, cached)
 	if err != nil {
-		return false, "", fmt.Errorf("error leyendo manifiesto: %w", err)
+		return nil, err
 	}
 
-	// Parsear JSON
-	var manifest Manifest
-	if err := json.Unmarshal(body, &manifest); err != nil {
-		return false, "", fmt.Errorf("error parseando manifiesto: %w", err)
+	if response.notModified {
+		cached.Expires = now.Add(response.maxAge)
+		if response.etag != "" {
+			cached.ETag = response.etag
+		}
+		if response.lastModified != "" {
+			cached.LastModified = response.lastModified
+		}
+		u.saveFeedCache(cached)
+		return []byte(cached.Body), nil
 	}
 
-	// Guardar manifiesto para uso posterior
-	u.manifest = &manifest
+	body := response.body
+	var signature []byte
+	if u.config.ManifestPublicKey != "" {
+		if signature, err = u.fetchFeedSignature(feedURL, body); err != nil {
+			return nil, err
+		}
+	}
+
+	if response.cacheable() {
+		u.saveFeedCache(&feedCache{
+			URL:          feedURL,
+			ETag:         response.etag,
+			LastModified: response.lastModified,
+			Body:         string(body),
+			Signature:    string(signature),
+			Expires:      now.Add(response.maxAge),
+		})
+	} else if cached != nil {
+		u.saveFeedCache(nil)
+	}
 
-	// Comparar versiones
-	hasUpdate, err := isNewerVersion(u.config.CurrentVersion, manifest.Version)
+	return body, nil
+}
+
+// checkHighestVersion rechaza version si es menor al high-water mark: la versión
+// más alta instalada por el updater
+func (u *Updater) checkHighestVersion(state *updaterState, version string) error {
+	if state.HighestVersion == "" {
+		return nil
+	}
+	highest, err := u.compareVersions(version, state.HighestVersion)
+	if err == nil && highest < 0 {
+		return fmt.Errorf("%w: se anuncia %s pero ya se instaló %s", ErrDowngradeRejected, version, state.HighestVersion)
+	}
+	return nil
+}
+
+// fetchFeedSignature descarga y valida la firma de body. Primero busca la firma
+// direccionada por contenido (ManifestSignaturePath, relativa a feedURL), que
+// no puede corresponder a otra versión del feed; si no existe o no es válida
+// prueba con {feedURL}.sig, la firma que publicaban las versiones anteriores
+func (u *Updater) fetchFeedSignature(feedURL string, body []byte) ([]byte, error) {
+	var candidates []string
+	if base, err := url.Parse(feedURL); err == nil {
+		if ref, err := url.Parse(ManifestSignaturePath(body)); err == nil {
+			candidates = append(candidates, base.ResolveReference(ref).String())
+		}
+	}
+	candidates = append(candidates, feedURL+".sig")
+
+	var lastErr error
+	for _, signatureURL := range candidates {
+		signature, err := fetchURL(signatureURL)
+		if err != nil {
+			lastErr = fmt.Errorf("%w: %v", ErrManifestSignature, err)
+			continue
+		}
+		if err := VerifyManifestSignature(body, signature, u.config.ManifestPublicKey); err != nil {
+			lastErr = err
+			continue
+		}
+		return signature, nil
+	}
+	return nil, lastErr
+}
+
+// fetchURL descarga el contenido de un manifiesto, appcast o firma
+func fetchURL(feedURL string) ([]byte, error) {
+	response, err := requestFeed(feedURL, nil)
 	if err != nil {
-		// Si hay error en comparación semántica, comparar como strings
-		hasUpdate = manifest.Version != u.config.CurrentVersion
+		return nil, err
 	}
+	return response.body, nil
+}
 
-	if hasUpdate {
-		return true, manifest.Version, nil
+// requestFeed descarga feedURL. Si hay una copia cacheada la pide de forma
+// condicional, y una respuesta 304 indica que sigue vigente
+func requestFeed(feedURL string, cached *feedCache) (*feedResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error descargando manifiesto: %w", err)
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
 	}
 
-	return false, "", nil
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error descargando manifiesto: %w", err)
+	}
+	defer resp.Body.Close()
+
+	response := &feedResponse{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}
+	response.maxAge, response.noStore = parseCacheControl(resp.Header.Get("Cache-Control"), resp.Header.Get("Age"))
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		response.notModified = true
+		return response, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error HTTP %d descargando manifiesto", resp.StatusCode)
+	}
+
+	// Leer contenido
+	response.body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo manifiesto: %w", err)
+	}
+
+	return response, nil
 }
 
-// isNewerVersion compara dos versiones semánticas
-// Retorna true si newVersion es mayor que currentVersion
-func isNewerVersion(currentVersion, newVersion string) (bool, error) {
-	// Asegurar que las versiones tienen el prefijo "v" para semver
-	current := currentVersion
-	if current != "" && current[0] != 'v' {
-		current = "v" + current
+// acceptManifest decide si el manifiesto es una actualización instalable.
+// En modo estricto solo se aceptan versiones estrictamente mayores a la actual
+// y no menores al high-water mark persistido, que el instalador actualiza al
+// instalar (una release retirada antes de instalarse no lo mueve). Un downgrade solo se acepta si
+// el manifiesto lo marca como rollback y Config.AllowDowngrade está activo.
+// Las versiones omitidas o pospuestas por el usuario no se ofrecen, salvo
+// que el manifiesto las marque como críticas
+func (u *Updater) acceptManifest(manifest *Manifest) (bool, error) {
+	comparison, err := u.compareVersions(manifest.Version, u.config.CurrentVersion)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidVersion, err)
+	}
+
+	state, err := u.loadState()
+	if err != nil {
+		return false, err
+	}
+
+	if manifest.Rollback && comparison < 0 {
+		if !u.config.AllowDowngrade {
+			return false, fmt.Errorf("%w: el manifiesto es un rollback a %s pero AllowDowngrade no está activo", ErrDowngradeRejected, manifest.Version)
+		}
+		// Un rollback intencional redefine el high-water mark al instalarse
+		return true, nil
 	}
 
-	new := newVersion
-	if new != "" && new[0] != 'v' {
-		new = "v" + new
+	if comparison <= 0 {
+		// Misma versión o más antigua que la instalada: no hay actualización
+		return false, nil
 	}
 
-	// Validar que ambas son versiones semánticas válidas
-	if !semver.IsValid(current) {
-		return false, fmt.Errorf("versión actual inválida: %s", currentVersion)
+	if err := u.checkHighestVersion(state, manifest.Version); err != nil {
+		return false, err
 	}
-	if !semver.IsValid(new) {
-		return false, fmt.Errorf("versión nueva inválida: %s", newVersion)
+
+	// Respetar "Omitir esta versión" y "Recordarme más tarde" (salvo releases críticas)
+	if u.isDeferred(state.Preferences, manifest, time.Now()) {
+		return false, nil
 	}
 
-	// Comparar: retorna 1 si new > current, 0 si iguales, -1 si new < current
-	comparison := semver.Compare(new, current)
-	return comparison > 0, nil
+	return true, nil
 }
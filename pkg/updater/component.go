@@ -0,0 +1,241 @@
+package updater
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/bundle"
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/utils"
+)
+
+// Component describe un componente adicional de la release. Su payload es un
+// ZIP con una única entrada en la raíz (un bundle .app o un ejecutable) que
+// se instala en InstallPath
+type Component struct {
+	// Name identifica el componente (letras, números, '.', '_' y '-')
+	Name string `json:"name"`
+
+	// URL es la URL del ZIP del componente: absoluta o relativa a SourceURL
+	URL string `json:"url"`
+
+	// InstallPath es la ruta absoluta donde se instala
+	// (ej: "/Library/PrivilegedHelperTools/com.joobpay.helper")
+	InstallPath string `json:"install_path"`
+
+	// Symlink es una ruta absoluta opcional donde se crea un symlink a
+	// InstallPath (ej: "/usr/local/bin/myapp")
+	Symlink string `json:"symlink,omitempty"`
+
+	// Checksum, Digests, Size y EdSignature validan el ZIP igual que en el
+	// manifiesto principal
+	Checksum    string            `json:"checksum,omitempty"`
+	Digests     map[string]string `json:"digests,omitempty"`
+	Size        int64             `json:"size,omitempty"`
+	EdSignature string            `json:"ed_signature,omitempty"`
+
+	// InstalledSize es el tamaño descomprimido en bytes (0 si no se conoce)
+	InstalledSize int64 `json:"installed_size,omitempty"`
+}
+
+// componentNamePattern restringe los nombres para usarlos como nombres de archivo
+var componentNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// payload retorna un manifiesto con los datos de validación del componente
+func (c Component) payload() *Manifest {
+	return &Manifest{
+		Checksum:    c.Checksum,
+		Digests:     c.Digests,
+		Size:        c.Size,
+		EdSignature: c.EdSignature,
+		URL:         c.URL,
+	}
+}
+
+// validateComponents valida los componentes del manifiesto antes de descargar
+// o instalar nada. Las rutas deben estar en Config.ComponentPaths: el
+// manifiesto es remoto y no debe poder escribir en cualquier lugar (por
+// ejemplo, en /Library/LaunchDaemons)
+func (u *Updater) validateComponents(components []Component) error {
+	seen := make(map[string]bool)
+	for _, component := range components {
+		if !componentNamePattern.MatchString(component.Name) || component.Name == "." || component.Name == ".." {
+			return fmt.Errorf("nombre de componente inválido: %q", component.Name)
+		}
+		if seen[component.Name] {
+			return fmt.Errorf("componente duplicado: %s", component.Name)
+		}
+		seen[component.Name] = true
+
+		if component.URL == "" {
+			return fmt.Errorf("el componente %s no tiene url", component.Name)
+		}
+		if !filepath.IsAbs(expandHome(component.InstallPath)) {
+			return fmt.Errorf("el componente %s debe tener un install_path absoluto", component.Name)
+		}
+		if !u.componentPathAllowed(component.InstallPath) {
+			return fmt.Errorf("el install_path del componente %s no está en ComponentPaths: %s", component.Name, component.InstallPath)
+		}
+		if component.Symlink != "" {
+			if !filepath.IsAbs(expandHome(component.Symlink)) {
+				return fmt.Errorf("el symlink del componente %s debe ser absoluto", component.Name)
+			}
+			if !u.componentPathAllowed(component.Symlink) {
+				return fmt.Errorf("el symlink del componente %s no está en ComponentPaths: %s", component.Name, component.Symlink)
+			}
+		}
+	}
+	return nil
+}
+
+// componentPathAllowed indica si path, una vez limpio (sin ".."), es una de
+// las rutas de Config.ComponentPaths o está debajo de una
+func (u *Updater) componentPathAllowed(path string) bool {
+	path = cleanComponentPath(path)
+	for _, allowed := range u.config.ComponentPaths {
+		allowed = cleanComponentPath(allowed)
+		if allowed == "" || !filepath.IsAbs(allowed) {
+			continue
+		}
+		if path == allowed || strings.HasPrefix(path, strings.TrimSuffix(allowed, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanComponentPath expande "~" y limpia una ruta de componente
+func cleanComponentPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return filepath.Clean(expandHome(path))
+}
+
+// componentsPath es el directorio donde se descargan los ZIP de componentes
+func (u *Updater) componentsPath() string {
+	return filepath.Join(u.config.DownloadPath, "components")
+}
+
+// componentZipPath retorna la ruta local del ZIP de un componente
+func (u *Updater) componentZipPath(name string) string {
+	return filepath.Join(u.componentsPath(), name+".zip")
+}
+
+// downloadComponents descarga y valida los ZIP de los componentes
+func (u *Updater) downloadComponents(manifest *Manifest) error {
+	if len(manifest.Components) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(u.componentsPath(), 0755); err != nil {
+		return fmt.Errorf("error creando directorio de componentes: %w", err)
+	}
+
+	for _, component := range manifest.Components {
+		check, err := newDownloadCheck(component.payload(), u.config.EdDSAPublicKey)
+		if err != nil {
+			return fmt.Errorf("componente %s: %w", component.Name, err)
+		}
+
+		downloadURL, err := u.resolveURL(component.URL)
+		if err != nil {
+			return fmt.Errorf("componente %s: %w", component.Name, err)
+		}
+
+		fmt.Printf("Descargando componente %s desde: %s\n", component.Name, downloadURL)
+		if err := downloadFile(downloadURL, u.componentZipPath(component.Name), check); err != nil {
+			return fmt.Errorf("error descargando componente %s: %w", component.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// stageComponents descomprime y valida los componentes en extractPath y
+// retorna los items a instalar junto con la app
+func (u *Updater) stageComponents(manifest *Manifest, extractPath, mainAppPath string, timestamp int64) ([]installItem, error) {
+	var items []installItem
+
+	for _, component := range manifest.Components {
+		zipPath := u.componentZipPath(component.Name)
+		if _, err := os.Stat(zipPath); err != nil {
+			return nil, fmt.Errorf("el componente %s no está descargado: %w", component.Name, err)
+		}
+
+		componentPath := filepath.Join(extractPath, "components", component.Name)
+		if err := utils.UnzipFile(zipPath, componentPath); err != nil {
+			return nil, fmt.Errorf("error descomprimiendo componente %s: %w", component.Name, err)
+		}
+
+		newPath, err := singleEntry(componentPath)
+		if err != nil {
+			return nil, fmt.Errorf("componente %s: %w", component.Name, err)
+		}
+
+		installPath := cleanComponentPath(component.InstallPath)
+
+		// Un bundle debe mantener el identificador de la versión instalada
+		if strings.HasSuffix(newPath, ".app") {
+			if err := verifyComponentBundle(newPath, installPath); err != nil {
+				return nil, fmt.Errorf("componente %s: %w", component.Name, err)
+			}
+		}
+
+		if u.config.VerifyCodeSignature {
+			if err := u.verifyComponentSignature(newPath, installPath, mainAppPath); err != nil {
+				return nil, fmt.Errorf("componente %s: %w", component.Name, err)
+			}
+		}
+
+		item := installItem{
+			Name:        component.Name,
+			NewPath:     newPath,
+			InstallPath: installPath,
+			StagingPath: siblingPath(installPath, fmt.Sprintf("staging-%d", timestamp)),
+			BackupPath:  siblingPath(installPath, fmt.Sprintf("backup-%d", timestamp)),
+		}
+		if component.Symlink != "" {
+			item.Symlink = cleanComponentPath(component.Symlink)
+			item.SymlinkBackup = siblingPath(item.Symlink, fmt.Sprintf("backup-%d", timestamp))
+		}
+		items = append(items, item)
+
+		fmt.Printf("Componente preparado: %s -> %s\n", component.Name, installPath)
+	}
+
+	return items, nil
+}
+
+// singleEntry retorna la única entrada en la raíz de un componente extraído
+func singleEntry(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("error leyendo directorio: %w", err)
+	}
+	if len(entries) != 1 {
+		return "", fmt.Errorf("el ZIP debe contener una única entrada en la raíz, tiene %d", len(entries))
+	}
+	return filepath.Join(dir, entries[0].Name()), nil
+}
+
+// verifyComponentBundle valida el Info.plist de un componente .app contra la
+// versión instalada (si existe)
+func verifyComponentBundle(newPath, installPath string) error {
+	newInfo, err := bundle.ReadInfo(newPath)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBundleMismatch, err)
+	}
+
+	installedInfo, err := bundle.ReadInfo(installPath)
+	if err != nil {
+		// Componente nuevo: no hay contra qué comparar
+		return nil
+	}
+	if newInfo.Identifier != installedInfo.Identifier {
+		return fmt.Errorf("%w: identificador esperado %q, encontrado %q", ErrBundleMismatch, installedInfo.Identifier, newInfo.Identifier)
+	}
+	return nil
+}
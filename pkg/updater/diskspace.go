@@ -0,0 +1,151 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/utils"
+)
+
+// ErrInsufficientSpace indica que no hay espacio libre suficiente para
+// descargar o instalar la actualización. Los errores concretos son
+// *InsufficientSpaceError
+var ErrInsufficientSpace = errors.New("espacio en disco insuficiente")
+
+// InsufficientSpaceError reporta el espacio requerido y disponible en un volumen
+type InsufficientSpaceError struct {
+	// Path es una ruta del volumen sin espacio
+	Path string
+
+	// Required y Available son los bytes requeridos y disponibles
+	Required  uint64
+	Available uint64
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("%v en %s: se requieren %d bytes, hay %d disponibles", ErrInsufficientSpace, e.Path, e.Required, e.Available)
+}
+
+// Unwrap permite usar errors.Is(err, ErrInsufficientSpace)
+func (e *InsufficientSpaceError) Unwrap() error {
+	return ErrInsufficientSpace
+}
+
+// VolumeInfo describe el volumen que contiene una ruta
+type VolumeInfo struct {
+	// Device identifica el volumen: dos rutas con el mismo Device están en el
+	// mismo sistema de archivos
+	Device uint64
+
+	// Available son los bytes libres para el usuario actual
+	Available uint64
+}
+
+// spaceCheck acumula el espacio requerido por volumen
+type spaceCheck struct {
+	u       *Updater
+	volumes []*volumeNeed
+}
+
+// volumeNeed es el espacio requerido en un volumen
+type volumeNeed struct {
+	path     string
+	info     VolumeInfo
+	required uint64
+}
+
+// add suma bytes al volumen que contiene path (o su primer directorio
+// padre existente) y retorna su información
+func (c *spaceCheck) add(path string, bytes uint64) (VolumeInfo, error) {
+	path = c.u.existingAncestor(path)
+	info, err := c.u.config.FileSystem.StatVolume(path)
+	if err != nil {
+		return VolumeInfo{}, fmt.Errorf("error consultando espacio libre en %s: %w", path, err)
+	}
+
+	for _, volume := range c.volumes {
+		if volume.info.Device == info.Device {
+			volume.required += bytes
+			return info, nil
+		}
+	}
+	c.volumes = append(c.volumes, &volumeNeed{path: path, info: info, required: bytes})
+	return info, nil
+}
+
+// verify retorna *InsufficientSpaceError si algún volumen no tiene el
+// espacio acumulado
+func (c *spaceCheck) verify() error {
+	for _, volume := range c.volumes {
+		if volume.required > volume.info.Available {
+			return &InsufficientSpaceError{
+				Path:      volume.path,
+				Required:  volume.required,
+				Available: volume.info.Available,
+			}
+		}
+	}
+	return nil
+}
+
+// checkDownloadSpace verifica que DownloadPath tiene espacio para los ZIP
+// anunciados en el manifiesto y su contenido descomprimido. Si el manifiesto
+// no informa tamaños no hay nada que verificar
+func (u *Updater) checkDownloadSpace(manifest *Manifest) error {
+	required := uint64(manifest.Size + manifest.InstalledSize)
+	for _, component := range manifest.Components {
+		required += uint64(component.Size + component.InstalledSize)
+	}
+	if required == 0 {
+		return nil
+	}
+
+	check := &spaceCheck{u: u}
+	if _, err := check.add(u.config.DownloadPath, required); err != nil {
+		return err
+	}
+	return check.verify()
+}
+
+// checkApplySpace verifica el espacio para descomprimir los ZIP descargados
+// (tamaño según su directorio central) y para copiar cada elemento junto a su
+// destino cuando está en otro volumen. El backup es un rename en el volumen
+// de destino y no ocupa espacio adicional
+func (u *Updater) checkApplySpace(manifest *Manifest, zipPath, currentAppPath string) error {
+	type payload struct {
+		zipPath     string
+		installPath string
+	}
+	payloads := []payload{{zipPath, currentAppPath}}
+	if manifest != nil {
+		for _, component := range manifest.Components {
+			payloads = append(payloads, payload{u.componentZipPath(component.Name), cleanComponentPath(component.InstallPath)})
+		}
+	}
+
+	check := &spaceCheck{u: u}
+	for _, p := range payloads {
+		size, err := utils.UncompressedSize(p.zipPath)
+		if err != nil {
+			return err
+		}
+
+		downloads, err := check.add(u.config.DownloadPath, size)
+		if err != nil {
+			return err
+		}
+
+		targetDir := u.existingAncestor(filepath.Dir(p.installPath))
+		target, err := u.config.FileSystem.StatVolume(targetDir)
+		if err != nil {
+			return fmt.Errorf("error consultando espacio libre en %s: %w", targetDir, err)
+		}
+		if target.Device != downloads.Device {
+			if _, err := check.add(targetDir, size); err != nil {
+				return err
+			}
+		}
+	}
+	return check.verify()
+}
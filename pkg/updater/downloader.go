@@ -1,101 +1,268 @@
 package updater
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
-
-	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/utils"
+	"strings"
 )
 
-// DownloadUpdate descarga la actualización y valida su integridad
+// DownloadUpdate descarga la actualización (y sus componentes) y valida su integridad
 // Retorna error si:
 //   - No se ha llamado a CheckForUpdate() previamente
 //   - La descarga falla
-//   - El checksum no coincide (el archivo se elimina automáticamente)
+//   - El checksum no coincide (el archivo nunca llega a la ruta final)
 func (u *Updater) DownloadUpdate() error {
-	// Verificar que tenemos un manifiesto
-	if u.manifest == nil {
+	// Verificar que tenemos un manifiesto. La descarga usa esta copia aunque
+	// otra goroutine vuelva a llamar a CheckForUpdate
+	manifest := u.currentManifest()
+	if manifest == nil {
 		// Intentar obtener el manifiesto primero
 		hasUpdate, _, err := u.CheckForUpdate()
 		if err != nil {
 			return fmt.Errorf("error verificando actualización: %w", err)
 		}
-		if !hasUpdate {
+		manifest = u.currentManifest()
+		if !hasUpdate || manifest == nil {
 			return fmt.Errorf("no hay actualización disponible")
 		}
 	}
 
-	// Asegurar que existe el directorio de descarga
-	if err := u.ensureDownloadPath(); err != nil {
+	if err := u.validateComponents(manifest.Components); err != nil {
 		return err
 	}
 
-	// Determinar nombre del archivo y ruta de descarga
-	zipPath := filepath.Join(u.config.DownloadPath, u.config.ZipFileName)
+	// Evitar que otra instancia descargue o aplique a la vez
+	lock, err := u.lock()
+	if err != nil {
+		return err
+	}
+	defer lock.release()
 
-	// Construir URL de descarga: SourceURL + ZipFileName
-	downloadURL := u.config.SourceURL + u.config.ZipFileName
+	// Determinar cómo se validará el archivo (digest y/o firma EdDSA)
+	check, err := newDownloadCheck(manifest, u.config.EdDSAPublicKey)
+	if err != nil {
+		return err
+	}
 
-	// Descargar el archivo
-	fmt.Printf("Descargando actualización desde: %s\n", downloadURL)
-	if err := downloadFile(downloadURL, zipPath); err != nil {
-		return fmt.Errorf("error descargando actualización: %w", err)
+	// Asegurar que existe el directorio de descarga
+	if err := u.ensureDownloadPath(); err != nil {
+		return err
+	}
+
+	// Verificar el espacio libre antes de descargar
+	if err := u.checkDownloadSpace(manifest); err != nil {
+		return err
 	}
 
-	// Validar checksum SHA-256
-	fmt.Println("Validando integridad del archivo...")
-	valid, err := utils.VerifyChecksum(zipPath, u.manifest.Checksum)
+	// Determinar ruta de descarga
+	zipPath := u.zipPath(manifest)
+
+	// Construir URL de descarga: la del manifiesto o SourceURL + ZipFileName
+	downloadURL, err := u.payloadURL(manifest)
 	if err != nil {
-		// Error calculando checksum, eliminar archivo
-		os.Remove(zipPath)
-		return fmt.Errorf("error validando checksum: %w", err)
+		return err
 	}
 
-	if !valid {
-		// Checksum no coincide, eliminar archivo inmediatamente
-		os.Remove(zipPath)
-		return fmt.Errorf("checksum mismatch: el archivo descargado está corrupto o fue manipulado")
+	// Descargar el archivo validándolo mientras se escribe
+	fmt.Printf("Descargando actualización desde: %s\n", downloadURL)
+	if err := downloadFile(downloadURL, zipPath, check); err != nil {
+		if errors.Is(err, ErrChecksumMismatch) {
+			// Eliminar cualquier descarga previa que haya quedado en la ruta final
+			os.Remove(zipPath)
+		}
+		return fmt.Errorf("error descargando actualización: %w", err)
 	}
 
 	fmt.Println("Checksum validado correctamente")
 
+	// Descargar los componentes adicionales
+	if err := u.downloadComponents(manifest); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-// downloadFile descarga un archivo desde una URL y lo guarda en disco
-func downloadFile(url, destPath string) error {
-	// Crear archivo de destino
-	out, err := os.Create(destPath)
+// downloadFile descarga un archivo desde una URL calculando su digest mientras
+// se escribe en un archivo parcial. Solo si pasa las validaciones se renombra a
+// destPath, de modo que nunca queda un archivo parcial o corrupto en la ruta final.
+// Si la conexión se corta, el archivo parcial se conserva y el próximo intento
+// pide solo lo que falta (Range), siempre que el servidor haya enviado un ETag
+// fuerte y el archivo no haya cambiado (If-Range)
+func downloadFile(url, destPath string, check *downloadCheck) error {
+	partPath := partialPath(destPath)
+	offset, etag := resumableOffset(url, destPath, check)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return fmt.Errorf("error creando archivo de destino: %w", err)
+		return fmt.Errorf("error en petición HTTP: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", etag)
 	}
-	defer out.Close()
 
 	// Realizar petición HTTP
-	resp, err := http.Get(url)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("error en petición HTTP: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp) == offset:
+		fmt.Printf("Reanudando descarga desde %.2f MB\n", float64(offset)/(1024*1024))
+	case offset > 0 && (resp.StatusCode == http.StatusRequestedRangeNotSatisfiable || resp.StatusCode == http.StatusPartialContent):
+		// El archivo parcial no corresponde al del servidor: empezar de nuevo
+		resp.Body.Close()
+		removePartial(destPath)
+		return downloadFile(url, destPath, check)
+	case resp.StatusCode == http.StatusOK:
+		// Sin Range, o el archivo cambió (If-Range): se descarga completo
+		offset = 0
+	default:
 		return fmt.Errorf("error HTTP %d descargando archivo", resp.StatusCode)
 	}
 
-	// Copiar contenido al archivo
-	written, err := io.Copy(out, resp.Body)
+	payload, err := check.newWriter()
+	if err != nil {
+		return err
+	}
+
+	// El archivo parcial está en el mismo directorio para que el rename sea atómico
+	out, err := os.OpenFile(partPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("error creando archivo temporal: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			out.Close()
+		}
+	}()
+
+	if offset > 0 {
+		// Calcular el digest de lo ya descargado: es lo único que se vuelve a leer
+		if _, err := io.CopyN(payload, out, offset); err != nil {
+			removePartial(destPath)
+			return fmt.Errorf("error leyendo descarga parcial: %w", err)
+		}
+	} else if err := out.Truncate(0); err != nil {
+		return fmt.Errorf("error creando archivo temporal: %w", err)
+	}
+
+	// Guardar el ETag para poder reanudar si la conexión se corta
+	resumable := writePartialInfo(destPath, url, resp.Header.Get("ETag"))
+
+	// Copiar contenido al archivo, al hasher y a la firma en una sola pasada
+	written, err := io.Copy(io.MultiWriter(out, payload), resp.Body)
+	written += offset
 	if err != nil {
+		if !resumable {
+			out.Close()
+			removePartial(destPath)
+		}
 		return fmt.Errorf("error escribiendo archivo: %w", err)
 	}
 
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("error cerrando archivo: %w", err)
+	}
+	committed = true
+
 	fmt.Printf("Descargados %.2f MB\n", float64(written)/(1024*1024))
 
+	// Validar tamaño, digest y firma antes de mover el archivo a la ruta final.
+	// Un archivo que no pasa las validaciones no se puede reanudar
+	if err := check.verify(written, payload); err != nil {
+		removePartial(destPath)
+		return err
+	}
+
+	if err := os.Rename(partPath, destPath); err != nil {
+		return fmt.Errorf("error moviendo archivo descargado: %w", err)
+	}
+	removePartial(destPath)
+
 	return nil
 }
 
+// partialDownload describe una descarga parcial reanudable: la URL y el ETag
+// con que se pidió, para pedir el resto solo si el archivo no cambió
+type partialDownload struct {
+	URL  string `json:"url"`
+	ETag string `json:"etag"`
+}
+
+// partialPath retorna la ruta de la descarga parcial de destPath
+func partialPath(destPath string) string {
+	return filepath.Join(filepath.Dir(destPath), "."+filepath.Base(destPath)+".part")
+}
+
+// partialInfoPath retorna la ruta del partialDownload de destPath
+func partialInfoPath(destPath string) string {
+	return partialPath(destPath) + ".json"
+}
+
+// removePartial elimina la descarga parcial de destPath
+func removePartial(destPath string) {
+	os.Remove(partialPath(destPath))
+	os.Remove(partialInfoPath(destPath))
+}
+
+// resumableOffset retorna cuántos bytes de una descarga parcial anterior de url
+// se pueden reutilizar y el ETag con que se descargaron (0 si no hay)
+func resumableOffset(url, destPath string, check *downloadCheck) (int64, string) {
+	data, err := os.ReadFile(partialInfoPath(destPath))
+	if err != nil {
+		return 0, ""
+	}
+	var partial partialDownload
+	if err := json.Unmarshal(data, &partial); err != nil || partial.URL != url || !strongETag(partial.ETag) {
+		return 0, ""
+	}
+
+	info, err := os.Stat(partialPath(destPath))
+	if err != nil || info.Size() == 0 || (check.size > 0 && info.Size() >= check.size) {
+		return 0, ""
+	}
+	return info.Size(), partial.ETag
+}
+
+// writePartialInfo guarda el ETag de la descarga en curso. Retorna false si la
+// descarga no se podrá reanudar (sin ETag fuerte)
+func writePartialInfo(destPath, url, etag string) bool {
+	if !strongETag(etag) {
+		os.Remove(partialInfoPath(destPath))
+		return false
+	}
+	data, err := json.Marshal(partialDownload{URL: url, ETag: etag})
+	if err != nil {
+		return false
+	}
+	return os.WriteFile(partialInfoPath(destPath), data, 0644) == nil
+}
+
+// strongETag indica si el ETag sirve para If-Range (los débiles no)
+func strongETag(etag string) bool {
+	return strings.HasPrefix(etag, `"`)
+}
+
+// contentRangeStart retorna el primer byte de una respuesta 206 (-1 si no se
+// puede leer el Content-Range)
+func contentRangeStart(resp *http.Response) int64 {
+	var start int64
+	if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start); err != nil {
+		return -1
+	}
+	return start
+}
+
 // IsDownloaded verifica si ya existe una actualización descargada
 func (u *Updater) IsDownloaded() bool {
 	zipPath := u.GetZipPath()
@@ -114,9 +281,20 @@ func (u *Updater) CleanDownload() error {
 		return nil
 	}
 
+	lock, err := u.lock()
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
 	if err := os.Remove(zipPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("error eliminando archivo de actualización: %w", err)
 	}
+	removePartial(zipPath)
+
+	if err := os.RemoveAll(u.componentsPath()); err != nil {
+		return fmt.Errorf("error eliminando componentes descargados: %w", err)
+	}
 
 	return nil
 }
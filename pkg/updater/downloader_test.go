@@ -0,0 +1,402 @@
+package updater
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/releaseserver"
+)
+
+func TestPayloadURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		zipFileName string
+		manifestURL string
+		want        string
+	}{
+		{"legacy usa ZipFileName", "myapp.zip", "", "https://cdn.example.com/updates/myapp.zip"},
+		{"relativa a SourceURL", "myapp.zip", "releases/1.2.3/myapp.zip", "https://cdn.example.com/updates/releases/1.2.3/myapp.zip"},
+		{"absoluta", "", "https://other.example.com/myapp-1.2.3.zip", "https://other.example.com/myapp-1.2.3.zip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := New(Config{SourceURL: "https://cdn.example.com/updates", ZipFileName: tt.zipFileName})
+			u.manifest = &Manifest{Version: "1.2.3", URL: tt.manifestURL}
+
+			got, err := u.payloadURL(u.manifest)
+			if err != nil {
+				t.Fatalf("payloadURL: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("payloadURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	u := New(Config{SourceURL: "https://cdn.example.com/updates"})
+	u.manifest = &Manifest{Version: "1.2.3"}
+	if _, err := u.payloadURL(u.manifest); err == nil {
+		t.Error("payloadURL debería fallar sin url ni ZipFileName")
+	}
+}
+
+func TestDownloadUpdateUsesVersionedPayload(t *testing.T) {
+	payload := []byte("zip 1.2.3")
+	sum := sha256.Sum256(payload)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/updates/releases/1.2.3/myapp.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(payload)
+	}))
+	defer server.Close()
+
+	downloadPath := t.TempDir()
+	u := New(Config{SourceURL: server.URL + "/updates/", DownloadPath: downloadPath})
+	u.manifest = &Manifest{
+		Version:  "1.2.3",
+		Checksum: hex.EncodeToString(sum[:]),
+		URL:      "releases/1.2.3/myapp.zip",
+	}
+
+	if err := u.DownloadUpdate(); err != nil {
+		t.Fatalf("DownloadUpdate: %v", err)
+	}
+
+	if got := u.GetZipPath(); got != filepath.Join(downloadPath, "myapp.zip") {
+		t.Errorf("GetZipPath() = %q", got)
+	}
+	data, err := os.ReadFile(u.GetZipPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(payload) {
+		t.Errorf("contenido descargado = %q", data)
+	}
+}
+
+func TestDownloadUpdateFaults(t *testing.T) {
+	payload := make([]byte, 64*1024)
+	sum := sha256.Sum256(payload)
+
+	releases := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(releases, "releases", "1.2.3"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(releases, "releases", "1.2.3", "myapp.zip"), payload, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		faults   releaseserver.Faults
+		checksum bool
+		// partial indica que la descarga se conserva para reanudarla
+		partial bool
+	}{
+		{"checksum incorrecto", releaseserver.Faults{Corrupt: true}, true, false},
+		{"descarga truncada", releaseserver.Faults{TruncateAfter: 1024}, false, true},
+		{"error del servidor", releaseserver.Faults{FailFirst: 1}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(releaseserver.New(releases, tt.faults))
+			defer server.Close()
+
+			downloadPath := t.TempDir()
+			u := New(Config{SourceURL: server.URL, DownloadPath: downloadPath, ZipFileName: "myapp.zip"})
+			u.manifest = &Manifest{
+				Version:  "1.2.3",
+				Checksum: hex.EncodeToString(sum[:]),
+				URL:      "releases/1.2.3/myapp.zip",
+			}
+
+			err := u.DownloadUpdate()
+			if err == nil {
+				t.Fatal("DownloadUpdate debería fallar")
+			}
+			if tt.checksum != errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("error inesperado: %v", err)
+			}
+
+			// El archivo de lock se conserva entre operaciones
+			entries, _ := os.ReadDir(downloadPath)
+			var leftovers []string
+			for _, entry := range entries {
+				if entry.Name() != lockFileName {
+					leftovers = append(leftovers, entry.Name())
+				}
+			}
+			var want []string
+			if tt.partial {
+				want = []string{".myapp.zip.part", ".myapp.zip.part.json"}
+			}
+			if !reflect.DeepEqual(leftovers, want) {
+				t.Errorf("la descarga fallida dejó %v, want %v", leftovers, want)
+			}
+			if u.IsDownloaded() {
+				t.Error("IsDownloaded no debería reportar una descarga fallida")
+			}
+		})
+	}
+}
+
+// resumeServer sirve payload con ETag y Range, y corta la primera respuesta
+// tras truncateAfter bytes
+type resumeServer struct {
+	mu            sync.Mutex
+	payload       []byte
+	etag          string
+	truncateAfter int
+	ranges        []string
+	statuses      []int
+}
+
+func (s *resumeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	payload, etag, truncate := s.payload, s.etag, s.truncateAfter
+	s.truncateAfter = 0
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.mu.Unlock()
+
+	w.Header().Set("ETag", etag)
+	if truncate > 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+		w.Write(payload[:truncate])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	http.ServeContent(&statusCapture{ResponseWriter: w, server: s}, r, "myapp.zip", time.Time{}, bytes.NewReader(payload))
+}
+
+// requests retorna los Range pedidos y los códigos de las respuestas completas
+func (s *resumeServer) requests() ([]string, []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ranges...), append([]int(nil), s.statuses...)
+}
+
+func (s *resumeServer) setPayload(payload []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sum := sha256.Sum256(payload)
+	s.payload, s.etag = payload, `"`+hex.EncodeToString(sum[:])+`"`
+}
+
+// statusCapture registra en el servidor el código de la respuesta
+type statusCapture struct {
+	http.ResponseWriter
+	server *resumeServer
+}
+
+func (c *statusCapture) WriteHeader(status int) {
+	c.server.mu.Lock()
+	c.server.statuses = append(c.server.statuses, status)
+	c.server.mu.Unlock()
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// signedCheck arma las validaciones de payload: sha512, tamaño y firma EdDSA
+func signedCheck(t *testing.T, payload []byte) *downloadCheck {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha512.Sum512(payload)
+	check, err := newDownloadCheck(&Manifest{
+		Version:     "1.2.3",
+		Digests:     map[string]string{"sha512": hex.EncodeToString(sum[:])},
+		Size:        int64(len(payload)),
+		EdSignature: base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, payload)),
+	}, base64.StdEncoding.EncodeToString(publicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return check
+}
+
+func randomPayload(t *testing.T, size int) []byte {
+	t.Helper()
+	payload := make([]byte, size)
+	if _, err := rand.Read(payload); err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestDownloadFileHashesWhileStreaming(t *testing.T) {
+	payload := randomPayload(t, 256*1024)
+	destPath := filepath.Join(t.TempDir(), "myapp.zip")
+
+	// El servidor envía todo el cuerpo y espera antes de terminar la respuesta.
+	// Mientras tanto se altera el archivo parcial en disco: si la validación
+	// volviera a leer el archivo fallaría; como usa el digest calculado al
+	// recibir los datos, pasa
+	sent := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payload)
+		w.(http.Flusher).Flush()
+		close(sent)
+		<-release
+	}))
+	defer server.Close()
+
+	done := make(chan error)
+	go func() { done <- downloadFile(server.URL+"/myapp.zip", destPath, signedCheck(t, payload)) }()
+
+	<-sent
+	partPath := partialPath(destPath)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if info, err := os.Stat(partPath); err == nil && info.Size() == int64(len(payload)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("el archivo parcial no llegó a recibir todo el cuerpo")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	file, err := os.OpenFile(partPath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteAt([]byte{payload[0] ^ 1}, 0); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Fatalf("downloadFile: %v", err)
+	}
+	data, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] == payload[0] {
+		t.Error("el archivo final debería conservar la alteración hecha en disco")
+	}
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	payload := randomPayload(t, 256*1024)
+	tests := []struct {
+		name string
+		// between se ejecuta entre el intento interrumpido y el reintento
+		between func(t *testing.T, s *resumeServer, destPath string)
+		// wantStatus es la respuesta del reintento: 206 si se reanudó
+		wantStatus int
+		wantErr    error
+	}{
+		{
+			name:       "reanuda lo que falta",
+			wantStatus: http.StatusPartialContent,
+		},
+		{
+			name: "el archivo cambió en el servidor",
+			between: func(t *testing.T, s *resumeServer, destPath string) {
+				// If-Range no coincide: el servidor envía el archivo completo
+				s.mu.Lock()
+				s.etag = `"otro"`
+				s.mu.Unlock()
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "descarga parcial alterada",
+			between: func(t *testing.T, s *resumeServer, destPath string) {
+				data, err := os.ReadFile(partialPath(destPath))
+				if err != nil {
+					t.Fatal(err)
+				}
+				data[10] ^= 1
+				if err := os.WriteFile(partialPath(destPath), data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantStatus: http.StatusPartialContent,
+			wantErr:    ErrChecksumMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &resumeServer{truncateAfter: 100 * 1024}
+			s.setPayload(payload)
+			server := httptest.NewServer(s)
+			defer server.Close()
+
+			url := server.URL + "/myapp.zip"
+			destPath := filepath.Join(t.TempDir(), "myapp.zip")
+			check := signedCheck(t, payload)
+
+			if err := downloadFile(url, destPath, check); err == nil {
+				t.Fatal("el primer intento debería fallar")
+			}
+			if _, err := os.Stat(destPath); !os.IsNotExist(err) {
+				t.Fatal("una descarga interrumpida no debería llegar a la ruta final")
+			}
+			if tt.between != nil {
+				tt.between(t, s, destPath)
+			}
+
+			err := downloadFile(url, destPath, check)
+			ranges, statuses := s.requests()
+			if ranges[1] != "bytes=102400-" || len(statuses) != 1 || statuses[0] != tt.wantStatus {
+				t.Errorf("reintento: Range = %q, respuestas = %v, want %d", ranges[1], statuses, tt.wantStatus)
+			}
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("downloadFile = %v, want %v", err, tt.wantErr)
+				}
+				// La descarga inválida se descarta y el siguiente intento empieza de cero
+				for _, path := range []string{destPath, partialPath(destPath), partialInfoPath(destPath)} {
+					if _, err := os.Stat(path); !os.IsNotExist(err) {
+						t.Errorf("quedó %s", filepath.Base(path))
+					}
+				}
+				if err := downloadFile(url, destPath, check); err != nil {
+					t.Fatalf("descarga desde cero: %v", err)
+				}
+				if ranges, _ := s.requests(); ranges[2] != "" {
+					t.Errorf("Range de la descarga desde cero = %q", ranges[2])
+				}
+			} else if err != nil {
+				t.Fatalf("downloadFile: %v", err)
+			}
+
+			data, err := os.ReadFile(destPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, payload) {
+				t.Error("el archivo descargado no coincide con el payload")
+			}
+			if _, err := os.Stat(partialPath(destPath)); !os.IsNotExist(err) {
+				t.Error("la descarga completa dejó el archivo parcial")
+			}
+		})
+	}
+}
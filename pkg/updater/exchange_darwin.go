@@ -0,0 +1,8 @@
+package updater
+
+import "golang.org/x/sys/unix"
+
+// Exchange usa renamex_np con RENAME_SWAP (APFS y HFS+)
+func (OSFileSystem) Exchange(oldpath, newpath string) error {
+	return unix.RenamexNp(oldpath, newpath, unix.RENAME_SWAP)
+}
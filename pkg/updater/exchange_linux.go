@@ -0,0 +1,8 @@
+package updater
+
+import "golang.org/x/sys/unix"
+
+// Exchange usa renameat2 con RENAME_EXCHANGE (Linux 3.15+)
+func (OSFileSystem) Exchange(oldpath, newpath string) error {
+	return unix.Renameat2(unix.AT_FDCWD, oldpath, unix.AT_FDCWD, newpath, unix.RENAME_EXCHANGE)
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+
+package updater
+
+import "syscall"
+
+// Exchange no está disponible: el instalador usa rename
+func (OSFileSystem) Exchange(oldpath, newpath string) error {
+	return syscall.ENOTSUP
+}
@@ -0,0 +1,110 @@
+package updater
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// feedCache es la última copia del manifiesto (o appcast) descargada, con los
+// validadores HTTP para volver a pedirla de forma condicional
+type feedCache struct {
+	// URL es la dirección del feed cacheado
+	URL string `json:"url"`
+
+	// ETag y LastModified son los validadores de la última respuesta
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+
+	// Body es el contenido del feed y Signature su firma separada (si
+	// Config.ManifestPublicKey está definida)
+	Body      string `json:"body"`
+	Signature string `json:"signature,omitempty"`
+
+	// Expires es hasta cuándo se puede usar sin consultar al servidor
+	// (max-age de Cache-Control)
+	Expires time.Time `json:"expires"`
+}
+
+// feedResponse es la respuesta de una consulta, posiblemente condicional, de
+// un manifiesto, appcast o firma
+type feedResponse struct {
+	notModified  bool
+	body         []byte
+	etag         string
+	lastModified string
+	maxAge       time.Duration
+	noStore      bool
+}
+
+// cacheable indica si la respuesta se puede guardar: el servidor no lo
+// prohíbe y permite revalidarla o reutilizarla por un tiempo
+func (r *feedResponse) cacheable() bool {
+	return !r.noStore && (r.etag != "" || r.lastModified != "" || r.maxAge > 0)
+}
+
+// parseCacheControl retorna el max-age de la respuesta y si prohíbe guardarla.
+// no-cache obliga a revalidar siempre (max-age 0). Age descuenta el tiempo que
+// la respuesta ya pasó en caches intermedios (CDN)
+func parseCacheControl(cacheControl, age string) (maxAge time.Duration, noStore bool) {
+	noCache := false
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			noStore = true
+		case "no-cache":
+			noCache = true
+		case "max-age":
+			if seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64); err == nil && seconds > 0 {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if noCache || noStore {
+		return 0, noStore
+	}
+
+	if seconds, err := strconv.ParseInt(strings.TrimSpace(age), 10, 64); err == nil && seconds > 0 {
+		maxAge -= time.Duration(seconds) * time.Second
+	}
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	return maxAge, false
+}
+
+// cachedFeed retorna la copia cacheada de feedURL, o nil si no hay o ya no es
+// válida (otra URL o firma que no verifica con la clave configurada)
+func (u *Updater) cachedFeed(feedURL string) *feedCache {
+	state, err := u.loadState()
+	if err != nil || state.Feed == nil || state.Feed.URL != feedURL {
+		return nil
+	}
+
+	cached := state.Feed
+	if u.config.ManifestPublicKey != "" {
+		if cached.Signature == "" {
+			return nil
+		}
+		if err := VerifyManifestSignature([]byte(cached.Body), []byte(cached.Signature), u.config.ManifestPublicKey); err != nil {
+			return nil
+		}
+	}
+	return cached
+}
+
+// saveFeedCache persiste la copia cacheada del feed (nil la elimina). No
+// poder guardarla no impide la verificación: solo se pierde el caché
+func (u *Updater) saveFeedCache(cached *feedCache) {
+	u.stateMu.Lock()
+	defer u.stateMu.Unlock()
+
+	err := updateStateFile(u.statePath(), func(state *updaterState) {
+		state.Feed = cached
+	})
+	if err != nil {
+		fmt.Printf("No se pudo guardar el manifiesto en caché: %v\n", err)
+	}
+}
@@ -0,0 +1,30 @@
+package updater
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		age          string
+		maxAge       time.Duration
+		noStore      bool
+	}{
+		{"", "", 0, false},
+		{"no-cache", "", 0, false},
+		{"public, max-age=300", "", 5 * time.Minute, false},
+		{"max-age=300", "120", 3 * time.Minute, false},
+		{"max-age=300", "600", 0, false},
+		{"max-age=300, no-cache", "", 0, false},
+		{"no-store", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		maxAge, noStore := parseCacheControl(tt.cacheControl, tt.age)
+		if maxAge != tt.maxAge || noStore != tt.noStore {
+			t.Errorf("parseCacheControl(%q, %q) = %s, %t, want %s, %t", tt.cacheControl, tt.age, maxAge, noStore, tt.maxAge, tt.noStore)
+		}
+	}
+}
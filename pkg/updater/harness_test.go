@@ -0,0 +1,1816 @@
+package updater
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/bundle"
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/releaseserver"
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/utils"
+)
+
+// fakeSystem registra las llamadas al sistema operativo en lugar de hacerlas.
+// Implementa FileSystem (sobre el disco real, salvo la cuarentena) y
+// ProcessLauncher (los comandos de shell se ejecutan de verdad)
+type fakeSystem struct {
+	OSFileSystem
+	OSProcessLauncher
+
+	mu         sync.Mutex
+	exe        string
+	alive      map[int]int
+	appRunning int
+	children   map[int][]int
+	ignoreTerm map[int]bool
+	signals    []string
+
+	// otherVolume simula que las rutas con ese prefijo están en otro volumen
+	otherVolume string
+	// failRenameFrom hace fallar los rename cuyo origen contiene ese texto
+	failRenameFrom string
+	noExchange     bool
+	readOnly       map[string]bool
+	volumes        map[string]VolumeInfo
+	opened         []string
+	quarantined    []string
+	detached       [][]string
+}
+
+func (s *fakeSystem) Getpid() int { return 1234 }
+
+func (s *fakeSystem) Executable() (string, error) { return s.exe, nil }
+
+// ProcessAlive simula procesos que siguen vivos durante alive[pid] consultas
+func (s *fakeSystem) ProcessAlive(pid int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.alive[pid] > 0 {
+		s.alive[pid]--
+		return true
+	}
+	return false
+}
+
+// ProcessesForApp simula un proceso del bundle durante appRunning consultas
+func (s *fakeSystem) ProcessesForApp(appPath string) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.appRunning > 0 {
+		s.appRunning--
+		return []int{999}, nil
+	}
+	return nil, nil
+}
+
+// Rename falla con EXDEV si el origen está en otro volumen, como rename(2),
+// y con EIO si el origen contiene failRenameFrom
+func (s *fakeSystem) Rename(oldpath, newpath string) error {
+	if s.failRenameFrom != "" && strings.Contains(oldpath, s.failRenameFrom) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EIO}
+	}
+	if s.otherVolume != "" && strings.HasPrefix(oldpath, s.otherVolume) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+func (s *fakeSystem) Exchange(oldpath, newpath string) error {
+	if s.noExchange {
+		return syscall.ENOTSUP
+	}
+	return s.OSFileSystem.Exchange(oldpath, newpath)
+}
+
+func (s *fakeSystem) Writable(path string) bool {
+	return !s.readOnly[path]
+}
+
+// StatVolume simula volúmenes montados en los prefijos de volumes
+func (s *fakeSystem) StatVolume(path string) (VolumeInfo, error) {
+	for prefix, info := range s.volumes {
+		if path == prefix || strings.HasPrefix(path, prefix+string(filepath.Separator)) {
+			return info, nil
+		}
+	}
+	return s.OSFileSystem.StatVolume(path)
+}
+
+func (s *fakeSystem) ChildProcesses(pid int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.children[pid], nil
+}
+
+// Signal registra la señal; el proceso termina salvo que ignore SIGTERM
+func (s *fakeSystem) Signal(pid int, sig syscall.Signal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signals = append(s.signals, fmt.Sprintf("%d:%s", pid, signalNames[sig]))
+	if sig == syscall.SIGKILL || !s.ignoreTerm[pid] {
+		delete(s.alive, pid)
+	}
+	return nil
+}
+
+func (s *fakeSystem) ClearQuarantine(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quarantined = append(s.quarantined, path)
+	return nil
+}
+
+func (s *fakeSystem) Open(appPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opened = append(s.opened, appPath)
+	return nil
+}
+
+func (s *fakeSystem) StartDetached(name string, env []string, logPath string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.detached = append(s.detached, env)
+	return 4242, nil
+}
+
+// harness arma una app instalada falsa, un directorio de releases servido por
+// HTTP y un Updater apuntando a ambos
+type harness struct {
+	t            *testing.T
+	sys          *fakeSystem
+	installedApp string
+	releases     string
+	handler      http.Handler
+	handlerMu    sync.Mutex
+	server       *httptest.Server
+	updater      *Updater
+}
+
+const harnessIdentifier = "com.joobpay.harness"
+
+func newHarness(t *testing.T, installedVersion string) *harness {
+	t.Helper()
+	root := t.TempDir()
+
+	h := &harness{
+		t:            t,
+		installedApp: writeFakeApp(t, filepath.Join(root, "Applications"), installedVersion),
+		releases:     filepath.Join(root, "releases"),
+	}
+	h.sys = &fakeSystem{exe: filepath.Join(h.installedApp, "Contents", "MacOS", "MyApp")}
+	h.setFaults(releaseserver.Faults{})
+
+	h.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.handlerMu.Lock()
+		handler := h.handler
+		h.handlerMu.Unlock()
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(h.server.Close)
+
+	h.updater = New(Config{
+		CurrentVersion:     installedVersion,
+		SourceURL:          h.server.URL,
+		DownloadPath:       filepath.Join(root, "downloads"),
+		StartAutomatically: true,
+		FileSystem:         h.sys,
+		ProcessLauncher:    h.sys,
+	})
+
+	return h
+}
+
+// setFaults reemplaza las fallas que inyecta el servidor de releases
+func (h *harness) setFaults(faults releaseserver.Faults) {
+	h.handlerMu.Lock()
+	defer h.handlerMu.Unlock()
+	h.handler = releaseserver.New(h.releases, faults)
+}
+
+// writeFakeApp crea MyApp.app con Info.plist y un ejecutable en dir
+func writeFakeApp(t *testing.T, dir, version string) string {
+	t.Helper()
+	appPath := filepath.Join(dir, "MyApp.app")
+	if err := os.MkdirAll(filepath.Join(appPath, "Contents", "MacOS"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	plist := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>CFBundleIdentifier</key>
+	<string>%s</string>
+	<key>CFBundleShortVersionString</key>
+	<string>%s</string>
+	<key>CFBundleVersion</key>
+	<string>%s</string>
+	<key>CFBundleExecutable</key>
+	<string>MyApp</string>
+</dict>
+</plist>
+`, harnessIdentifier, version, version)
+	if err := os.WriteFile(bundle.InfoPlistPath(appPath), []byte(plist), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(appPath, "Contents", "MacOS", "MyApp"), []byte("#!/bin/sh\necho "+version+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return appPath
+}
+
+// publish genera el ZIP y el manifiesto de una release en el directorio servido
+func (h *harness) publish(version string, mutate func(*Manifest)) {
+	h.t.Helper()
+
+	appPath := writeFakeApp(h.t, h.t.TempDir(), version)
+	payloadPath := filepath.Join("releases", version, "MyApp.zip")
+	zipPath := filepath.Join(h.releases, payloadPath)
+	if err := os.MkdirAll(filepath.Dir(zipPath), 0755); err != nil {
+		h.t.Fatal(err)
+	}
+	if err := utils.ZipDirectory(appPath, zipPath); err != nil {
+		h.t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(zipPath)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+
+	manifest := &Manifest{
+		Version:  version,
+		Checksum: hex.EncodeToString(sum[:]),
+		URL:      filepath.ToSlash(payloadPath),
+		Size:     int64(len(data)),
+	}
+	if mutate != nil {
+		mutate(manifest)
+	}
+
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	manifestPath := filepath.Join(h.releases, fmt.Sprintf("darwin-%s.json", runtime.GOARCH))
+	if err := os.WriteFile(manifestPath, manifestData, 0644); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// install ejecuta en el proceso del test el plan que ApplyUpdate entregó al
+// instalador detached
+func (h *harness) install() (string, error) {
+	h.t.Helper()
+
+	if len(h.sys.detached) != 1 {
+		h.t.Fatalf("se esperaba un instalador detached, hubo %d", len(h.sys.detached))
+	}
+	return h.runPlan(h.sys.detached[0])
+}
+
+// runPlan ejecuta el plan indicado en env (JOOBPAY_UPDATER_INSTALL_PLAN=...)
+// como lo hace el init() del instalador
+func (h *harness) runPlan(env []string) (string, error) {
+	h.t.Helper()
+
+	plan, err := loadInstallPlan(envValue(env, installPlanEnv), envValue(env, installPlanDigestEnv))
+	if err != nil {
+		return "", err
+	}
+
+	var log bytes.Buffer
+	inst := newInstaller(plan, h.sys, h.sys, &log)
+	inst.pollInterval = 0
+	inst.settleDelay = 0
+	err = inst.run()
+	return log.String(), err
+}
+
+// envValue retorna el valor de name en env
+func envValue(env []string, name string) string {
+	for _, entry := range env {
+		if value, ok := strings.CutPrefix(entry, name+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// installedVersion retorna la versión del bundle instalado
+func (h *harness) installedVersion() string {
+	h.t.Helper()
+	info, err := bundle.ReadInfo(h.installedApp)
+	if err != nil {
+		h.t.Fatalf("la app instalada no es válida: %v", err)
+	}
+	return info.ShortVersion
+}
+
+// leftovers retorna los backups y copias preparadas que quedaron junto a la
+// app instalada
+func (h *harness) leftovers() []string {
+	matches, _ := filepath.Glob(siblingPath(h.installedApp, "*"))
+	return matches
+}
+
+func TestHarnessHappyPath(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	h.publish("1.1.0", nil)
+
+	hasUpdate, version, err := h.updater.CheckForUpdate()
+	if err != nil || !hasUpdate || version != "1.1.0" {
+		t.Fatalf("CheckForUpdate = %v, %q, %v", hasUpdate, version, err)
+	}
+	if err := h.updater.DownloadUpdate(); err != nil {
+		t.Fatalf("DownloadUpdate: %v", err)
+	}
+	if err := h.updater.ApplyUpdate(); err != nil {
+		t.Fatalf("ApplyUpdate: %v", err)
+	}
+
+	if log, err := h.install(); err != nil {
+		t.Fatalf("instalación: %v\n%s", err, log)
+	}
+
+	if got := h.installedVersion(); got != "1.1.0" {
+		t.Errorf("versión instalada = %s, want 1.1.0", got)
+	}
+	if h.updater.IsDownloaded() {
+		t.Error("el ZIP descargado debería eliminarse")
+	}
+	if backups := h.leftovers(); len(backups) != 0 {
+		t.Errorf("quedaron backups: %v", backups)
+	}
+	if len(h.sys.quarantined) != 1 {
+		t.Errorf("ClearQuarantine llamado %d veces", len(h.sys.quarantined))
+	}
+	if len(h.sys.opened) != 1 || h.sys.opened[0] != h.installedApp {
+		t.Errorf("Open = %v, want [%s]", h.sys.opened, h.installedApp)
+	}
+}
+
+func TestHarnessChecksumMismatch(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	h.publish("1.1.0", nil)
+	h.setFaults(releaseserver.Faults{Pattern: "*.zip", Corrupt: true})
+
+	if _, _, err := h.updater.CheckForUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updater.DownloadUpdate(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("DownloadUpdate = %v, want ErrChecksumMismatch", err)
+	}
+	if h.updater.IsDownloaded() {
+		t.Error("un ZIP corrupto no debería quedar en la ruta final")
+	}
+	if err := h.updater.ApplyUpdate(); err == nil {
+		t.Error("ApplyUpdate debería fallar sin una descarga válida")
+	}
+	if got := h.installedVersion(); got != "1.0.0" {
+		t.Errorf("versión instalada = %s, want 1.0.0", got)
+	}
+}
+
+func TestHarnessInterruptedDownload(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	h.publish("1.1.0", nil)
+	h.setFaults(releaseserver.Faults{Pattern: "*.zip", TruncateAfter: 64})
+
+	if _, _, err := h.updater.CheckForUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updater.DownloadUpdate(); err == nil {
+		t.Fatal("DownloadUpdate debería fallar con una descarga truncada")
+	}
+	// La descarga parcial queda fuera de la ruta final, para reanudarla
+	if h.updater.IsDownloaded() {
+		t.Error("IsDownloaded no debería reportar una descarga interrumpida")
+	}
+	if _, err := os.Stat(filepath.Join(h.updater.config.DownloadPath, ".MyApp.zip.part")); err != nil {
+		t.Errorf("la descarga interrumpida debería conservarse: %v", err)
+	}
+
+	// Reintentar sin fallas: se pide solo lo que falta
+	h.setFaults(releaseserver.Faults{})
+	var ranges []string
+	server := h.handler
+	h.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".zip") {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		server.ServeHTTP(w, r)
+	})
+	if err := h.updater.DownloadUpdate(); err != nil {
+		t.Fatalf("reintento de DownloadUpdate: %v", err)
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=64-" {
+		t.Errorf("Range del reintento = %q, want [bytes=64-]", ranges)
+	}
+	entries, _ := os.ReadDir(h.updater.config.DownloadPath)
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".part") {
+			t.Errorf("la descarga completa dejó %s", entry.Name())
+		}
+	}
+	if err := h.updater.ApplyUpdate(); err != nil {
+		t.Fatalf("ApplyUpdate: %v", err)
+	}
+	if log, err := h.install(); err != nil {
+		t.Fatalf("instalación: %v\n%s", err, log)
+	}
+	if got := h.installedVersion(); got != "1.1.0" {
+		t.Errorf("versión instalada = %s, want 1.1.0", got)
+	}
+}
+
+func TestHarnessRollbackOnFailedInstall(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	// Falla el swap con la versión nueva ya en su lugar (o el bundle instalado
+	// ya movido al backup, sin intercambio atómico)
+	h.sys.failRenameFrom = ".staging-"
+	h.publish("1.1.0", nil)
+
+	if _, _, err := h.updater.CheckForUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updater.DownloadUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updater.ApplyUpdate(); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := h.install()
+	if err == nil {
+		t.Fatal("la instalación debería fallar")
+	}
+	if !strings.Contains(log, "Rollback completado") {
+		t.Errorf("el log no registra el rollback:\n%s", log)
+	}
+	if got := h.installedVersion(); got != "1.0.0" {
+		t.Errorf("versión instalada = %s, want 1.0.0 (restaurada)", got)
+	}
+	if backups := h.leftovers(); len(backups) != 0 {
+		t.Errorf("quedaron backups: %v", backups)
+	}
+	if len(h.sys.opened) != 0 {
+		t.Errorf("no debería relanzarse tras un rollback: %v", h.sys.opened)
+	}
+}
+
+func TestHarnessAfterCommandFailure(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	h.updater.config.AfterUpdateCommand = `test -d "$CURRENT_APP_PATH" && exit 1`
+	h.publish("1.1.0", nil)
+
+	if _, _, err := h.updater.CheckForUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updater.DownloadUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updater.ApplyUpdate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// El comando posterior no revierte una actualización ya instalada
+	log, err := h.install()
+	if err != nil {
+		t.Fatalf("instalación: %v\n%s", err, log)
+	}
+	if !strings.Contains(log, "ERROR en comando posterior") || strings.Contains(log, "rollback") {
+		t.Errorf("el log debería registrar el error sin rollback:\n%s", log)
+	}
+	if got := h.installedVersion(); got != "1.1.0" {
+		t.Errorf("versión instalada = %s, want 1.1.0", got)
+	}
+	if backups := h.leftovers(); len(backups) != 0 {
+		t.Errorf("quedaron backups: %v", backups)
+	}
+	if result, err := h.updater.LastInstall(); err != nil || result == nil || !result.Success {
+		t.Errorf("LastInstall = %+v, %v, want exitoso", result, err)
+	}
+}
+
+func TestHarnessManifestRollback(t *testing.T) {
+	h := newHarness(t, "1.1.0")
+	h.publish("1.0.0", func(m *Manifest) { m.Rollback = true })
+
+	if _, _, err := h.updater.CheckForUpdate(); !errors.Is(err, ErrDowngradeRejected) {
+		t.Fatalf("CheckForUpdate sin AllowDowngrade = %v, want ErrDowngradeRejected", err)
+	}
+
+	h.updater.config.AllowDowngrade = true
+	hasUpdate, version, err := h.updater.CheckForUpdate()
+	if err != nil || !hasUpdate || version != "1.0.0" {
+		t.Fatalf("CheckForUpdate = %v, %q, %v", hasUpdate, version, err)
+	}
+	if err := h.updater.DownloadUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updater.ApplyUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if log, err := h.install(); err != nil {
+		t.Fatalf("instalación: %v\n%s", err, log)
+	}
+	if got := h.installedVersion(); got != "1.0.0" {
+		t.Errorf("versión instalada = %s, want 1.0.0", got)
+	}
+}
+
+func TestHarnessHighWaterMark(t *testing.T) {
+	highest := func(t *testing.T, h *harness) string {
+		t.Helper()
+		state, err := h.updater.loadState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return state.HighestVersion
+	}
+	update := func(t *testing.T, h *harness, want string) {
+		t.Helper()
+		_, version, err := h.updater.CheckForUpdate()
+		if err != nil || version != want {
+			t.Fatalf("CheckForUpdate = %q, %v, want %q", version, err, want)
+		}
+		if err := h.updater.DownloadUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.updater.ApplyUpdate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("release retirada antes de instalarse", func(t *testing.T) {
+		h := newHarness(t, "1.0.0")
+		h.publish("1.2.0", nil)
+		if _, version, err := h.updater.CheckForUpdate(); err != nil || version != "1.2.0" {
+			t.Fatalf("CheckForUpdate = %q, %v", version, err)
+		}
+		if got := highest(t, h); got != "" {
+			t.Fatalf("el high-water mark no debería moverse al verificar, es %q", got)
+		}
+
+		// 1.2.0 se retira y se vuelve a publicar 1.1.0
+		h.publish("1.1.0", nil)
+		update(t, h, "1.1.0")
+		if log, err := h.install(); err != nil {
+			t.Fatalf("instalación: %v\n%s", err, log)
+		}
+		if got := highest(t, h); got != "1.1.0" {
+			t.Errorf("high-water mark = %q, want 1.1.0", got)
+		}
+
+		// Un manifiesto anterior a lo instalado es un replay
+		h.publish("1.0.5", nil)
+		if _, _, err := h.updater.CheckForUpdate(); !errors.Is(err, ErrDowngradeRejected) {
+			t.Errorf("CheckForUpdate con 1.0.5 = %v, want ErrDowngradeRejected", err)
+		}
+	})
+
+	t.Run("instalación fallida", func(t *testing.T) {
+		h := newHarness(t, "1.0.0")
+		h.publish("1.1.0", nil)
+		h.sys.failRenameFrom = ".staging-"
+		update(t, h, "1.1.0")
+		if _, err := h.install(); err == nil {
+			t.Fatal("la instalación debería fallar")
+		}
+		if got := highest(t, h); got != "" {
+			t.Errorf("high-water mark = %q, want vacío", got)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		h := newHarness(t, "1.1.0")
+		h.updater.config.AllowDowngrade = true
+		if err := h.updater.saveState(&updaterState{HighestVersion: "1.1.0"}); err != nil {
+			t.Fatal(err)
+		}
+		h.publish("1.0.0", func(m *Manifest) { m.Rollback = true })
+		update(t, h, "1.0.0")
+		if log, err := h.install(); err != nil {
+			t.Fatalf("instalación: %v\n%s", err, log)
+		}
+		if got := highest(t, h); got != "1.0.0" {
+			t.Errorf("high-water mark = %q, want 1.0.0", got)
+		}
+	})
+}
+
+func TestHarnessApplyWithoutManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		highest string
+		wantErr error
+	}{
+		{"versión del bundle mayor", "1.0.0", "", nil},
+		{"versión del bundle igual a la actual", "1.1.0", "", ErrDowngradeRejected},
+		{"versión del bundle menor al high-water mark", "1.0.0", "1.2.0", ErrDowngradeRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, "1.0.0")
+			h.updater.config.ZipFileName = "MyApp.zip"
+			h.publish("1.1.0", nil)
+			if _, _, err := h.updater.CheckForUpdate(); err != nil {
+				t.Fatal(err)
+			}
+			if err := h.updater.DownloadUpdate(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Como después de reiniciar la app: la descarga está, el manifiesto no
+			h.updater.setManifest(nil)
+			h.updater.config.CurrentVersion = tt.current
+			if tt.highest != "" {
+				if err := h.updater.saveState(&updaterState{HighestVersion: tt.highest}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := h.updater.ApplyUpdate()
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ApplyUpdate: err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyUpdate: %v", err)
+			}
+			if log, err := h.install(); err != nil {
+				t.Fatalf("instalación: %v\n%s", err, log)
+			}
+			state, err := h.updater.loadState()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state.HighestVersion != "1.1.0" || state.LastInstall == nil || state.LastInstall.Version != "1.1.0" {
+				t.Errorf("estado = %+v, %+v; want versión 1.1.0", state.HighestVersion, state.LastInstall)
+			}
+		})
+	}
+}
+
+func TestHarnessFixedAppLocator(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	h.publish("1.1.0", nil)
+
+	// Actualizar una app auxiliar en otra ruta, no la del ejecutable actual
+	helperApp := writeFakeApp(t, filepath.Join(t.TempDir(), "Helpers"), "1.0.0")
+	h.updater.config.AppLocator = FixedAppLocator(helperApp)
+
+	if _, _, err := h.updater.CheckForUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updater.DownloadUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updater.ApplyUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if log, err := h.install(); err != nil {
+		t.Fatalf("instalación: %v\n%s", err, log)
+	}
+
+	info, err := bundle.ReadInfo(helperApp)
+	if err != nil || info.ShortVersion != "1.1.0" {
+		t.Errorf("app auxiliar = %+v, %v; want 1.1.0", info, err)
+	}
+	if got := h.installedVersion(); got != "1.0.0" {
+		t.Errorf("la app del ejecutable no debería cambiar, tiene %s", got)
+	}
+}
+
+func TestHarnessApplyUpdateToTarget(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	h.publish("1.1.0", nil)
+
+	helperApp := writeFakeApp(t, filepath.Join(t.TempDir(), "LoginItems"), "1.0.0")
+	h.sys.alive = map[int]int{501: 2, 502: 3}
+	h.sys.appRunning = 2
+
+	if _, _, err := h.updater.CheckForUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updater.DownloadUpdate(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := h.updater.ApplyUpdateTo(Target{AppPath: helperApp, BundleIdentifier: "com.joobpay.otra"})
+	if !errors.Is(err, ErrBundleMismatch) {
+		t.Fatalf("ApplyUpdateTo con identificador incorrecto = %v, want ErrBundleMismatch", err)
+	}
+
+	target := Target{AppPath: helperApp, PIDs: []int{501, 502}, BundleIdentifier: harnessIdentifier}
+	if err := h.updater.ApplyUpdateTo(target); err != nil {
+		t.Fatalf("ApplyUpdateTo: %v", err)
+	}
+
+	log, err := h.install()
+	if err != nil {
+		t.Fatalf("instalación: %v\n%s", err, log)
+	}
+	if h.sys.alive[501] != 0 || h.sys.alive[502] != 0 || h.sys.appRunning != 0 {
+		t.Errorf("el instalador no esperó a todos los procesos: alive=%v appRunning=%d", h.sys.alive, h.sys.appRunning)
+	}
+
+	info, err := bundle.ReadInfo(helperApp)
+	if err != nil || info.ShortVersion != "1.1.0" {
+		t.Errorf("app auxiliar = %+v, %v; want 1.1.0", info, err)
+	}
+	if got := h.installedVersion(); got != "1.0.0" {
+		t.Errorf("la app del ejecutable no debería cambiar, tiene %s", got)
+	}
+}
+
+// publishComponent genera el ZIP de un componente (el archivo o bundle en
+// sourcePath) y retorna su entrada de manifiesto
+func (h *harness) publishComponent(name, sourcePath, installPath, symlink string) Component {
+	h.t.Helper()
+
+	payloadPath := filepath.Join("releases", "components", name+".zip")
+	zipPath := filepath.Join(h.releases, payloadPath)
+	if err := os.MkdirAll(filepath.Dir(zipPath), 0755); err != nil {
+		h.t.Fatal(err)
+	}
+	if err := utils.ZipDirectory(sourcePath, zipPath); err != nil {
+		h.t.Fatal(err)
+	}
+	data, err := os.ReadFile(zipPath)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+
+	return Component{
+		Name:        name,
+		URL:         filepath.ToSlash(payloadPath),
+		InstallPath: installPath,
+		Symlink:     symlink,
+		Checksum:    hex.EncodeToString(sum[:]),
+		Size:        int64(len(data)),
+	}
+}
+
+// writeTool crea un ejecutable suelto con el contenido indicado
+func writeTool(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHarnessComponents(t *testing.T) {
+	root := t.TempDir()
+	helperInstall := filepath.Join(root, "PrivilegedHelperTools", "MyApp.app")
+	toolInstall := filepath.Join(root, "Application Support", "bin", "myapp")
+	toolLink := filepath.Join(root, "usr", "local", "bin", "myapp")
+
+	tests := []struct {
+		name        string
+		toolInstall string
+		wantErr     bool
+	}{
+		{"transacción completa", toolInstall, false},
+		// El padre de install_path es un archivo: falla el último componente
+		{"rollback de todos los componentes", filepath.Join(root, "blocker", "myapp"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFakeApp(t, filepath.Dir(helperInstall), "1.0.0")
+			writeTool(t, toolInstall, "tool 1.0.0")
+			writeTool(t, filepath.Join(root, "blocker"), "no soy un directorio")
+			os.Remove(toolLink)
+
+			h := newHarness(t, "1.0.0")
+			h.updater.config.ComponentPaths = []string{root}
+			helper := h.publishComponent("helper", writeFakeApp(t, t.TempDir(), "1.1.0"), helperInstall, "")
+			newTool := filepath.Join(t.TempDir(), "myapp")
+			writeTool(t, newTool, "tool 1.1.0")
+			tool := h.publishComponent("tool", newTool, tt.toolInstall, toolLink)
+			h.publish("1.1.0", func(m *Manifest) { m.Components = []Component{helper, tool} })
+
+			if _, _, err := h.updater.CheckForUpdate(); err != nil {
+				t.Fatal(err)
+			}
+			if err := h.updater.DownloadUpdate(); err != nil {
+				t.Fatalf("DownloadUpdate: %v", err)
+			}
+			if err := h.updater.ApplyUpdate(); err != nil {
+				t.Fatalf("ApplyUpdate: %v", err)
+			}
+
+			log, err := h.install()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("instalación: err = %v, wantErr %v\n%s", err, tt.wantErr, log)
+			}
+
+			wantVersion := "1.1.0"
+			if tt.wantErr {
+				wantVersion = "1.0.0"
+			}
+			if got := h.installedVersion(); got != wantVersion {
+				t.Errorf("app = %s, want %s", got, wantVersion)
+			}
+			if info, err := bundle.ReadInfo(helperInstall); err != nil || info.ShortVersion != wantVersion {
+				t.Errorf("helper = %+v, %v; want %s", info, err, wantVersion)
+			}
+
+			if tt.wantErr {
+				if _, err := os.Lstat(toolLink); !os.IsNotExist(err) {
+					t.Errorf("el symlink debería deshacerse: %v", err)
+				}
+				return
+			}
+
+			data, err := os.ReadFile(toolLink)
+			if err != nil || string(data) != "tool 1.1.0" {
+				t.Errorf("herramienta vía symlink = %q, %v", data, err)
+			}
+			if _, err := os.Stat(h.updater.componentsPath()); !os.IsNotExist(err) {
+				t.Errorf("los ZIP de componentes deberían eliminarse: %v", err)
+			}
+			if backups := h.leftovers(); len(backups) != 0 {
+				t.Errorf("quedaron backups: %v", backups)
+			}
+		})
+	}
+}
+
+func TestHarnessComponentRestrictions(t *testing.T) {
+	root := t.TempDir()
+	allowed := filepath.Join(root, "Application Support", "MyApp")
+
+	tests := []struct {
+		name        string
+		installPath string
+		symlink     string
+		wantErr     string
+	}{
+		{"fuera de ComponentPaths", filepath.Join(root, "LaunchDaemons", "com.evil.plist"), "", "no está en ComponentPaths"},
+		{"escape con ..", filepath.Join(allowed, "..", "..", "LaunchAgents", "x"), "", "no está en ComponentPaths"},
+		{"prefijo sin separador", allowed + "-evil", "", "no está en ComponentPaths"},
+		{"symlink fuera de ComponentPaths", filepath.Join(allowed, "bin", "myapp"), filepath.Join(root, "usr", "bin", "sudo"), "no está en ComponentPaths"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, "1.0.0")
+			h.updater.config.ComponentPaths = []string{allowed}
+			newTool := filepath.Join(t.TempDir(), "myapp")
+			writeTool(t, newTool, "tool 1.1.0")
+			tool := h.publishComponent("tool", newTool, tt.installPath, tt.symlink)
+			h.publish("1.1.0", func(m *Manifest) { m.Components = []Component{tool} })
+
+			if _, _, err := h.updater.CheckForUpdate(); err != nil {
+				t.Fatal(err)
+			}
+			err := h.updater.DownloadUpdate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("DownloadUpdate: err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("sin ComponentPaths", func(t *testing.T) {
+		h := newHarness(t, "1.0.0")
+		newTool := filepath.Join(t.TempDir(), "myapp")
+		writeTool(t, newTool, "tool 1.1.0")
+		tool := h.publishComponent("tool", newTool, filepath.Join(allowed, "myapp"), "")
+		h.publish("1.1.0", func(m *Manifest) { m.Components = []Component{tool} })
+
+		if _, _, err := h.updater.CheckForUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.updater.DownloadUpdate(); err == nil {
+			t.Fatal("DownloadUpdate debería rechazar componentes sin ComponentPaths")
+		}
+	})
+
+	t.Run("componentes descargados sin manifiesto", func(t *testing.T) {
+		h := newHarness(t, "1.0.0")
+		h.updater.config.ComponentPaths = []string{allowed}
+		h.updater.config.ZipFileName = "MyApp.zip"
+		newTool := filepath.Join(t.TempDir(), "myapp")
+		writeTool(t, newTool, "tool 1.1.0")
+		tool := h.publishComponent("tool", newTool, filepath.Join(allowed, "myapp"), "")
+		h.publish("1.1.0", func(m *Manifest) { m.Components = []Component{tool} })
+
+		if _, _, err := h.updater.CheckForUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.updater.DownloadUpdate(); err != nil {
+			t.Fatalf("DownloadUpdate: %v", err)
+		}
+
+		// Como después de reiniciar la app: la descarga está, el manifiesto no
+		h.updater.setManifest(nil)
+		err := h.updater.ApplyUpdate()
+		if err == nil || !strings.Contains(err.Error(), "no hay manifiesto") {
+			t.Fatalf("ApplyUpdate: err = %v, want error por falta de manifiesto", err)
+		}
+		if _, err := os.Stat(filepath.Join(allowed, "myapp")); !os.IsNotExist(err) {
+			t.Errorf("el componente no debería instalarse: %v", err)
+		}
+	})
+}
+
+func TestHarnessWaitTimeout(t *testing.T) {
+	// hung simula un proceso que no termina por sí solo
+	const hung = 1 << 30
+
+	tests := []struct {
+		name        string
+		action      WaitTimeoutAction
+		ignoreTerm  bool
+		wantOutcome WaitOutcome
+		wantSignals []string
+		wantVersion string
+	}{
+		{"cancelar", WaitTimeoutAbort, false, WaitTimedOut, nil, "1.0.0"},
+		{"SIGTERM", WaitTimeoutTerminate, false, WaitTerminated, []string{"501:SIGTERM", "777:SIGTERM"}, "1.1.0"},
+		{"SIGTERM ignorado y SIGKILL", WaitTimeoutTerminate, true, WaitKilled, []string{"501:SIGTERM", "777:SIGTERM", "501:SIGKILL", "777:SIGKILL"}, "1.1.0"},
+		{"SIGKILL", WaitTimeoutKill, false, WaitKilled, []string{"501:SIGKILL", "777:SIGKILL"}, "1.1.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, "1.0.0")
+			h.publish("1.1.0", nil)
+			h.updater.config.WaitTimeout = 20 * time.Millisecond
+			h.updater.config.WaitTimeoutAction = tt.action
+			h.updater.config.TerminateGracePeriod = 20 * time.Millisecond
+
+			// 777 es un helper lanzado por la app y 1234 el propio instalador
+			// (Getpid del fake), que no se espera ni recibe señales
+			h.sys.alive = map[int]int{501: hung, 777: hung, 1234: hung}
+			h.sys.children = map[int][]int{501: {777, 1234}}
+			if tt.ignoreTerm {
+				h.sys.ignoreTerm = map[int]bool{501: true, 777: true}
+			}
+
+			if _, _, err := h.updater.CheckForUpdate(); err != nil {
+				t.Fatal(err)
+			}
+			if err := h.updater.DownloadUpdate(); err != nil {
+				t.Fatal(err)
+			}
+			if err := h.updater.ApplyUpdateTo(Target{AppPath: h.installedApp, PIDs: []int{501}}); err != nil {
+				t.Fatalf("ApplyUpdateTo: %v", err)
+			}
+
+			log, err := h.install()
+			if wantErr := tt.wantOutcome == WaitTimedOut; (err != nil) != wantErr {
+				t.Fatalf("instalación: err = %v, wantErr %v\n%s", err, wantErr, log)
+			}
+			if !strings.Contains(log, "Resultado: ") {
+				t.Errorf("el log no registra el resultado:\n%s", log)
+			}
+
+			if fmt.Sprint(h.sys.signals) != fmt.Sprint(tt.wantSignals) {
+				t.Errorf("señales = %v, want %v", h.sys.signals, tt.wantSignals)
+			}
+			if got := h.installedVersion(); got != tt.wantVersion {
+				t.Errorf("versión instalada = %s, want %s", got, tt.wantVersion)
+			}
+
+			result, err := h.updater.LastInstall()
+			if err != nil || result == nil {
+				t.Fatalf("LastInstall = %v, %v", result, err)
+			}
+			if result.Wait != tt.wantOutcome || result.Version != "1.1.0" || result.Success != (tt.wantOutcome != WaitTimedOut) {
+				t.Errorf("LastInstall = %+v, want espera %s", result, tt.wantOutcome)
+			}
+
+			if tt.wantOutcome == WaitTimedOut {
+				if _, err := os.Stat(filepath.Join(h.updater.config.DownloadPath, "extracted")); !os.IsNotExist(err) {
+					t.Errorf("los archivos extraídos deberían eliminarse: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestHarnessCrossVolumeSwap(t *testing.T) {
+	tests := []struct {
+		name       string
+		noExchange bool
+		failSwap   bool
+	}{
+		{"intercambio atómico", false, false},
+		{"rename", true, false},
+		{"intercambio atómico con rollback", false, true},
+		{"rename con rollback", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, "1.0.0")
+			h.publish("1.1.0", nil)
+			h.sys.otherVolume = h.updater.config.DownloadPath
+			h.sys.noExchange = tt.noExchange
+			if tt.failSwap {
+				h.sys.failRenameFrom = ".staging-"
+			}
+
+			if _, _, err := h.updater.CheckForUpdate(); err != nil {
+				t.Fatal(err)
+			}
+			if err := h.updater.DownloadUpdate(); err != nil {
+				t.Fatal(err)
+			}
+			if err := h.updater.ApplyUpdate(); err != nil {
+				t.Fatal(err)
+			}
+
+			log, err := h.install()
+			if (err != nil) != tt.failSwap {
+				t.Fatalf("instalación: err = %v, wantErr %v\n%s", err, tt.failSwap, log)
+			}
+			if fallback := strings.Contains(log, "Intercambio atómico no disponible"); fallback != tt.noExchange {
+				t.Errorf("fallback a rename = %v, want %v\n%s", fallback, tt.noExchange, log)
+			}
+
+			wantVersion := "1.1.0"
+			if tt.failSwap {
+				wantVersion = "1.0.0"
+			}
+			if got := h.installedVersion(); got != wantVersion {
+				t.Errorf("versión instalada = %s, want %s", got, wantVersion)
+			}
+			if backups := h.leftovers(); len(backups) != 0 {
+				t.Errorf("quedaron backups o copias preparadas: %v", backups)
+			}
+		})
+	}
+}
+
+func TestCopyTreePreservesMetadata(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "MyApp.app")
+	framework := filepath.Join(src, "Contents", "Frameworks", "Lib.framework")
+	writeTool(t, filepath.Join(src, "Contents", "MacOS", "MyApp"), "#!/bin/sh\n")
+	if err := os.MkdirAll(filepath.Join(framework, "Versions", "A"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(framework, "Versions", "A", "Lib"), []byte("lib"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("A", filepath.Join(framework, "Versions", "Current")); err != nil {
+		t.Fatal(err)
+	}
+
+	// En macOS la copia también debe conservar los atributos extendidos
+	xattrFile := filepath.Join(src, "Contents", "MacOS", "MyApp")
+	if runtime.GOOS == "darwin" {
+		if out, err := exec.Command("xattr", "-w", "com.joobpay.test", "valor", xattrFile).CombinedOutput(); err != nil {
+			t.Fatalf("xattr -w: %v: %s", err, out)
+		}
+	}
+
+	dst := filepath.Join(t.TempDir(), "MyApp.app")
+	if err := copyTree(src, dst); err != nil {
+		t.Fatalf("copyTree: %v", err)
+	}
+
+	srcDigest, err := treeDigest(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstDigest, err := treeDigest(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srcDigest != dstDigest {
+		t.Error("la copia no conserva contenido, permisos o symlinks")
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "Contents", "Frameworks", "Lib.framework", "Versions", "Current")); err != nil || link != "A" {
+		t.Errorf("symlink = %q, %v, want A", link, err)
+	}
+
+	if runtime.GOOS == "darwin" {
+		out, err := exec.Command("xattr", "-p", "com.joobpay.test", filepath.Join(dst, "Contents", "MacOS", "MyApp")).Output()
+		if err != nil || strings.TrimSpace(string(out)) != "valor" {
+			t.Errorf("atributo extendido = %q, %v, want valor", out, err)
+		}
+	}
+}
+
+func TestHarnessNeedsPrivileges(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	h.publish("1.1.0", nil)
+	applications := filepath.Dir(h.installedApp)
+	h.sys.readOnly = map[string]bool{applications: true}
+
+	if _, _, err := h.updater.CheckForUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updater.DownloadUpdate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.updater.CheckWritePermissions(); !errors.Is(err, ErrNeedsPrivileges) {
+		t.Errorf("CheckWritePermissions = %v, want ErrNeedsPrivileges", err)
+	}
+	if err := h.updater.ApplyUpdate(); !errors.Is(err, ErrNeedsPrivileges) {
+		t.Fatalf("ApplyUpdate sin Elevator = %v, want ErrNeedsPrivileges", err)
+	}
+	if _, err := os.Stat(filepath.Join(h.updater.config.DownloadPath, "extracted")); !os.IsNotExist(err) {
+		t.Errorf("no debería descomprimir sin permisos: %v", err)
+	}
+
+	var requests []ElevationRequest
+	h.updater.config.Elevator = ElevatorFunc(func(request ElevationRequest) error {
+		requests = append(requests, request)
+		return nil
+	})
+	if err := h.updater.ApplyUpdate(); err != nil {
+		t.Fatalf("ApplyUpdate con Elevator: %v", err)
+	}
+
+	if len(h.sys.detached) != 0 {
+		t.Errorf("el instalador no debería iniciarse sin privilegios: %v", h.sys.detached)
+	}
+	if len(requests) != 1 || fmt.Sprint(requests[0].Paths) != fmt.Sprint([]string{applications}) {
+		t.Fatalf("solicitudes de elevación = %+v", requests)
+	}
+	if requests[0].Executable != h.sys.exe {
+		t.Errorf("Executable = %s, want %s", requests[0].Executable, h.sys.exe)
+	}
+
+	log, err := h.runPlan(requests[0].Env)
+	if err != nil {
+		t.Fatalf("instalación: %v\n%s", err, log)
+	}
+	if got := h.installedVersion(); got != "1.1.0" {
+		t.Errorf("versión instalada = %s, want 1.1.0", got)
+	}
+}
+
+// elevatedPlan simula que ApplyUpdate corrió como el usuario uid y pidió
+// privilegios: reescribe el plan con ese UserID y su nuevo digest
+func (h *harness) elevatedPlan(env []string, uid int) []string {
+	h.t.Helper()
+
+	planPath := envValue(env, installPlanEnv)
+	data, err := os.ReadFile(planPath)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	var plan installPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		h.t.Fatal(err)
+	}
+	plan.UserID = uid
+	if data, err = json.Marshal(plan); err != nil {
+		h.t.Fatal(err)
+	}
+	if err := os.WriteFile(planPath, data, 0600); err != nil {
+		h.t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	return []string{installPlanEnv + "=" + planPath, installPlanDigestEnv + "=" + hex.EncodeToString(sum[:])}
+}
+
+// nobodyUID retorna el uid de nobody para simular al usuario que pidió la
+// instalación. Omite el test si no corre como root
+func nobodyUID(t *testing.T) int {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("el instalador elevado requiere ejecutar los tests como root")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("no existe el usuario nobody")
+	}
+	uid, _ := strconv.Atoi(nobody.Uid)
+	return uid
+}
+
+// userDir crea un directorio que pertenece a uid, como DownloadPath
+func userDir(t *testing.T, uid int) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "joobpay-user-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(dir, uid, -1); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestElevatedInstallerUserPaths(t *testing.T) {
+	uid := nobodyUID(t)
+
+	// Un archivo de root fuera del alcance del usuario
+	protected := filepath.Join(t.TempDir(), "protected")
+	if err := os.WriteFile(protected, []byte("root"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("log", func(t *testing.T) {
+		dir := userDir(t, uid)
+		logPath := filepath.Join(dir, "update.log")
+		log, err := openLogAsUser(uid, logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintln(log, "Resultado: exitoso=true")
+		if err := log.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+
+		data, err := os.ReadFile(logPath)
+		if err != nil || string(data) != "Resultado: exitoso=true\n" {
+			t.Errorf("log = %q, %v", data, err)
+		}
+		if info, err := os.Lstat(logPath); err != nil || info.Sys().(*syscall.Stat_t).Uid != uint32(uid) {
+			t.Errorf("el log debería pertenecer al usuario: %v", err)
+		}
+	})
+
+	t.Run("log con symlink plantado", func(t *testing.T) {
+		dir := userDir(t, uid)
+		logPath := filepath.Join(dir, "update.log")
+		if err := os.Symlink(protected, logPath); err != nil {
+			t.Fatal(err)
+		}
+
+		if log, err := openLogAsUser(uid, logPath); err == nil {
+			fmt.Fprintln(log, "pwned")
+			log.Close()
+		}
+		if data, _ := os.ReadFile(protected); string(data) != "root" {
+			t.Errorf("el log sobrescribió un archivo de root: %q", data)
+		}
+	})
+
+	t.Run("limpieza con padre reemplazado por symlink", func(t *testing.T) {
+		dir := userDir(t, uid)
+		// extracted apunta al directorio del archivo protegido
+		if err := os.Symlink(filepath.Dir(protected), filepath.Join(dir, "extracted")); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := removeAsUser(uid, filepath.Join(dir, "extracted", "protected")); err == nil {
+			t.Error("removeAsUser debería fallar sin permisos sobre el destino")
+		}
+		if _, err := os.Stat(protected); err != nil {
+			t.Errorf("la limpieza eliminó un archivo de root: %v", err)
+		}
+
+		userFile := filepath.Join(dir, "update.zip")
+		if err := os.WriteFile(userFile, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := removeAsUser(uid, userFile); err != nil {
+			t.Errorf("removeAsUser: %v", err)
+		}
+	})
+}
+
+func TestHarnessElevatedInstallerTrust(t *testing.T) {
+	// apply prepara una instalación que requiere privilegios y retorna el
+	// entorno con el que se ejecutaría el instalador
+	apply := func(t *testing.T, h *harness) []string {
+		t.Helper()
+		h.publish("1.1.0", nil)
+		h.sys.readOnly = map[string]bool{filepath.Dir(h.installedApp): true}
+		var requests []ElevationRequest
+		h.updater.config.Elevator = ElevatorFunc(func(request ElevationRequest) error {
+			requests = append(requests, request)
+			return nil
+		})
+		if _, _, err := h.updater.CheckForUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.updater.DownloadUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.updater.ApplyUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		return requests[0].Env
+	}
+
+	t.Run("plan modificado", func(t *testing.T) {
+		h := newHarness(t, "1.0.0")
+		env := apply(t, h)
+
+		// Otro proceso del usuario reescribe el plan mientras se pide la contraseña
+		planPath := envValue(env, installPlanEnv)
+		data, err := os.ReadFile(planPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = bytes.Replace(data, []byte(`"relaunch"`), []byte(`"after_command": "touch /tmp/pwned", "relaunch"`), 1)
+		if err := os.WriteFile(planPath, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := h.runPlan(env); err == nil || !strings.Contains(err.Error(), "modificado") {
+			t.Fatalf("runPlan con plan modificado = %v", err)
+		}
+		if _, err := h.runPlan([]string{installPlanEnv + "=" + planPath}); err == nil {
+			t.Fatal("runPlan sin digest debería fallar")
+		}
+		if got := h.installedVersion(); got != "1.0.0" {
+			t.Errorf("versión instalada = %s, want 1.0.0", got)
+		}
+	})
+
+	uid := nobodyUID(t)
+
+	t.Run("contenido modificado", func(t *testing.T) {
+		h := newHarness(t, "1.0.0")
+		env := h.elevatedPlan(apply(t, h), uid)
+
+		// El bundle validado se reemplaza antes de que el instalador lo copie
+		newApp := filepath.Join(h.updater.config.DownloadPath, "extracted", "MyApp.app")
+		if err := os.WriteFile(filepath.Join(newApp, "Contents", "MacOS", "MyApp"), []byte("#!/bin/sh\nevil\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		log, err := h.runPlan(env)
+		if err == nil || !strings.Contains(err.Error(), "cambió después de validarlo") {
+			t.Fatalf("runPlan con contenido modificado = %v\n%s", err, log)
+		}
+		if got := h.installedVersion(); got != "1.0.0" {
+			t.Errorf("versión instalada = %s, want 1.0.0", got)
+		}
+		if leftovers := h.leftovers(); len(leftovers) != 0 {
+			t.Errorf("quedaron restos: %v", leftovers)
+		}
+	})
+
+	t.Run("setuid agregado", func(t *testing.T) {
+		h := newHarness(t, "1.0.0")
+		env := h.elevatedPlan(apply(t, h), uid)
+
+		// Mismo contenido, pero el ejecutable validado pasa a ser setuid
+		newApp := filepath.Join(h.updater.config.DownloadPath, "extracted", "MyApp.app")
+		if err := os.Chmod(filepath.Join(newApp, "Contents", "MacOS", "MyApp"), 0755|os.ModeSetuid); err != nil {
+			t.Fatal(err)
+		}
+
+		log, err := h.runPlan(env)
+		if err == nil || !strings.Contains(err.Error(), "cambió después de validarlo") {
+			t.Fatalf("runPlan con setuid agregado = %v\n%s", err, log)
+		}
+		if got := h.installedVersion(); got != "1.0.0" {
+			t.Errorf("versión instalada = %s, want 1.0.0", got)
+		}
+		if leftovers := h.leftovers(); len(leftovers) != 0 {
+			t.Errorf("quedaron restos: %v", leftovers)
+		}
+	})
+
+	t.Run("comandos como el usuario", func(t *testing.T) {
+		h := newHarness(t, "1.0.0")
+		t.Setenv(installPlanEnv, "/tmp/plan.json")
+		t.Setenv(installPlanDigestEnv, "abc")
+		check := fmt.Sprintf(`test "$(id -u)" = %d && %s`, uid, noInstallPlanEnv)
+		h.updater.config.BeforeUpdateCommand = check
+		h.updater.config.AfterUpdateCommand = check
+		env := h.elevatedPlan(apply(t, h), uid)
+
+		log, err := h.runPlan(env)
+		if err != nil {
+			t.Fatalf("instalación: %v\n%s", err, log)
+		}
+		if got := h.installedVersion(); got != "1.1.0" {
+			t.Errorf("versión instalada = %s, want 1.1.0", got)
+		}
+	})
+}
+
+// noInstallPlanEnv es un comando que falla si hereda las variables del plan
+const noInstallPlanEnv = `test -z "${JOOBPAY_UPDATER_INSTALL_PLAN+x}${JOOBPAY_UPDATER_INSTALL_PLAN_SHA256+x}"`
+
+func TestHarnessCommandsWithoutInstallPlanEnv(t *testing.T) {
+	// El instalador se ejecuta con el plan en su entorno
+	t.Setenv(installPlanEnv, "/tmp/plan.json")
+	t.Setenv(installPlanDigestEnv, "abc")
+
+	h := newHarness(t, "1.0.0")
+	h.publish("1.1.0", nil)
+	h.updater.config.BeforeUpdateCommand = noInstallPlanEnv
+	h.updater.config.AfterUpdateCommand = noInstallPlanEnv
+
+	if _, _, err := h.updater.CheckForUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updater.DownloadUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.updater.ApplyUpdate(); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := h.install()
+	if err != nil {
+		t.Fatalf("los comandos heredaron el plan de instalación: %v\n%s", err, log)
+	}
+	if got := h.installedVersion(); got != "1.1.0" {
+		t.Errorf("versión instalada = %s, want 1.1.0", got)
+	}
+}
+
+func TestHarnessDiskSpace(t *testing.T) {
+	t.Run("descarga", func(t *testing.T) {
+		h := newHarness(t, "1.0.0")
+		h.publish("1.1.0", func(m *Manifest) { m.InstalledSize = 4096 })
+		h.sys.volumes = map[string]VolumeInfo{h.updater.config.DownloadPath: {Device: 1, Available: 1024}}
+
+		if _, _, err := h.updater.CheckForUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		err := h.updater.DownloadUpdate()
+
+		var spaceErr *InsufficientSpaceError
+		if !errors.As(err, &spaceErr) || !errors.Is(err, ErrInsufficientSpace) {
+			t.Fatalf("DownloadUpdate = %v, want InsufficientSpaceError", err)
+		}
+		want := uint64(h.updater.manifest.Size + 4096)
+		if spaceErr.Required != want || spaceErr.Available != 1024 {
+			t.Errorf("requerido/disponible = %d/%d, want %d/1024", spaceErr.Required, spaceErr.Available, want)
+		}
+		if _, err := os.Stat(h.updater.GetZipPath()); !os.IsNotExist(err) {
+			t.Errorf("no debería descargarse el ZIP: %v", err)
+		}
+	})
+
+	tests := []struct {
+		name         string
+		targetDevice uint64
+		wantErr      bool
+	}{
+		// En el mismo volumen la copia es un rename: no requiere espacio
+		{"mismo volumen", 1, false},
+		{"otro volumen", 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run("instalación en "+tt.name, func(t *testing.T) {
+			h := newHarness(t, "1.0.0")
+			h.publish("1.1.0", nil)
+
+			if _, _, err := h.updater.CheckForUpdate(); err != nil {
+				t.Fatal(err)
+			}
+			if err := h.updater.DownloadUpdate(); err != nil {
+				t.Fatal(err)
+			}
+
+			applications := filepath.Dir(h.installedApp)
+			h.sys.volumes = map[string]VolumeInfo{
+				h.updater.config.DownloadPath: {Device: 1, Available: 1 << 30},
+				applications:                  {Device: tt.targetDevice, Available: 16},
+			}
+
+			err := h.updater.ApplyUpdate()
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("ApplyUpdate: %v", err)
+				}
+				return
+			}
+
+			var spaceErr *InsufficientSpaceError
+			if !errors.As(err, &spaceErr) {
+				t.Fatalf("ApplyUpdate = %v, want InsufficientSpaceError", err)
+			}
+			size, _ := utils.UncompressedSize(h.updater.GetZipPath())
+			if spaceErr.Path != applications || spaceErr.Required != size {
+				t.Errorf("error = %+v, want %s con %d bytes requeridos", spaceErr, applications, size)
+			}
+			if len(h.sys.detached) != 0 {
+				t.Errorf("no debería iniciarse el instalador")
+			}
+		})
+	}
+}
+
+func TestHarnessConcurrentUse(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	h.publish("1.1.0", nil)
+	h.updater.config.LockTimeout = 10 * time.Second
+
+	// Retener las consultas del manifiesto para que las verificaciones se
+	// superpongan
+	var manifestRequests int
+	var countMu sync.Mutex
+	requested := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := h.handler
+	h.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".json") {
+			countMu.Lock()
+			manifestRequests++
+			countMu.Unlock()
+			select {
+			case requested <- struct{}{}:
+			default:
+			}
+			<-release
+		}
+		server.ServeHTTP(w, r)
+	})
+
+	const callers = 8
+	var wg sync.WaitGroup
+	versions := make([]string, callers)
+	errs := make([]error, callers)
+	for n := 0; n < callers; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			_, versions[n], errs[n] = h.updater.CheckForUpdate()
+		}(n)
+	}
+
+	<-requested
+	time.Sleep(200 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for n := 0; n < callers; n++ {
+		if errs[n] != nil || versions[n] != "1.1.0" {
+			t.Fatalf("CheckForUpdate %d = %q, %v", n, versions[n], errs[n])
+		}
+	}
+	if manifestRequests != 1 {
+		t.Errorf("se descargó el manifiesto %d veces, want 1", manifestRequests)
+	}
+
+	// Verificaciones, descargas, consultas y preferencias a la vez
+	for n := 0; n < callers; n++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			if _, _, err := h.updater.CheckForUpdate(); err != nil {
+				t.Errorf("CheckForUpdate: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := h.updater.DownloadUpdate(); err != nil {
+				t.Errorf("DownloadUpdate: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			h.updater.GetManifest()
+			h.updater.IsDownloaded()
+		}()
+		go func(n int) {
+			defer wg.Done()
+			if err := h.updater.SkipVersion(fmt.Sprintf("0.9.%d", n)); err != nil {
+				t.Errorf("SkipVersion: %v", err)
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	if !h.updater.IsDownloaded() {
+		t.Error("la actualización debería estar descargada")
+	}
+	prefs, err := h.updater.GetPreferences()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prefs.SkippedVersions) != callers {
+		t.Errorf("SkippedVersions = %v, se perdieron escrituras concurrentes", prefs.SkippedVersions)
+	}
+}
+
+// panicComparator entra en panic en la primera comparación, después de que el
+// test lo libera
+type panicComparator struct {
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (c *panicComparator) Compare(a, b string) (int, error) {
+	c.once.Do(func() {
+		close(c.entered)
+		<-c.release
+		panic("comparador roto")
+	})
+	return SemverComparator.Compare(a, b)
+}
+
+func TestHarnessCheckPanic(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	h.publish("1.1.0", nil)
+	comparator := &panicComparator{entered: make(chan struct{}), release: make(chan struct{})}
+	h.updater.config.VersionComparator = comparator
+
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		h.updater.CheckForUpdate()
+	}()
+	<-comparator.entered
+
+	// Una segunda llamada espera la verificación en curso
+	waiter := make(chan error)
+	go func() {
+		_, _, err := h.updater.CheckForUpdate()
+		waiter <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(comparator.release)
+
+	if r := <-panicked; r == nil {
+		t.Fatal("CheckForUpdate debería propagar el panic")
+	}
+	select {
+	case err := <-waiter:
+		if !errors.Is(err, errCheckPanicked) {
+			t.Errorf("la llamada en espera retornó %v, want errCheckPanicked", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("la llamada en espera quedó bloqueada")
+	}
+
+	// La verificación siguiente no queda atada a la que falló
+	hasUpdate, version, err := h.updater.CheckForUpdate()
+	if err != nil || !hasUpdate || version != "1.1.0" {
+		t.Errorf("CheckForUpdate = %v, %q, %v", hasUpdate, version, err)
+	}
+}
+
+// statusRecorder registra el código de respuesta de cada petición al manifiesto
+type statusRecorder struct {
+	http.ResponseWriter
+	status *int
+}
+
+func (r statusRecorder) WriteHeader(status int) {
+	*r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func TestHarnessConditionalManifest(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	h.publish("1.1.0", nil)
+
+	var statuses []int
+	serve := func(cacheControl string) {
+		srv := releaseserver.New(h.releases, releaseserver.Faults{})
+		srv.CacheControl = cacheControl
+		h.handlerMu.Lock()
+		defer h.handlerMu.Unlock()
+		h.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasSuffix(r.URL.Path, ".json") {
+				srv.ServeHTTP(w, r)
+				return
+			}
+			status := http.StatusOK
+			srv.ServeHTTP(statusRecorder{w, &status}, r)
+			statuses = append(statuses, status)
+		})
+	}
+	check := func(want string) {
+		t.Helper()
+		_, version, err := h.updater.CheckForUpdate()
+		if err != nil || version != want {
+			t.Fatalf("CheckForUpdate = %q, %v, want %q", version, err, want)
+		}
+	}
+
+	// no-cache: siempre se revalida, y sin cambios el servidor responde 304
+	serve("no-cache")
+	check("1.1.0")
+	check("1.1.0")
+	if fmt.Sprint(statuses) != "[200 304]" {
+		t.Fatalf("respuestas = %v, want [200 304]", statuses)
+	}
+
+	// Un manifiesto nuevo invalida el ETag
+	h.publish("1.2.0", nil)
+	check("1.2.0")
+
+	// Con max-age se reutiliza sin consultar al servidor, incluso sin conexión
+	serve("max-age=60")
+	statuses = nil
+	check("1.2.0")
+	h.server.Close()
+	check("1.2.0")
+	if fmt.Sprint(statuses) != "[304]" {
+		t.Errorf("respuestas = %v, want [304]", statuses)
+	}
+
+	state, err := h.updater.loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Feed == nil || state.Feed.ETag == "" || !state.Feed.Expires.After(time.Now()) {
+		t.Errorf("caché del manifiesto = %+v", state.Feed)
+	}
+}
+
+func TestHarnessManifestSignature(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestPath := func(h *harness) string {
+		return filepath.Join(h.releases, fmt.Sprintf("darwin-%s.json", runtime.GOARCH))
+	}
+	sign := func(data []byte) []byte {
+		return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(private, data)) + "\n")
+	}
+	writeSignature := func(t *testing.T, path string, signature []byte) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, signature, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		sign    func(t *testing.T, h *harness, data []byte)
+		wantErr bool
+	}{
+		{"firma direccionada por contenido", func(t *testing.T, h *harness, data []byte) {
+			writeSignature(t, filepath.Join(h.releases, ManifestSignaturePath(data)), sign(data))
+		}, false},
+		// El .sig legacy aún es del manifiesto anterior (publicación en curso)
+		{"firma legacy desactualizada", func(t *testing.T, h *harness, data []byte) {
+			writeSignature(t, filepath.Join(h.releases, ManifestSignaturePath(data)), sign(data))
+			writeSignature(t, manifestPath(h)+".sig", sign([]byte("manifiesto anterior")))
+		}, false},
+		{"solo firma legacy", func(t *testing.T, h *harness, data []byte) {
+			writeSignature(t, manifestPath(h)+".sig", sign(data))
+		}, false},
+		{"sin firma", func(t *testing.T, h *harness, data []byte) {}, true},
+		{"firma de otro manifiesto", func(t *testing.T, h *harness, data []byte) {
+			writeSignature(t, filepath.Join(h.releases, ManifestSignaturePath(data)), sign([]byte("otro")))
+			writeSignature(t, manifestPath(h)+".sig", sign([]byte("otro")))
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t, "1.0.0")
+			h.updater.config.ManifestPublicKey = base64.StdEncoding.EncodeToString(public)
+			h.publish("1.1.0", nil)
+
+			data, err := os.ReadFile(manifestPath(h))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.sign(t, h, data)
+
+			_, version, err := h.updater.CheckForUpdate()
+			if tt.wantErr {
+				if !errors.Is(err, ErrManifestSignature) {
+					t.Fatalf("CheckForUpdate: err = %v, want ErrManifestSignature", err)
+				}
+				return
+			}
+			if err != nil || version != "1.1.0" {
+				t.Fatalf("CheckForUpdate = %q, %v, want 1.1.0", version, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,786 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// installPlanEnv es la variable de entorno con la que ApplyUpdate relanza el
+// ejecutable actual en modo instalador. El proceso detached ejecuta el plan
+// desde init() y termina sin llegar al main de la aplicación
+const installPlanEnv = "JOOBPAY_UPDATER_INSTALL_PLAN"
+
+// installPlanDigestEnv lleva el SHA-256 del plan. El plan vive en
+// DownloadPath, donde cualquier proceso del usuario puede escribir, y el
+// instalador puede correr como root: un plan que no coincide se rechaza
+const installPlanDigestEnv = "JOOBPAY_UPDATER_INSTALL_PLAN_SHA256"
+
+// installPlanFileName es el nombre del plan de instalación en DownloadPath
+const installPlanFileName = "install-plan.json"
+
+// installPlan contiene todo lo que necesita el proceso instalador, que corre
+// fuera de la aplicación y no tiene acceso a su Config
+type installPlan struct {
+	// PIDs son los procesos que deben terminar antes del swap
+	PIDs []int `json:"pids,omitempty"`
+
+	// WaitForApp indica que también se espera a todos los procesos que se
+	// ejecutan desde CurrentAppPath
+	WaitForApp bool `json:"wait_for_app,omitempty"`
+
+	// WaitTimeout, WaitTimeoutAction y TerminateGracePeriod definen cuánto se
+	// espera a los procesos y qué se hace si no terminan
+	WaitTimeout          time.Duration     `json:"wait_timeout,omitempty"`
+	WaitTimeoutAction    WaitTimeoutAction `json:"wait_timeout_action,omitempty"`
+	TerminateGracePeriod time.Duration     `json:"terminate_grace_period,omitempty"`
+
+	// Version es la versión que se instala
+	Version string `json:"version,omitempty"`
+
+	// StatePath es el archivo de estado donde se registra el resultado
+	StatePath string `json:"state_path,omitempty"`
+
+	// LockPath es el lock de DownloadPath, que el instalador toma durante
+	// la instalación
+	LockPath string `json:"lock_path,omitempty"`
+
+	// ExtractPath es el directorio de extracción, que se elimina si se
+	// cancela la actualización
+	ExtractPath string `json:"extract_path,omitempty"`
+
+	// NewAppPath es el bundle descomprimido y validado
+	NewAppPath string `json:"new_app_path"`
+
+	// NewAppDigest es el treeDigest de NewAppPath al validarlo. Solo se
+	// calcula cuando el instalador corre con privilegios
+	NewAppDigest string `json:"new_app_digest,omitempty"`
+
+	// CurrentAppPath es el bundle instalado a reemplazar
+	CurrentAppPath string `json:"current_app_path"`
+
+	// StagingPath es una ruta oculta junto a CurrentAppPath (mismo volumen)
+	// donde se copia el nuevo bundle antes del swap
+	StagingPath string `json:"staging_path"`
+
+	// BackupPath es donde se mueve el bundle instalado durante el swap, también
+	// junto a CurrentAppPath
+	BackupPath string `json:"backup_path"`
+
+	// ZipPath es el ZIP descargado, que se elimina al terminar
+	ZipPath string `json:"zip_path"`
+
+	// BeforeCommand y AfterCommand son comandos de shell opcionales
+	BeforeCommand string `json:"before_command,omitempty"`
+	AfterCommand  string `json:"after_command,omitempty"`
+
+	// Relaunch indica si se debe abrir la aplicación al terminar
+	Relaunch bool `json:"relaunch"`
+
+	// UserID es el usuario que pidió la actualización cuando el instalador
+	// corre con privilegios: la app se relanza como ese usuario y no como
+	// root, y el estado y el log le siguen perteneciendo
+	UserID int `json:"user_id,omitempty"`
+
+	// LogPath es el log de actualización
+	LogPath string `json:"log_path,omitempty"`
+
+	// Components son los componentes adicionales que se instalan en la
+	// misma transacción que la app
+	Components []installItem `json:"components,omitempty"`
+
+	// ComponentsPath es el directorio de ZIP de componentes, que se elimina al terminar
+	ComponentsPath string `json:"components_path,omitempty"`
+}
+
+// installItem es un elemento de la transacción de instalación. StagingPath y
+// BackupPath están en el mismo directorio que InstallPath, de modo que el
+// swap es un rename dentro del mismo volumen
+type installItem struct {
+	Name        string `json:"name"`
+	NewPath     string `json:"new_path"`
+	InstallPath string `json:"install_path"`
+	StagingPath string `json:"staging_path"`
+	BackupPath  string `json:"backup_path"`
+
+	// Symlink es un symlink opcional a InstallPath; el existente se guarda
+	// en SymlinkBackup
+	Symlink       string `json:"symlink,omitempty"`
+	SymlinkBackup string `json:"symlink_backup,omitempty"`
+
+	// Digest es el treeDigest de NewPath al validarlo (solo con privilegios)
+	Digest string `json:"digest,omitempty"`
+}
+
+// items retorna la app y los componentes en el orden en que se instalan
+func (p installPlan) items() []installItem {
+	app := installItem{
+		Name:        "app",
+		NewPath:     p.NewAppPath,
+		InstallPath: p.CurrentAppPath,
+		StagingPath: p.StagingPath,
+		BackupPath:  p.BackupPath,
+		Digest:      p.NewAppDigest,
+	}
+	return append([]installItem{app}, p.Components...)
+}
+
+// siblingPath retorna una ruta oculta en el mismo directorio que path (y por
+// lo tanto en el mismo volumen), por ejemplo /Applications/.MyApp.app.backup-123
+func siblingPath(path, suffix string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+"."+suffix)
+}
+
+// itemProgress registra qué pasos de un item se completaron, para deshacerlos
+type itemProgress struct {
+	item   installItem
+	staged bool
+
+	// backupAt es dónde quedó la versión anterior ("" si no había o si
+	// todavía no se movió)
+	backupAt string
+
+	installed       bool
+	symlinkBackedUp bool
+	symlinked       bool
+}
+
+func init() {
+	if planPath := os.Getenv(installPlanEnv); planPath != "" {
+		// Los comandos y la app que lance el instalador no deben heredar el plan
+		digest := os.Getenv(installPlanDigestEnv)
+		os.Unsetenv(installPlanEnv)
+		os.Unsetenv(installPlanDigestEnv)
+		os.Exit(runInstallPlan(planPath, digest))
+	}
+}
+
+// runInstallPlan ejecuta el plan guardado en planPath y retorna el exit code.
+// El instalador es otro proceso y no recibe la Config de la app, por lo que
+// usa siempre las implementaciones reales de FileSystem y ProcessLauncher
+func runInstallPlan(planPath, digest string) int {
+	plan, err := loadInstallPlan(planPath, digest)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	// El instalador elevado no recibe la salida redirigida al log: lo abre un
+	// proceso del usuario, porque como root seguiría un symlink plantado en
+	// DownloadPath
+	var out io.Writer = os.Stdout
+	if plan.UserID > 0 && os.Geteuid() == 0 && plan.LogPath != "" {
+		log, err := openLogAsUser(plan.UserID, plan.LogPath)
+		if err != nil {
+			fmt.Printf("No se pudo abrir el log de actualización: %v\n", err)
+		} else {
+			defer log.Close()
+			out = log
+		}
+	}
+
+	inst := newInstaller(plan, OSFileSystem{}, OSProcessLauncher{}, out)
+	if err := inst.run(); err != nil {
+		inst.logf("ERROR: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// loadInstallPlan lee el plan guardado en planPath y verifica que su SHA-256
+// sea digest, el que calculó ApplyUpdate al escribirlo
+func loadInstallPlan(planPath, digest string) (installPlan, error) {
+	data, err := os.ReadFile(planPath)
+	if err != nil {
+		return installPlan{}, fmt.Errorf("error leyendo plan de instalación: %w", err)
+	}
+	os.Remove(planPath)
+
+	sum := sha256.Sum256(data)
+	if digest == "" || !strings.EqualFold(hex.EncodeToString(sum[:]), digest) {
+		return installPlan{}, fmt.Errorf("el plan de instalación fue modificado después de crearlo")
+	}
+
+	var plan installPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return installPlan{}, fmt.Errorf("error parseando plan de instalación: %w", err)
+	}
+	return plan, nil
+}
+
+// installer realiza el swap del bundle una vez que la aplicación terminó
+type installer struct {
+	plan     installPlan
+	fs       FileSystem
+	launcher ProcessLauncher
+	out      io.Writer
+
+	// pollInterval es cada cuánto se verifica si el proceso terminó
+	pollInterval time.Duration
+
+	// settleDelay es una pausa adicional para que se liberen los recursos
+	settleDelay time.Duration
+
+	// children son los procesos hijos de la app, registrados al iniciar
+	children []int
+
+	// waitOutcome es el resultado de la espera a los procesos
+	waitOutcome WaitOutcome
+
+	// elevated indica que el instalador corre como root en nombre de
+	// plan.UserID
+	elevated bool
+}
+
+// newInstaller crea un instalador con las demoras por defecto
+func newInstaller(plan installPlan, fs FileSystem, launcher ProcessLauncher, out io.Writer) *installer {
+	return &installer{
+		plan:         plan,
+		fs:           fs,
+		launcher:     launcher,
+		out:          out,
+		pollInterval: 500 * time.Millisecond,
+		settleDelay:  time.Second,
+		elevated:     plan.UserID > 0 && os.Geteuid() == 0,
+	}
+}
+
+// logf escribe una línea con timestamp en el log de actualización
+func (i *installer) logf(format string, args ...any) {
+	fmt.Fprintf(i.out, "[%s] %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+}
+
+// run ejecuta la instalación y registra el resultado en el log y en el
+// estado persistido
+func (i *installer) run() error {
+	err := i.install()
+	i.recordResult(err)
+	return err
+}
+
+// install realiza la instalación. Si falla después de mover el bundle
+// instalado, lo restaura desde el backup
+func (i *installer) install() error {
+	plan := i.plan
+
+	i.logf("=== Iniciando actualización ===")
+	i.logf("PIDs a esperar: %v", plan.PIDs)
+	i.logf("Nueva app: %s", plan.NewAppPath)
+	i.logf("App actual: %s", plan.CurrentAppPath)
+
+	// Los hijos se registran antes de que la app termine
+	i.collectChildren()
+
+	// La app libera el lock al retornar de ApplyUpdate
+	if plan.LockPath != "" {
+		lock, err := acquireLock(plan.LockPath, plan.WaitTimeout)
+		if err != nil {
+			return err
+		}
+		defer lock.release()
+	}
+
+	if plan.BeforeCommand != "" {
+		if err := i.runCommand(plan.BeforeCommand); err != nil {
+			return fmt.Errorf("error en comando previo a la actualización: %w", err)
+		}
+	}
+
+	// 1. Esperar a que los procesos de la app terminen
+	i.logf("Esperando a que los procesos de la app terminen...")
+	outcome, err := i.waitForProcesses()
+	i.waitOutcome = outcome
+	if err != nil {
+		i.logf("Actualización cancelada: %v", err)
+		if plan.ExtractPath != "" {
+			i.removeUserPath(plan.ExtractPath)
+		}
+		return err
+	}
+	i.logf("Procesos terminados (%s)", outcome)
+
+	// Pequeña pausa adicional para asegurar que los recursos se liberaron
+	time.Sleep(i.settleDelay)
+
+	// 2. Sanitización - Limpiar atributos de cuarentena (Gatekeeper)
+	i.logf("Limpiando atributos de cuarentena...")
+	if err := i.fs.ClearQuarantine(plan.NewAppPath); err != nil {
+		i.logf("No se pudieron limpiar los atributos de cuarentena: %v", err)
+	}
+
+	// 3. Verificar que todos los elementos nuevos existen antes de tocar nada
+	items := plan.items()
+	for _, item := range items {
+		if _, err := i.fs.Lstat(item.NewPath); err != nil {
+			return fmt.Errorf("el nuevo elemento %s no existe en %s", item.Name, item.NewPath)
+		}
+	}
+
+	// 4. Copiar todos los elementos junto a su destino. Si falla (por
+	// ejemplo, disco lleno en el volumen de destino) no se tocó nada instalado
+	var progress []*itemProgress
+	for _, item := range items {
+		step := &itemProgress{item: item}
+		progress = append(progress, step)
+		if err := i.stageItem(step); err != nil {
+			return i.rollback(progress, fmt.Errorf("error preparando %s: %w", item.Name, err))
+		}
+	}
+
+	// 5. Swap transaccional: si falla cualquier elemento se deshacen todos
+	i.logf("Realizando swap...")
+	for _, step := range progress {
+		if err := i.installItem(step); err != nil {
+			return i.rollback(progress, fmt.Errorf("error instalando %s: %w", step.item.Name, err))
+		}
+	}
+
+	i.logf("Swap completado exitosamente")
+
+	// 6. Limpieza
+	i.logf("Limpiando archivos temporales...")
+	for _, step := range progress {
+		if step.backupAt != "" {
+			if err := i.fs.RemoveAll(step.backupAt); err != nil {
+				i.logf("No se pudo eliminar el backup de %s: %v", step.item.Name, err)
+			}
+		}
+		if step.symlinkBackedUp {
+			i.fs.Remove(step.item.SymlinkBackup)
+		}
+	}
+	i.logf("Backups eliminados")
+	if plan.ZipPath != "" {
+		if err := i.removeUserPath(plan.ZipPath); err == nil {
+			i.logf("ZIP eliminado")
+		}
+	}
+	if plan.ComponentsPath != "" {
+		i.removeUserPath(plan.ComponentsPath)
+	}
+
+	i.logf("=== Actualización completada exitosamente ===")
+
+	// El comando posterior corre con la actualización ya instalada y sin
+	// backup: si falla se registra, pero no se revierte la actualización
+	if plan.AfterCommand != "" {
+		if err := i.runCommand(plan.AfterCommand); err != nil {
+			i.logf("ERROR en comando posterior a la actualización: %v", err)
+		}
+	}
+
+	// 7. Reiniciar la aplicación
+	if plan.Relaunch {
+		i.logf("Iniciando nueva versión de la aplicación...")
+		open := i.launcher.Open
+		if i.elevated {
+			open = func(appPath string) error { return openAsUser(plan.UserID, appPath) }
+		}
+		if err := open(plan.CurrentAppPath); err != nil {
+			i.logf("No se pudo iniciar la aplicación: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// stageItem mueve el nuevo item a StagingPath, en el mismo volumen que
+// InstallPath. Si DownloadPath está en otro volumen se copia: una copia
+// interrumpida queda en la ruta oculta y nunca en InstallPath
+func (i *installer) stageItem(step *itemProgress) error {
+	item := step.item
+
+	i.logf("Preparando %s en %s...", item.Name, item.StagingPath)
+	if err := i.fs.MkdirAll(filepath.Dir(item.InstallPath), 0755); err != nil {
+		return err
+	}
+	// Restos de una ejecución anterior interrumpida
+	i.fs.RemoveAll(item.StagingPath)
+
+	if i.elevated {
+		return i.stageElevated(step)
+	}
+
+	if err := moveTree(i.fs, item.NewPath, item.StagingPath); err != nil {
+		return err
+	}
+	step.staged = true
+
+	// Como root, la nueva versión conserva el dueño de la instalada para que
+	// la próxima actualización no vuelva a requerir privilegios
+	if os.Geteuid() == 0 {
+		if err := matchOwner(item.StagingPath, item.InstallPath); err != nil {
+			return fmt.Errorf("error asignando dueño: %w", err)
+		}
+	}
+	return nil
+}
+
+// stageElevated prepara el item cuando el instalador corre como root. El
+// nuevo item está en DownloadPath, donde escribe el usuario: se copia (la
+// copia es de root y el usuario ya no la puede modificar) y se verifica que
+// sea lo mismo que validó ApplyUpdate
+func (i *installer) stageElevated(step *itemProgress) error {
+	item := step.item
+
+	if item.Digest == "" {
+		return fmt.Errorf("el plan no incluye el digest de %s", item.Name)
+	}
+	if err := copyTree(item.NewPath, item.StagingPath); err != nil {
+		i.fs.RemoveAll(item.StagingPath)
+		return err
+	}
+	step.staged = true
+
+	digest, err := treeDigest(item.StagingPath)
+	if err != nil {
+		return err
+	}
+	if digest != item.Digest {
+		return fmt.Errorf("el contenido de %s cambió después de validarlo", item.Name)
+	}
+	if err := i.removeUserPath(item.NewPath); err != nil {
+		i.logf("No se pudo eliminar %s: %v", item.NewPath, err)
+	}
+
+	if err := matchOwner(item.StagingPath, item.InstallPath); err != nil {
+		return fmt.Errorf("error asignando dueño: %w", err)
+	}
+	return nil
+}
+
+// removeUserPath elimina una ruta de DownloadPath. El instalador elevado lo
+// hace como el usuario: como root, reemplazar un directorio padre por un
+// symlink permitiría borrar cualquier archivo del sistema
+func (i *installer) removeUserPath(path string) error {
+	if i.elevated {
+		return removeAsUser(i.plan.UserID, path)
+	}
+	return i.fs.RemoveAll(path)
+}
+
+// treeDigest calcula el SHA-256 de un árbol: rutas relativas, tipos,
+// permisos (incluidos setuid, setgid y sticky), destinos de los symlinks y
+// contenido de los archivos
+func treeDigest(root string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%q %s\n", rel, info.Mode())
+
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "-> %q\n", link)
+		case info.Mode().IsRegular():
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			fmt.Fprintf(h, "%d\n", info.Size())
+			if _, err := io.Copy(h, file); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error calculando digest de %s: %w", root, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// matchOwner asigna a todo el árbol de path el dueño y grupo de reference,
+// si existe
+func matchOwner(path, reference string) error {
+	info, err := os.Lstat(reference)
+	if err != nil {
+		return nil
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	return filepath.WalkDir(path, func(p string, _ fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(p, int(stat.Uid), int(stat.Gid))
+	})
+}
+
+// installItem reemplaza la versión instalada de un item por la preparada y
+// crea el symlink si corresponde
+func (i *installer) installItem(step *itemProgress) error {
+	item := step.item
+
+	i.logf("Instalando nuevo %s en %s...", item.Name, item.InstallPath)
+	if err := i.swapItem(step); err != nil {
+		return err
+	}
+
+	if item.Symlink == "" {
+		return nil
+	}
+
+	if _, err := i.fs.Lstat(item.Symlink); err == nil {
+		if err := i.fs.Rename(item.Symlink, item.SymlinkBackup); err != nil {
+			return fmt.Errorf("error moviendo symlink existente: %w", err)
+		}
+		step.symlinkBackedUp = true
+	}
+	if err := i.fs.MkdirAll(filepath.Dir(item.Symlink), 0755); err != nil {
+		return err
+	}
+	if err := i.fs.Symlink(item.InstallPath, item.Symlink); err != nil {
+		return fmt.Errorf("error creando symlink %s: %w", item.Symlink, err)
+	}
+	step.symlinked = true
+
+	return nil
+}
+
+// swapItem reemplaza InstallPath por StagingPath. Si el sistema lo soporta
+// intercambia ambas rutas en una sola operación atómica (renamex_np o
+// renameat2); si no, mueve la versión instalada al backup y la nueva a su
+// lugar con dos rename en el mismo volumen
+func (i *installer) swapItem(step *itemProgress) error {
+	item := step.item
+
+	if _, err := i.fs.Lstat(item.InstallPath); err != nil {
+		// Instalación nueva: no hay nada que reemplazar
+		if err := i.fs.Rename(item.StagingPath, item.InstallPath); err != nil {
+			return err
+		}
+		step.installed = true
+		return nil
+	}
+
+	err := i.fs.Exchange(item.StagingPath, item.InstallPath)
+	if err == nil {
+		// La versión anterior quedó en StagingPath
+		step.installed = true
+		step.backupAt = item.StagingPath
+		if err := i.fs.Rename(item.StagingPath, item.BackupPath); err != nil {
+			return fmt.Errorf("error moviendo a backup: %w", err)
+		}
+		step.backupAt = item.BackupPath
+		return nil
+	}
+	i.logf("Intercambio atómico no disponible (%v), usando rename", err)
+
+	if err := i.fs.Rename(item.InstallPath, item.BackupPath); err != nil {
+		return fmt.Errorf("error moviendo a backup: %w", err)
+	}
+	step.backupAt = item.BackupPath
+	if err := i.fs.Rename(item.StagingPath, item.InstallPath); err != nil {
+		return err
+	}
+	step.installed = true
+	return nil
+}
+
+// rollback deshace, en orden inverso, los items instalados y restaura sus backups
+func (i *installer) rollback(progress []*itemProgress, cause error) error {
+	i.logf("ERROR: %v", cause)
+	i.logf("Realizando rollback...")
+
+	var failures []error
+	for j := len(progress) - 1; j >= 0; j-- {
+		step := progress[j]
+		item := step.item
+
+		if step.symlinked {
+			if err := i.fs.Remove(item.Symlink); err != nil {
+				failures = append(failures, err)
+			}
+		}
+		if step.symlinkBackedUp {
+			if err := i.fs.Rename(item.SymlinkBackup, item.Symlink); err != nil {
+				failures = append(failures, err)
+			}
+		}
+
+		switch {
+		case step.installed && step.backupAt != "":
+			// El intercambio deja la versión nueva en backupAt
+			if err := i.fs.Exchange(step.backupAt, item.InstallPath); err == nil {
+				i.fs.RemoveAll(step.backupAt)
+				break
+			}
+			if err := i.fs.RemoveAll(item.InstallPath); err != nil {
+				failures = append(failures, err)
+				continue
+			}
+			if err := i.fs.Rename(step.backupAt, item.InstallPath); err != nil {
+				failures = append(failures, err)
+			}
+		case step.installed:
+			if err := i.fs.RemoveAll(item.InstallPath); err != nil {
+				failures = append(failures, err)
+			}
+		case step.backupAt != "":
+			if err := i.fs.Rename(step.backupAt, item.InstallPath); err != nil {
+				failures = append(failures, err)
+			}
+		}
+
+		if step.staged && !step.installed {
+			i.fs.RemoveAll(item.StagingPath)
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%w (rollback fallido: %v)", cause, errors.Join(failures...))
+	}
+
+	i.logf("Rollback completado")
+	return cause
+}
+
+// recordResult escribe el resultado de la instalación en el log y en el estado
+func (i *installer) recordResult(err error) {
+	result := &InstallResult{
+		Version:    i.plan.Version,
+		FinishedAt: time.Now(),
+		Success:    err == nil,
+		Wait:       i.waitOutcome,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	i.logf("Resultado: exitoso=%t, espera=%s", result.Success, result.Wait)
+
+	if i.plan.StatePath == "" {
+		return
+	}
+	if err := updateStateFile(i.plan.StatePath, func(state *updaterState) {
+		state.LastInstall = result
+		// El high-water mark solo avanza con una versión efectivamente
+		// instalada. ApplyUpdate ya validó que no es menor (o que es un rollback)
+		if result.Success && i.plan.Version != "" {
+			state.HighestVersion = i.plan.Version
+		}
+	}); err != nil {
+		i.logf("No se pudo registrar el resultado en el estado: %v", err)
+	}
+
+	// El instalador elevado crea el estado como root
+	if i.elevated {
+		os.Lchown(i.plan.StatePath, i.plan.UserID, -1)
+	}
+}
+
+// runCommand ejecuta un comando de shell con la salida en el log. El comando
+// recibe las rutas del plan en las mismas variables que exponía el script de
+// actualización (PID, NEW_APP_PATH, CURRENT_APP_PATH, OLD_APP_BACKUP, ZIP_PATH).
+// El instalador elevado lo ejecuta como el usuario que pidió la actualización,
+// nunca como root
+func (i *installer) runCommand(command string) error {
+	env := []string{
+		"PID=" + strings.Trim(fmt.Sprint(i.plan.PIDs), "[]"),
+		"NEW_APP_PATH=" + i.plan.NewAppPath,
+		"CURRENT_APP_PATH=" + i.plan.CurrentAppPath,
+		"OLD_APP_BACKUP=" + i.plan.BackupPath,
+		"ZIP_PATH=" + i.plan.ZipPath,
+	}
+	if i.elevated {
+		return runCommandAsUser(i.plan.UserID, command, env, i.out)
+	}
+	return i.launcher.RunCommand(command, env, i.out)
+}
+
+// moveTree mueve un directorio con rename y, si origen y destino están en
+// volúmenes distintos, lo copia y elimina el original (como mv). Si la copia
+// falla elimina lo copiado
+func moveTree(fsys FileSystem, src, dst string) error {
+	err := fsys.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	if err := copyTree(src, dst); err != nil {
+		fsys.RemoveAll(dst)
+		return err
+	}
+	return fsys.RemoveAll(src)
+}
+
+// walkCopyTree copia un árbol preservando permisos (incluidos setuid, setgid y
+// sticky, como ditto) y symlinks (los frameworks de un bundle usan symlinks
+// relativos). No copia atributos extendidos: en macOS copyTree usa ditto
+func walkCopyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		mode := info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+		switch {
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case entry.IsDir():
+			return os.MkdirAll(target, mode)
+		default:
+			return copyFile(path, target, mode)
+		}
+	})
+}
+
+// copyFile copia un archivo regular con los permisos indicados
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
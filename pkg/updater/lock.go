@@ -0,0 +1,94 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrUpdateInProgress indica que otra instancia (u otra goroutine) está
+// descargando o aplicando una actualización en el mismo DownloadPath
+var ErrUpdateInProgress = errors.New("hay otra actualización en curso")
+
+// lockFileName es el archivo de lock en DownloadPath
+const lockFileName = "updater.lock"
+
+// lockPollInterval es cada cuánto se reintenta tomar un lock ocupado
+const lockPollInterval = 50 * time.Millisecond
+
+// updateLock es un lock advisory (flock) sobre un archivo. El sistema lo
+// libera al cerrar el descriptor, también si el proceso muere
+type updateLock struct {
+	file *os.File
+}
+
+// acquireLock toma el lock de path, esperando hasta timeout si está ocupado
+// (0 no espera, negativo espera sin límite). Retorna ErrUpdateInProgress si
+// no lo consigue
+func acquireLock(path string, timeout time.Duration) (*updateLock, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo lock: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, unix.EWOULDBLOCK) {
+			file.Close()
+			return nil, fmt.Errorf("error tomando lock: %w", err)
+		}
+		if timeout >= 0 && !time.Now().Before(deadline) {
+			holder := lockHolder(path)
+			file.Close()
+			if holder != "" {
+				return nil, fmt.Errorf("%w (PID %s)", ErrUpdateInProgress, holder)
+			}
+			return nil, ErrUpdateInProgress
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	// El PID del dueño solo es informativo
+	file.Truncate(0)
+	file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+
+	return &updateLock{file: file}, nil
+}
+
+// lockHolder retorna el PID registrado en el archivo de lock
+func lockHolder(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// release libera el lock
+func (l *updateLock) release() {
+	unix.Flock(int(l.file.Fd()), unix.LOCK_UN)
+	l.file.Close()
+}
+
+// lockPath retorna la ruta del archivo de lock
+func (u *Updater) lockPath() string {
+	return filepath.Join(u.config.DownloadPath, lockFileName)
+}
+
+// lock toma el lock de DownloadPath durante una descarga o instalación
+func (u *Updater) lock() (*updateLock, error) {
+	if err := u.ensureDownloadPath(); err != nil {
+		return nil, err
+	}
+	return acquireLock(u.lockPath(), u.config.LockTimeout)
+}
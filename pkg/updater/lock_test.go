@@ -0,0 +1,99 @@
+package updater
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// lockHelperEnv hace que el binario de test actúe como otra instancia que
+// toma el lock y queda bloqueada
+const lockHelperEnv = "JOOBPAY_UPDATER_LOCK_HELPER"
+
+func TestLockHelperProcess(t *testing.T) {
+	path := os.Getenv(lockHelperEnv)
+	if path == "" {
+		t.Skip("solo se ejecuta como proceso auxiliar")
+	}
+	if _, err := acquireLock(path, 0); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("locked")
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+func TestLockReleasedWhenProcessDies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), lockFileName)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLockHelperProcess$")
+	cmd.Env = append(os.Environ(), lockHelperEnv+"="+path)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || line != "locked\n" {
+		t.Fatalf("el proceso auxiliar no tomó el lock: %q, %v", line, err)
+	}
+
+	_, err = acquireLock(path, 0)
+	if !errors.Is(err, ErrUpdateInProgress) {
+		t.Fatalf("acquireLock con otra instancia = %v, want ErrUpdateInProgress", err)
+	}
+
+	// Al morir el proceso el sistema libera el lock
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	lock, err := acquireLock(path, time.Second)
+	if err != nil {
+		t.Fatalf("acquireLock después de matar al dueño: %v", err)
+	}
+	lock.release()
+}
+
+func TestDownloadUpdateLock(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	h.publish("1.1.0", nil)
+	if _, _, err := h.updater.CheckForUpdate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Otra instancia (u otra goroutine) usando el mismo DownloadPath
+	if err := h.updater.ensureDownloadPath(); err != nil {
+		t.Fatal(err)
+	}
+	other, err := acquireLock(h.updater.lockPath(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.updater.DownloadUpdate(); !errors.Is(err, ErrUpdateInProgress) {
+		t.Fatalf("DownloadUpdate sin esperar = %v, want ErrUpdateInProgress", err)
+	}
+
+	// Con LockTimeout espera a que la otra instancia termine
+	h.updater.config.LockTimeout = 5 * time.Second
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		other.release()
+	}()
+	if err := h.updater.DownloadUpdate(); err != nil {
+		t.Fatalf("DownloadUpdate esperando el lock: %v", err)
+	}
+	if !h.updater.IsDownloaded() {
+		t.Error("la actualización debería estar descargada")
+	}
+}
@@ -0,0 +1,102 @@
+package updater
+
+import (
+	"time"
+)
+
+// Preferences son las elecciones del usuario sobre las actualizaciones,
+// persistidas junto al estado del updater
+type Preferences struct {
+	// SkippedVersions son las versiones que el usuario eligió omitir ("Omitir esta versión")
+	SkippedVersions []string `json:"skipped_versions,omitempty"`
+
+	// SnoozeUntil pospone los avisos de actualización hasta esa fecha ("Recordarme mañana")
+	SnoozeUntil time.Time `json:"snooze_until,omitempty"`
+
+	// AutomaticDownload indica que el Scheduler debe descargar las
+	// actualizaciones automáticamente al detectarlas
+	AutomaticDownload bool `json:"automatic_download,omitempty"`
+}
+
+// GetPreferences retorna las preferencias persistidas
+func (u *Updater) GetPreferences() (Preferences, error) {
+	state, err := u.loadState()
+	if err != nil {
+		return Preferences{}, err
+	}
+	return state.Preferences, nil
+}
+
+// SkipVersion marca una versión para que CheckForUpdate no la ofrezca
+// (salvo que el manifiesto la marque como crítica)
+func (u *Updater) SkipVersion(version string) error {
+	return u.updatePreferences(func(prefs *Preferences) {
+		if !u.isSkipped(*prefs, version) {
+			prefs.SkippedVersions = append(prefs.SkippedVersions, version)
+		}
+	})
+}
+
+// Snooze pospone los avisos de actualización durante la duración indicada
+// (salvo para releases críticas)
+func (u *Updater) Snooze(duration time.Duration) error {
+	return u.updatePreferences(func(prefs *Preferences) {
+		prefs.SnoozeUntil = time.Now().Add(duration)
+	})
+}
+
+// SetAutomaticDownload activa o desactiva la descarga automática en el Scheduler
+func (u *Updater) SetAutomaticDownload(enabled bool) error {
+	return u.updatePreferences(func(prefs *Preferences) {
+		prefs.AutomaticDownload = enabled
+	})
+}
+
+// ResetPreferences elimina las versiones omitidas y el snooze, conservando
+// la preferencia de descarga automática
+func (u *Updater) ResetPreferences() error {
+	return u.updatePreferences(func(prefs *Preferences) {
+		prefs.SkippedVersions = nil
+		prefs.SnoozeUntil = time.Time{}
+	})
+}
+
+// updatePreferences aplica un cambio a las preferencias y las persiste
+func (u *Updater) updatePreferences(update func(*Preferences)) error {
+	u.stateMu.Lock()
+	defer u.stateMu.Unlock()
+
+	state, err := u.loadState()
+	if err != nil {
+		return err
+	}
+	update(&state.Preferences)
+	return u.saveState(state)
+}
+
+// isDeferred indica si las preferencias del usuario posponen esta versión.
+// Las releases críticas ignoran skip y snooze
+func (u *Updater) isDeferred(prefs Preferences, manifest *Manifest, now time.Time) bool {
+	if manifest.Critical {
+		return false
+	}
+	if now.Before(prefs.SnoozeUntil) {
+		return true
+	}
+	return u.isSkipped(prefs, manifest.Version)
+}
+
+// isSkipped indica si el usuario omitió la versión. Se compara con el esquema
+// de versiones configurado, de modo que "1.2" omite también "1.2.0"; una
+// versión omitida que no se puede comparar solo coincide textualmente
+func (u *Updater) isSkipped(prefs Preferences, version string) bool {
+	for _, skipped := range prefs.SkippedVersions {
+		if skipped == version {
+			return true
+		}
+		if comparison, err := u.compareVersions(skipped, version); err == nil && comparison == 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNeedsPrivileges indica que el usuario actual no puede escribir en la app
+// instalada o en el directorio que la contiene (por ejemplo, /Applications
+// sin ser administrador o un bundle cuyo dueño es root) y no hay un
+// Config.Elevator configurado
+var ErrNeedsPrivileges = errors.New("se requieren privilegios de administrador para instalar la actualización")
+
+// Elevator ejecuta el instalador con privilegios cuando el usuario actual no
+// tiene permisos de escritura sobre la instalación
+type Elevator interface {
+	// Elevate debe iniciar request.Executable con privilegios, con las
+	// variables de entorno de request.Env, sin esperar a que termine (el
+	// instalador espera a que la app salga). La salida no se debe redirigir
+	// a request.LogPath: el instalador escribe el log como el usuario
+	Elevate(request ElevationRequest) error
+}
+
+// ElevationRequest describe el instalador que se debe ejecutar con privilegios
+type ElevationRequest struct {
+	// Executable es el ejecutable que, con Env, corre el instalador
+	Executable string
+
+	// Env son las variables de entorno que indican el plan de instalación
+	Env []string
+
+	// LogPath es el log de actualización que escribe el instalador. Está en
+	// DownloadPath, donde escribe el usuario: un proceso con privilegios no
+	// debe abrirlo, porque seguiría un symlink plantado en esa ruta
+	LogPath string
+
+	// Paths son las rutas sin permisos de escritura que motivan la elevación
+	Paths []string
+}
+
+// ElevatorFunc adapta una función a Elevator, por ejemplo para delegar en un
+// helper privilegiado propio o abrir un paquete de instalación (.pkg)
+type ElevatorFunc func(request ElevationRequest) error
+
+// Elevate llama a f(request)
+func (f ElevatorFunc) Elevate(request ElevationRequest) error {
+	return f(request)
+}
+
+// AppleScriptElevator pide la contraseña de un administrador con el diálogo
+// estándar de macOS (do shell script ... with administrator privileges) y
+// ejecuta el instalador como root
+type AppleScriptElevator struct {
+	// Prompt es el texto del diálogo de autenticación
+	// (ej: "MyApp necesita permisos para actualizarse.")
+	Prompt string
+}
+
+// Elevate ejecuta el instalador en segundo plano con osascript
+func (e AppleScriptElevator) Elevate(request ElevationRequest) error {
+	var command strings.Builder
+	for _, env := range request.Env {
+		command.WriteString("export " + shellQuote(env) + "; ")
+	}
+	command.WriteString("nohup " + shellQuote(request.Executable) + " > /dev/null 2>&1 &")
+
+	script := "do shell script " + appleScriptQuote(command.String()) + " with administrator privileges"
+	if e.Prompt != "" {
+		script += " with prompt " + appleScriptQuote(e.Prompt)
+	}
+
+	if out, err := exec.Command("osascript", "-e", script).CombinedOutput(); err != nil {
+		return fmt.Errorf("error solicitando privilegios: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// shellQuote escapa s para usarlo como un único argumento de /bin/sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// appleScriptQuote escapa s como literal de string de AppleScript
+func appleScriptQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// CheckWritePermissions verifica que el usuario actual puede instalar la
+// actualización sobre la app del AppLocator. Retorna ErrNeedsPrivileges con
+// las rutas sin permisos de escritura
+func (u *Updater) CheckWritePermissions() error {
+	appPath, err := u.config.AppLocator.AppPath()
+	if err != nil {
+		return fmt.Errorf("error obteniendo ruta de la app actual: %w", err)
+	}
+	if denied := u.deniedPaths(u.currentManifest(), appPath); len(denied) > 0 {
+		return fmt.Errorf("%w: sin permisos de escritura en %s", ErrNeedsPrivileges, strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+// deniedPaths retorna las rutas que el instalador debe modificar y en las que
+// el usuario actual no puede escribir: el directorio de la app (copia, swap y
+// backup), el propio bundle (que se elimina al terminar) y los destinos de
+// los componentes
+func (u *Updater) deniedPaths(manifest *Manifest, appPath string) []string {
+	var denied []string
+	check := func(path string) {
+		if !u.config.FileSystem.Writable(path) {
+			denied = append(denied, path)
+		}
+	}
+
+	check(filepath.Dir(appPath))
+	check(appPath)
+
+	if manifest != nil {
+		for _, component := range manifest.Components {
+			installPath := cleanComponentPath(component.InstallPath)
+			check(u.existingAncestor(filepath.Dir(installPath)))
+			if _, err := u.config.FileSystem.Lstat(installPath); err == nil {
+				check(installPath)
+			}
+			if component.Symlink != "" {
+				check(u.existingAncestor(filepath.Dir(cleanComponentPath(component.Symlink))))
+			}
+		}
+	}
+	return denied
+}
+
+// existingAncestor retorna path o el primer directorio padre que existe,
+// que es donde se crearán los directorios que falten
+func (u *Updater) existingAncestor(path string) string {
+	for {
+		if _, err := u.config.FileSystem.Lstat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
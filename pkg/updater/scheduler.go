@@ -0,0 +1,140 @@
+package updater
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Scheduler verifica periódicamente si hay actualizaciones, respetando las
+// preferencias del usuario (skip, snooze y descarga automática)
+type Scheduler struct {
+	updater  *Updater
+	interval time.Duration
+
+	// Jitter agrega a cada espera un retardo aleatorio en [0, Jitter), para que
+	// muchas instancias iniciadas a la vez no consulten el servidor juntas.
+	// Se debe asignar antes de Start
+	Jitter time.Duration
+
+	// OnUpdate se llama cuando hay una actualización disponible. downloaded es
+	// true si se descargó automáticamente (Preferences.AutomaticDownload)
+	OnUpdate func(version string, downloaded bool)
+
+	// OnError se llama cuando falla una verificación o descarga
+	OnError func(err error)
+
+	// OnUpdate y OnError se ejecutan en la goroutine del Scheduler: no deben
+	// llamar a Stop directamente (Stop espera a esa goroutine), sino con go s.Stop()
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewScheduler crea un Scheduler que verifica actualizaciones cada interval
+func (u *Updater) NewScheduler(interval time.Duration) *Scheduler {
+	return &Scheduler{
+		updater:  u,
+		interval: interval,
+	}
+}
+
+// Start inicia las verificaciones periódicas en background. La primera
+// verificación se hace inmediatamente
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go s.run(s.stop, s.done)
+}
+
+// Stop detiene el Scheduler y espera a que termine la verificación en curso.
+// No se debe llamar desde OnUpdate ni OnError: se bloquearía esperando a su
+// propia goroutine
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// run es el loop del Scheduler
+func (s *Scheduler) run(stop, done chan struct{}) {
+	defer close(done)
+
+	for {
+		s.check()
+
+		timer := time.NewTimer(s.nextDelay())
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// nextDelay retorna la espera hasta la próxima verificación: el intervalo más
+// un jitter aleatorio
+func (s *Scheduler) nextDelay() time.Duration {
+	if s.Jitter <= 0 {
+		return s.interval
+	}
+	return s.interval + time.Duration(rand.Int63n(int64(s.Jitter)))
+}
+
+// check ejecuta una verificación y, si corresponde, la descarga automática
+func (s *Scheduler) check() {
+	hasUpdate, version, err := s.updater.CheckForUpdate()
+	if err != nil {
+		s.reportError(err)
+		return
+	}
+	if !hasUpdate {
+		return
+	}
+
+	prefs, err := s.updater.GetPreferences()
+	if err != nil {
+		s.reportError(err)
+		return
+	}
+
+	downloaded := false
+	if prefs.AutomaticDownload {
+		if err := s.updater.DownloadUpdate(); err != nil {
+			// Otra instancia ya la está descargando
+			if errors.Is(err, ErrUpdateInProgress) {
+				return
+			}
+			s.reportError(err)
+			return
+		}
+		downloaded = true
+	}
+
+	if s.OnUpdate != nil {
+		s.OnUpdate(version, downloaded)
+	}
+}
+
+func (s *Scheduler) reportError(err error) {
+	if s.OnError != nil {
+		s.OnError(err)
+	}
+}
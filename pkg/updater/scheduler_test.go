@@ -0,0 +1,235 @@
+package updater
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// schedulerRecorder registra las llamadas a OnUpdate y OnError
+type schedulerRecorder struct {
+	mu      sync.Mutex
+	updates []string
+	errs    []error
+	calls   chan struct{}
+}
+
+func newSchedulerRecorder(s *Scheduler) *schedulerRecorder {
+	r := &schedulerRecorder{calls: make(chan struct{}, 100)}
+	s.OnUpdate = func(version string, downloaded bool) {
+		r.mu.Lock()
+		r.updates = append(r.updates, version)
+		r.mu.Unlock()
+		r.calls <- struct{}{}
+	}
+	s.OnError = func(err error) {
+		r.mu.Lock()
+		r.errs = append(r.errs, err)
+		r.mu.Unlock()
+		r.calls <- struct{}{}
+	}
+	return r
+}
+
+func (r *schedulerRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.updates) + len(r.errs)
+}
+
+// offered ejecuta una verificación del Scheduler e indica si ofreció la versión
+func offered(t *testing.T, s *Scheduler, r *schedulerRecorder) bool {
+	t.Helper()
+	before := len(r.updates)
+	s.check()
+	if len(r.errs) > 0 {
+		t.Fatalf("OnError: %v", r.errs)
+	}
+	return len(r.updates) > before
+}
+
+func TestSchedulerInterval(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	h.publish("1.1.0", nil)
+
+	s := h.updater.NewScheduler(20 * time.Millisecond)
+	r := newSchedulerRecorder(s)
+
+	start := time.Now()
+	s.Start()
+	s.Start() // ya iniciado: no lanza otra goroutine
+
+	// La primera verificación es inmediata y las siguientes cada intervalo
+	for i := 0; i < 3; i++ {
+		select {
+		case <-r.calls:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("verificación %d no llegó", i+1)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("3 verificaciones en %v, want al menos 2 intervalos", elapsed)
+	}
+
+	s.Stop()
+	stopped := r.count()
+	time.Sleep(60 * time.Millisecond)
+	if got := r.count(); got != stopped {
+		t.Errorf("%d verificaciones después de Stop", got-stopped)
+	}
+	if len(r.errs) > 0 {
+		t.Errorf("OnError: %v", r.errs)
+	}
+
+	// Stop es idempotente y el Scheduler se puede reiniciar
+	s.Stop()
+	s.Start()
+	select {
+	case <-r.calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("el Scheduler reiniciado no verificó")
+	}
+	s.Stop()
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	s := New(Config{}).NewScheduler(time.Hour)
+	if got := s.nextDelay(); got != time.Hour {
+		t.Errorf("nextDelay sin jitter = %v, want %v", got, time.Hour)
+	}
+
+	s.Jitter = 10 * time.Minute
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		delay := s.nextDelay()
+		if delay < time.Hour || delay >= time.Hour+s.Jitter {
+			t.Fatalf("nextDelay = %v, want en [1h, 1h10m)", delay)
+		}
+		seen[delay] = true
+	}
+	if len(seen) < 2 {
+		t.Error("nextDelay no varía con Jitter")
+	}
+}
+
+func TestSchedulerStopFromCallback(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	h.publish("1.1.0", nil)
+
+	s := h.updater.NewScheduler(time.Hour)
+	stopped := make(chan struct{})
+	s.OnUpdate = func(version string, downloaded bool) {
+		// Como indica la documentación de OnUpdate
+		go func() {
+			s.Stop()
+			close(stopped)
+		}()
+	}
+	s.Start()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop desde OnUpdate no terminó")
+	}
+}
+
+func TestSchedulerPreferences(t *testing.T) {
+	t.Run("versión omitida", func(t *testing.T) {
+		h := newHarness(t, "1.0.0")
+		h.publish("1.2.0", nil)
+		s := h.updater.NewScheduler(time.Hour)
+		r := newSchedulerRecorder(s)
+
+		// "1.2" y "1.2.0" son la misma versión para el comparador semver
+		if err := h.updater.SkipVersion("1.2"); err != nil {
+			t.Fatal(err)
+		}
+		if offered(t, s, r) {
+			t.Error("el Scheduler ofreció una versión omitida")
+		}
+		if err := h.updater.SkipVersion("1.2.0"); err != nil {
+			t.Fatal(err)
+		}
+		if prefs, _ := h.updater.GetPreferences(); len(prefs.SkippedVersions) != 1 {
+			t.Errorf("SkippedVersions = %v, want una sola entrada", prefs.SkippedVersions)
+		}
+
+		// Una versión posterior sí se ofrece
+		h.publish("1.2.1", nil)
+		if !offered(t, s, r) {
+			t.Error("el Scheduler no ofreció una versión no omitida")
+		}
+	})
+
+	t.Run("snooze", func(t *testing.T) {
+		h := newHarness(t, "1.0.0")
+		h.publish("1.1.0", nil)
+		s := h.updater.NewScheduler(time.Hour)
+		r := newSchedulerRecorder(s)
+
+		if err := h.updater.Snooze(time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if offered(t, s, r) {
+			t.Error("el Scheduler ofreció una versión durante el snooze")
+		}
+
+		// Vencido el snooze la versión se vuelve a ofrecer
+		err := h.updater.updatePreferences(func(prefs *Preferences) {
+			prefs.SnoozeUntil = time.Now().Add(-time.Second)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !offered(t, s, r) {
+			t.Error("el Scheduler no ofreció la versión con el snooze vencido")
+		}
+	})
+
+	t.Run("release crítica", func(t *testing.T) {
+		h := newHarness(t, "1.0.0")
+		h.publish("1.1.0", func(m *Manifest) { m.Critical = true })
+		s := h.updater.NewScheduler(time.Hour)
+		r := newSchedulerRecorder(s)
+
+		if err := h.updater.SkipVersion("1.1.0"); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.updater.Snooze(time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		if !offered(t, s, r) {
+			t.Error("una release crítica debería ignorar skip y snooze")
+		}
+	})
+
+	t.Run("descarga automática", func(t *testing.T) {
+		h := newHarness(t, "1.0.0")
+		h.publish("1.1.0", nil)
+		s := h.updater.NewScheduler(time.Hour)
+		var downloaded bool
+		s.OnUpdate = func(version string, d bool) { downloaded = d }
+		s.OnError = func(err error) { t.Errorf("OnError: %v", err) }
+
+		if err := h.updater.SetAutomaticDownload(true); err != nil {
+			t.Fatal(err)
+		}
+		s.check()
+		if !downloaded || !h.updater.IsDownloaded() {
+			t.Error("el Scheduler debería descargar la actualización")
+		}
+	})
+}
+
+func TestSchedulerReportsErrors(t *testing.T) {
+	h := newHarness(t, "1.0.0")
+	// Sin manifiesto publicado la verificación falla
+	s := h.updater.NewScheduler(time.Hour)
+	r := newSchedulerRecorder(s)
+
+	s.check()
+	if len(r.errs) != 1 || len(r.updates) != 0 {
+		t.Errorf("errores = %v, actualizaciones = %v", r.errs, r.updates)
+	}
+}
@@ -0,0 +1,152 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/bundle"
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/codesign"
+)
+
+// ErrCodeSignatureMismatch indica que el nuevo bundle no está firmado por la
+// misma identidad que la app instalada
+var ErrCodeSignatureMismatch = errors.New("la firma de código del nuevo bundle no coincide")
+
+// verifyCodeSignature compara la firma del ejecutable principal del nuevo bundle
+// con la identidad esperada. Si Config no define ExpectedTeamID o
+// ExpectedSigningIdentifier, se toman de la app instalada
+func (u *Updater) verifyCodeSignature(newAppPath, currentAppPath string) error {
+	expectedTeamID := u.config.ExpectedTeamID
+	expectedIdentifier := u.config.ExpectedSigningIdentifier
+
+	if expectedTeamID == "" || expectedIdentifier == "" {
+		current, err := readBundleSignature(currentAppPath)
+		if err != nil {
+			return fmt.Errorf("error leyendo firma de la app instalada: %w", err)
+		}
+		if expectedTeamID == "" {
+			expectedTeamID = current.TeamID
+		}
+		if expectedIdentifier == "" {
+			expectedIdentifier = current.Identifier
+		}
+	}
+
+	// Sin Team ID no hay identidad contra la cual comparar (app instalada ad-hoc)
+	if expectedTeamID == "" {
+		return fmt.Errorf("%w: la app instalada no tiene Team ID", ErrCodeSignatureMismatch)
+	}
+
+	return u.checkSignature(newAppPath, expectedTeamID, expectedIdentifier)
+}
+
+// verifyComponentSignature valida un componente adicional: debe estar firmado
+// por el mismo Team ID que la app principal y, si ya está instalado, con el
+// mismo identificador que la versión instalada
+func (u *Updater) verifyComponentSignature(newPath, installedPath, mainAppPath string) error {
+	expectedTeamID := u.config.ExpectedTeamID
+	if expectedTeamID == "" {
+		current, err := readBundleSignature(mainAppPath)
+		if err != nil {
+			return fmt.Errorf("error leyendo firma de la app instalada: %w", err)
+		}
+		expectedTeamID = current.TeamID
+	}
+	if expectedTeamID == "" {
+		return fmt.Errorf("%w: la app instalada no tiene Team ID", ErrCodeSignatureMismatch)
+	}
+
+	var expectedIdentifier string
+	if installed, err := readBundleSignature(installedPath); err == nil {
+		expectedIdentifier = installed.Identifier
+	}
+
+	return u.checkSignature(newPath, expectedTeamID, expectedIdentifier)
+}
+
+// checkSignature valida criptográficamente la firma de newPath y la compara
+// con el Team ID y, si se indica, el identificador esperados
+func (u *Updater) checkSignature(newPath, expectedTeamID, expectedIdentifier string) error {
+	signature, err := u.validateSignature(newPath, expectedTeamID, expectedIdentifier)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCodeSignatureMismatch, err)
+	}
+
+	if signature.AdHoc {
+		return fmt.Errorf("%w: el nuevo bundle tiene una firma ad-hoc", ErrCodeSignatureMismatch)
+	}
+	if signature.TeamID != expectedTeamID {
+		return fmt.Errorf("%w: Team ID esperado %q, encontrado %q", ErrCodeSignatureMismatch, expectedTeamID, signature.TeamID)
+	}
+	if signature.CertificateTeamID != signature.TeamID {
+		return fmt.Errorf("%w: el certificado firmante (%q) no corresponde al Team ID %q", ErrCodeSignatureMismatch, signature.CertificateTeamID, signature.TeamID)
+	}
+	if expectedIdentifier != "" && signature.Identifier != expectedIdentifier {
+		return fmt.Errorf("%w: identificador esperado %q, encontrado %q", ErrCodeSignatureMismatch, expectedIdentifier, signature.Identifier)
+	}
+
+	fmt.Printf("Firma de código validada (Team ID: %s, identificador: %s)\n", signature.TeamID, signature.Identifier)
+
+	return nil
+}
+
+// validateSignature valida la firma de path y retorna su identidad. Con
+// Config.CodeSignatureRoots se valida en Go; si no, con codesign y el
+// requisito de Developer ID del Team ID esperado, que además valida los
+// recursos del bundle
+func (u *Updater) validateSignature(path, expectedTeamID, expectedIdentifier string) (*codesign.Signature, error) {
+	exePath, err := signedExecutable(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if roots := u.config.CodeSignatureRoots; roots != nil {
+		return codesign.Verify(exePath, codesign.VerifyOptions{Roots: roots})
+	}
+
+	if err := verifyWithCodesign(path, signingRequirement(expectedTeamID, expectedIdentifier)); err != nil {
+		return nil, err
+	}
+	return codesign.ReadSignature(exePath)
+}
+
+// signingRequirement arma el requisito de codesign: certificado emitido por
+// Apple con el Team ID esperado y, si se indica, el identificador
+func signingRequirement(teamID, identifier string) string {
+	requirement := "anchor apple generic and certificate leaf[subject.OU] = " + requirementQuote(teamID)
+	if identifier != "" {
+		requirement += " and identifier " + requirementQuote(identifier)
+	}
+	return requirement
+}
+
+// requirementQuote escapa s como string del lenguaje de requisitos de codesign
+func requirementQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// readBundleSignature lee la firma del ejecutable principal de un bundle, o
+// la del propio archivo si path es un ejecutable suelto (herramienta o helper)
+func readBundleSignature(path string) (*codesign.Signature, error) {
+	exePath, err := signedExecutable(path)
+	if err != nil {
+		return nil, err
+	}
+	return codesign.ReadSignature(exePath)
+}
+
+// signedExecutable retorna el ejecutable principal de un bundle, o path si
+// es un ejecutable suelto
+func signedExecutable(path string) (string, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !stat.IsDir() {
+		return path, nil
+	}
+	return bundle.ExecutablePath(path)
+}
@@ -0,0 +1,137 @@
+package updater
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateFileName es el nombre por defecto del archivo de estado persistido
+const stateFileName = "updater-state.json"
+
+// updaterState es el estado que el updater persiste entre ejecuciones
+type updaterState struct {
+	// HighestVersion es la versión más alta instalada hasta ahora (high-water
+	// mark), o la de un rollback intencional. La guarda el instalador al
+	// terminar bien. Un manifiesto con una versión menor se considera un replay
+	HighestVersion string `json:"highest_version,omitempty"`
+
+	// Preferences son las preferencias del usuario (skip, snooze, descarga automática)
+	Preferences Preferences `json:"preferences"`
+
+	// LastInstall es el resultado de la última ejecución del instalador
+	LastInstall *InstallResult `json:"last_install,omitempty"`
+
+	// Feed es la última copia del manifiesto o appcast, para pedirlo de forma
+	// condicional
+	Feed *feedCache `json:"feed,omitempty"`
+}
+
+// InstallResult es el resultado de una ejecución del instalador
+type InstallResult struct {
+	// Version es la versión que se intentó instalar
+	Version string `json:"version,omitempty"`
+
+	// FinishedAt es cuándo terminó el instalador
+	FinishedAt time.Time `json:"finished_at"`
+
+	// Success indica si la actualización se instaló
+	Success bool `json:"success"`
+
+	// Error es el motivo del fallo, si lo hubo
+	Error string `json:"error,omitempty"`
+
+	// Wait es cómo terminaron los procesos de la app (vacío si la
+	// instalación falló antes de esperarlos)
+	Wait WaitOutcome `json:"wait,omitempty"`
+}
+
+// statePath retorna la ruta del archivo de estado
+func (u *Updater) statePath() string {
+	if u.config.StatePath != "" {
+		return u.config.StatePath
+	}
+	return filepath.Join(u.config.DownloadPath, stateFileName)
+}
+
+// LastInstall retorna el resultado de la última ejecución del instalador, o
+// nil si no hay ninguna registrada
+func (u *Updater) LastInstall() (*InstallResult, error) {
+	state, err := u.loadState()
+	if err != nil {
+		return nil, err
+	}
+	return state.LastInstall, nil
+}
+
+// loadState lee el estado persistido. Si el archivo no existe retorna un estado vacío
+func (u *Updater) loadState() (*updaterState, error) {
+	return readStateFile(u.statePath())
+}
+
+// saveState persiste el estado
+func (u *Updater) saveState(state *updaterState) error {
+	return writeStateFile(u.statePath(), state)
+}
+
+// updateStateFile aplica un cambio al estado guardado en path y lo persiste
+func updateStateFile(path string, update func(*updaterState)) error {
+	state, err := readStateFile(path)
+	if err != nil {
+		return err
+	}
+	update(state)
+	return writeStateFile(path, state)
+}
+
+// readStateFile lee el estado guardado en path. Si el archivo no existe
+// retorna un estado vacío
+func readStateFile(path string) (*updaterState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &updaterState{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo estado del updater: %w", err)
+	}
+
+	var state updaterState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("error parseando estado del updater: %w", err)
+	}
+	return &state, nil
+}
+
+// writeStateFile persiste el estado de forma atómica (archivo temporal + rename)
+func writeStateFile(path string, state *updaterState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creando directorio de estado: %w", err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializando estado del updater: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creando estado temporal: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error escribiendo estado del updater: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error escribiendo estado del updater: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error guardando estado del updater: %w", err)
+	}
+	return nil
+}
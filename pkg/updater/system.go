@@ -0,0 +1,392 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// FileSystem abstrae las operaciones de archivos que modifican la instalación
+// (swap, backup, limpieza y cuarentena). Existe para los tests: el updater la
+// usa dentro de la app (escritura del plan, permisos, espacio libre), pero el
+// instalador detached es otro proceso y siempre usa OSFileSystem
+type FileSystem interface {
+	// Lstat retorna la información de path sin seguir symlinks
+	Lstat(path string) (fs.FileInfo, error)
+
+	// MkdirAll crea path y sus padres
+	MkdirAll(path string, perm fs.FileMode) error
+
+	// WriteFile escribe data en name
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+
+	// Rename mueve oldpath a newpath
+	Rename(oldpath, newpath string) error
+
+	// Remove elimina un archivo o directorio vacío
+	Remove(path string) error
+
+	// RemoveAll elimina path y todo su contenido
+	RemoveAll(path string) error
+
+	// Symlink crea newname como symlink a oldname
+	Symlink(oldname, newname string) error
+
+	// Exchange intercambia atómicamente oldpath y newpath, que deben existir
+	// y estar en el mismo volumen. Si retorna error no se modificó nada
+	Exchange(oldpath, newpath string) error
+
+	// Writable indica si el usuario actual puede escribir en path
+	Writable(path string) bool
+
+	// StatVolume retorna el volumen que contiene path y su espacio libre
+	StatVolume(path string) (VolumeInfo, error)
+
+	// ClearQuarantine elimina el atributo com.apple.quarantine de un bundle
+	ClearQuarantine(path string) error
+}
+
+// ProcessLauncher abstrae la gestión de procesos: el PID actual, la espera
+// de procesos, los comandos de shell y el lanzamiento de apps e instalador.
+// Igual que FileSystem existe para los tests: dentro de la app se usa para
+// lanzar el instalador, y el instalador siempre usa OSProcessLauncher
+type ProcessLauncher interface {
+	// Getpid retorna el PID del proceso actual
+	Getpid() int
+
+	// Executable retorna el ejecutable del proceso actual, que se relanza
+	// como instalador
+	Executable() (string, error)
+
+	// ProcessAlive indica si el proceso pid sigue en ejecución
+	ProcessAlive(pid int) bool
+
+	// ProcessesForApp retorna los procesos que se ejecutan desde el bundle appPath
+	ProcessesForApp(appPath string) ([]int, error)
+
+	// ChildProcesses retorna los descendientes del proceso pid (helpers
+	// lanzados por la app)
+	ChildProcesses(pid int) ([]int, error)
+
+	// Signal envía la señal sig al proceso pid
+	Signal(pid int, sig syscall.Signal) error
+
+	// RunCommand ejecuta un comando de shell con variables de entorno extra
+	RunCommand(command string, env []string, out io.Writer) error
+
+	// Open lanza la aplicación instalada en appPath
+	Open(appPath string) error
+
+	// StartDetached inicia name en una sesión nueva, con las variables de
+	// entorno extra indicadas y la salida redirigida a logPath
+	StartDetached(name string, env []string, logPath string) (int, error)
+}
+
+// AppLocator determina el bundle .app que se actualiza
+type AppLocator interface {
+	// AppPath retorna la ruta del bundle instalado
+	AppPath() (string, error)
+}
+
+// OSFileSystem es la implementación real de FileSystem
+type OSFileSystem struct{}
+
+func (OSFileSystem) Lstat(path string) (fs.FileInfo, error) { return os.Lstat(path) }
+
+func (OSFileSystem) MkdirAll(path string, perm fs.FileMode) error { return os.MkdirAll(path, perm) }
+
+func (OSFileSystem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (OSFileSystem) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
+
+func (OSFileSystem) Remove(path string) error { return os.Remove(path) }
+
+func (OSFileSystem) RemoveAll(path string) error { return os.RemoveAll(path) }
+
+func (OSFileSystem) Symlink(oldname, newname string) error { return os.Symlink(oldname, newname) }
+
+// Writable usa access(2) con los permisos del usuario real
+func (OSFileSystem) Writable(path string) bool { return unix.Access(path, unix.W_OK) == nil }
+
+// StatVolume usa stat(2) para el dispositivo y statfs(2) para el espacio
+// disponible para usuarios sin privilegios
+func (OSFileSystem) StatVolume(path string) (VolumeInfo, error) {
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return VolumeInfo{}, err
+	}
+	var statfs unix.Statfs_t
+	if err := unix.Statfs(path, &statfs); err != nil {
+		return VolumeInfo{}, err
+	}
+	return VolumeInfo{
+		Device:    uint64(stat.Dev),
+		Available: uint64(statfs.Bavail) * uint64(statfs.Bsize),
+	}, nil
+}
+
+// OSProcessLauncher es la implementación real de ProcessLauncher
+type OSProcessLauncher struct{}
+
+func (OSProcessLauncher) Getpid() int { return os.Getpid() }
+
+// Executable retorna la ruta del ejecutable actual con los symlinks resueltos
+func (OSProcessLauncher) Executable() (string, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exePath)
+}
+
+// ProcessAlive usa la señal 0, que valida la existencia del proceso sin afectarlo
+func (OSProcessLauncher) ProcessAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// ChildProcesses recorre el árbol de procesos a partir de pid
+func (OSProcessLauncher) ChildProcesses(pid int) ([]int, error) {
+	parents, err := processParents()
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]int)
+	for child, parent := range parents {
+		children[parent] = append(children[parent], child)
+	}
+
+	var descendants []int
+	queue := []int{pid}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range children[current] {
+			descendants = append(descendants, child)
+			queue = append(queue, child)
+		}
+	}
+	sort.Ints(descendants)
+	return descendants, nil
+}
+
+// Signal envía la señal con kill(2)
+func (OSProcessLauncher) Signal(pid int, sig syscall.Signal) error {
+	return syscall.Kill(pid, sig)
+}
+
+// RunCommand ejecuta el comando con /bin/bash
+func (OSProcessLauncher) RunCommand(command string, env []string, out io.Writer) error {
+	cmd := exec.Command("/bin/bash", "-c", command)
+	cmd.Env = append(inheritedEnv(), env...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// inheritedEnv retorna el entorno del proceso sin las variables del plan de
+// instalación, para que los comandos y procesos que se lanzan no las hereden
+// (un comando que relanzara el ejecutable volvería a correr el instalador)
+func inheritedEnv() []string {
+	var env []string
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		if name == installPlanEnv || name == installPlanDigestEnv {
+			continue
+		}
+		env = append(env, variable)
+	}
+	return env
+}
+
+// runCommandAsUser ejecuta el comando con /bin/bash como el usuario uid, con
+// su HOME. Lo usa el instalador elevado, que corre como root
+func runCommandAsUser(uid int, command string, env []string, out io.Writer) error {
+	account, credential, err := userCredential(uid)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("/bin/bash", "-c", command)
+	cmd.Env = append(inheritedEnv(), "HOME="+account.HomeDir, "USER="+account.Username, "LOGNAME="+account.Username)
+	cmd.Env = append(cmd.Env, env...)
+	cmd.Dir = "/"
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// removeAsUser elimina path con rm -rf como el usuario uid, que solo puede
+// borrar lo que ya podía borrar sin privilegios
+func removeAsUser(uid int, path string) error {
+	_, credential, err := userCredential(uid)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("/bin/rm", "-rf", "--", path)
+	cmd.Dir = "/"
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// userLog es el log de actualización abierto por un proceso del usuario
+type userLog struct {
+	*os.File
+	cmd *exec.Cmd
+}
+
+// openLogAsUser crea logPath desde un proceso cat que corre como el usuario
+// uid y retorna un pipe hacia él. El instalador elevado escribe así el log
+// en DownloadPath sin abrir como root una ruta que controla el usuario
+func openLogAsUser(uid int, logPath string) (*userLog, error) {
+	_, credential, err := userCredential(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	read, write, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer read.Close()
+
+	cmd := exec.Command("/bin/sh", "-c", `exec cat > "$1"`, "sh", logPath)
+	cmd.Dir = "/"
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential}
+	cmd.Stdin = read
+	if err := cmd.Start(); err != nil {
+		write.Close()
+		return nil, fmt.Errorf("error creando archivo de log: %w", err)
+	}
+	return &userLog{File: write, cmd: cmd}, nil
+}
+
+// Close cierra el pipe y espera a que el proceso termine de escribir el log
+func (l *userLog) Close() error {
+	l.File.Close()
+	return l.cmd.Wait()
+}
+
+// userCredential retorna la cuenta del usuario uid y la credencial (uid, gid
+// y grupos) para ejecutar procesos como ese usuario
+func userCredential(uid int) (*user.User, *syscall.Credential, error) {
+	account, err := user.LookupId(strconv.Itoa(uid))
+	if err != nil {
+		return nil, nil, err
+	}
+	gid, err := strconv.Atoi(account.Gid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	credential := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	if groups, err := account.GroupIds(); err == nil {
+		for _, group := range groups {
+			if id, err := strconv.Atoi(group); err == nil {
+				credential.Groups = append(credential.Groups, uint32(id))
+			}
+		}
+	}
+	return account, credential, nil
+}
+
+// StartDetached ejecuta el proceso como completamente independiente
+func (OSProcessLauncher) StartDetached(name string, env []string, logPath string) (int, error) {
+	cmd := exec.Command(name)
+	cmd.Env = append(inheritedEnv(), env...)
+
+	// Configurar para que el proceso sea completamente independiente
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true, // Crear nueva sesión para que sobreviva al padre
+	}
+
+	// Redirigir salida a un archivo de log
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return 0, fmt.Errorf("error creando archivo de log: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	// Iniciar el proceso (no esperamos a que termine)
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("error iniciando proceso: %w", err)
+	}
+	pid := cmd.Process.Pid
+
+	// Liberar el proceso para que continúe independientemente
+	if err := cmd.Process.Release(); err != nil {
+		return 0, fmt.Errorf("error liberando proceso: %w", err)
+	}
+
+	return pid, nil
+}
+
+// ExecutableAppLocator ubica el bundle ascendiendo desde el ejecutable del
+// proceso actual. Es el AppLocator por defecto
+type ExecutableAppLocator struct {
+	// Launcher provee el ejecutable actual (default: OSProcessLauncher)
+	Launcher ProcessLauncher
+}
+
+// AppPath busca el directorio .app que contiene al ejecutable
+func (l ExecutableAppLocator) AppPath() (string, error) {
+	launcher := l.Launcher
+	if launcher == nil {
+		launcher = OSProcessLauncher{}
+	}
+
+	exePath, err := launcher.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	// Recorrer hacia arriba buscando el directorio .app
+	path := exePath
+	for {
+		if strings.HasSuffix(path, ".app") {
+			return path, nil
+		}
+		parent := filepath.Dir(path)
+		if parent == path || parent == "." {
+			break
+		}
+		path = parent
+	}
+
+	// Si no encontramos un .app, devolvemos el directorio del ejecutable (fallback)
+	return filepath.Dir(exePath), nil
+}
+
+// FixedAppLocator actualiza siempre el bundle indicado, por ejemplo una app
+// auxiliar junto a la aplicación principal
+type FixedAppLocator string
+
+// AppPath retorna la ruta fija
+func (l FixedAppLocator) AppPath() (string, error) {
+	if l == "" {
+		return "", fmt.Errorf("ruta de app vacía")
+	}
+	return string(l), nil
+}
@@ -0,0 +1,95 @@
+package updater
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ClearQuarantine limpia los atributos de cuarentena (Gatekeeper)
+func (OSFileSystem) ClearQuarantine(path string) error {
+	return exec.Command("xattr", "-d", "-r", "com.apple.quarantine", path).Run()
+}
+
+// copyTree copia un árbol con ditto, que además de permisos y symlinks
+// conserva atributos extendidos, resource forks, ACLs y los permisos de los
+// symlinks (la firma de código de un bundle puede depender de ellos)
+func copyTree(src, dst string) error {
+	out, err := exec.Command("ditto", src, dst).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ditto: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Open lanza una nueva instancia de la aplicación con LaunchServices
+func (OSProcessLauncher) Open(appPath string) error {
+	return exec.Command("open", "-n", appPath).Run()
+}
+
+// verifyWithCodesign valida con codesign la firma de path (y de todo el
+// bundle) contra requirement
+func verifyWithCodesign(path, requirement string) error {
+	out, err := exec.Command("codesign", "--verify", "--deep", "--strict", "-R="+requirement, path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("codesign --verify: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// openAsUser abre la aplicación en la sesión del usuario uid. Lo usa el
+// instalador elevado, que corre como root
+func openAsUser(uid int, appPath string) error {
+	return exec.Command("launchctl", "asuser", strconv.Itoa(uid), "open", "-n", appPath).Run()
+}
+
+// ProcessesForApp lista con ps los procesos cuyo ejecutable está dentro del bundle
+func (OSProcessLauncher) ProcessesForApp(appPath string) ([]int, error) {
+	out, err := exec.Command("ps", "-axo", "pid=,comm=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("error listando procesos: %w", err)
+	}
+
+	prefix := strings.TrimSuffix(appPath, "/") + "/"
+	var pids []int
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		// comm contiene la ruta completa del ejecutable (puede tener espacios)
+		exePath := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), fields[0]))
+		if !strings.HasPrefix(exePath, prefix) {
+			continue
+		}
+		if pid, err := strconv.Atoi(fields[0]); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// processParents lista con ps el proceso padre de cada proceso
+func processParents() (map[int]int, error) {
+	out, err := exec.Command("ps", "-axo", "pid=,ppid=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("error listando procesos: %w", err)
+	}
+
+	parents := make(map[int]int)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		if ppid, err := strconv.Atoi(fields[1]); err == nil {
+			parents[pid] = ppid
+		}
+	}
+	return parents, nil
+}
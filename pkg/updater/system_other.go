@@ -0,0 +1,121 @@
+//go:build !darwin
+
+package updater
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/bundle"
+)
+
+// copyTree copia un árbol preservando permisos y symlinks
+func copyTree(src, dst string) error {
+	return walkCopyTree(src, dst)
+}
+
+// ClearQuarantine no hace nada fuera de macOS: no existe Gatekeeper
+func (OSFileSystem) ClearQuarantine(path string) error {
+	return nil
+}
+
+// verifyWithCodesign no está disponible fuera de macOS: la firma solo se
+// puede validar en Go con Config.CodeSignatureRoots
+func verifyWithCodesign(path, requirement string) error {
+	return fmt.Errorf("codesign no está disponible en esta plataforma: configure CodeSignatureRoots")
+}
+
+// Open ejecuta directamente el ejecutable principal del bundle
+func (OSProcessLauncher) Open(appPath string) error {
+	exePath, err := bundle.ExecutablePath(appPath)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exePath)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// openAsUser ejecuta el ejecutable principal del bundle como el usuario uid.
+// Lo usa el instalador elevado, que corre como root
+func openAsUser(uid int, appPath string) error {
+	exePath, err := bundle.ExecutablePath(appPath)
+	if err != nil {
+		return err
+	}
+	_, credential, err := userCredential(uid)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(exePath)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// ProcessesForApp recorre /proc buscando procesos cuyo ejecutable está
+// dentro del bundle
+func (OSProcessLauncher) ProcessesForApp(appPath string) ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("error listando procesos: %w", err)
+	}
+
+	prefix := strings.TrimSuffix(appPath, "/") + "/"
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		exePath, err := os.Readlink(filepath.Join("/proc", entry.Name(), "exe"))
+		if err == nil && strings.HasPrefix(exePath, prefix) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// processParents lee el proceso padre de cada proceso en /proc/<pid>/stat
+func processParents() (map[int]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("error listando procesos: %w", err)
+	}
+
+	parents := make(map[int]int)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// El nombre del proceso va entre paréntesis y puede contener
+		// espacios: los campos siguientes son estado y ppid
+		end := strings.LastIndexByte(string(stat), ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[end+1:]))
+		if len(fields) < 2 {
+			continue
+		}
+		if ppid, err := strconv.Atoi(fields[1]); err == nil {
+			parents[pid] = ppid
+		}
+	}
+	return parents, nil
+}
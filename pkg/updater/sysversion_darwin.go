@@ -0,0 +1,14 @@
+//go:build darwin
+
+package updater
+
+import "syscall"
+
+// currentSystemVersion retorna la versión de macOS (ej: "14.5")
+func currentSystemVersion() string {
+	version, err := syscall.Sysctl("kern.osproductversion")
+	if err != nil {
+		return ""
+	}
+	return version
+}
@@ -0,0 +1,9 @@
+//go:build !darwin
+
+package updater
+
+// currentSystemVersion retorna "" fuera de macOS: no se filtra por
+// sparkle:minimumSystemVersion salvo que se configure Config.SystemVersion
+func currentSystemVersion() string {
+	return ""
+}
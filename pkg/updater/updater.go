@@ -1,10 +1,17 @@
 package updater
 
 import (
+	"crypto/x509"
 	"fmt"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/utils"
 )
 
 // Config contiene la configuración del Updater
@@ -16,8 +23,11 @@ type Config struct {
 	// Ejemplo: "https://s3.amazonaws.com/mybucket/updates/"
 	SourceURL string
 
-	// ZipFileName es el nombre del archivo ZIP a descargar
-	// Ejemplo: "myapp.zip"``
+	// ZipFileName es el nombre local del ZIP descargado. También se usa para
+	// construir la URL de descarga (SourceURL + ZipFileName) cuando el manifiesto
+	// no incluye url (manifiestos legacy). Si está vacío se usa el nombre del
+	// payload indicado en el manifiesto
+	// Ejemplo: "myapp.zip"
 	ZipFileName string
 
 	// DownloadPath es la ruta local para descargas temporales
@@ -32,53 +42,309 @@ type Config struct {
 
 	// BeforeUpdateCommand es el comando que se ejecuta antes de la actualización
 	BeforeUpdateCommand string
+
+	// WaitTimeout es cuánto espera el instalador a que terminen los procesos
+	// de la app antes de aplicar WaitTimeoutAction. Default: 2 minutos
+	WaitTimeout time.Duration
+
+	// WaitTimeoutAction es qué hace el instalador si los procesos no terminan
+	// a tiempo: cancelar (WaitTimeoutAbort, default), SIGTERM y luego SIGKILL
+	// (WaitTimeoutTerminate) o SIGKILL (WaitTimeoutKill)
+	WaitTimeoutAction WaitTimeoutAction
+
+	// TerminateGracePeriod es cuánto se espera después de cada señal antes de
+	// escalar o cancelar. Default: 10 segundos
+	TerminateGracePeriod time.Duration
+
+	// LockTimeout es cuánto esperan DownloadUpdate, ApplyUpdate y
+	// CleanDownload si otra instancia está usando DownloadPath. Con 0 (default)
+	// retornan ErrUpdateInProgress sin esperar
+	LockTimeout time.Duration
+
+	// VerifyCodeSignature exige que el ejecutable del nuevo bundle esté firmado
+	// con el mismo Team ID e identificador que la app instalada antes del swap.
+	// En macOS la firma se valida con codesign --verify --deep --strict y un
+	// requisito anclado en Apple; en otras plataformas se requiere
+	// CodeSignatureRoots
+	VerifyCodeSignature bool
+
+	// CodeSignatureRoots valida la firma en Go en lugar de con codesign: los
+	// hashes de página del ejecutable, la firma CMS y la cadena del
+	// certificado hasta estas raíces. No valida los recursos del bundle, por
+	// lo que en macOS se recomienda dejarlo vacío
+	CodeSignatureRoots *x509.CertPool
+
+	// ExpectedTeamID es el Team ID requerido al verificar la firma.
+	// Si está vacío se toma de la app instalada
+	ExpectedTeamID string
+
+	// ExpectedSigningIdentifier es el identificador de firma requerido.
+	// Si está vacío se toma de la app instalada
+	ExpectedSigningIdentifier string
+
+	// AllowDowngrade permite instalar versiones anteriores a la actual cuando
+	// el manifiesto las marca con "rollback": true. Por defecto solo se
+	// aceptan versiones estrictamente mayores
+	AllowDowngrade bool
+
+	// AppcastURL es la URL de un appcast de Sparkle. Si está definida se usa
+	// en lugar del manifiesto darwin-{arch}.json
+	AppcastURL string
+
+	// Channels son los canales del appcast aceptados además del canal por
+	// defecto (items sin sparkle:channel). Ejemplo: []string{"beta"}
+	Channels []string
+
+	// EdDSAPublicKey es la clave pública Ed25519 en base64 (SUPublicEDKey).
+	// Si está definida, toda descarga debe tener una firma EdDSA válida
+	EdDSAPublicKey string
+
+	// ManifestPublicKey es la clave pública Ed25519 en base64 con la que se
+	// firma el manifiesto (o appcast). Si está definida, CheckForUpdate exige
+	// una firma válida en signatures/{sha256}.sig (ver ManifestSignaturePath)
+	// o, en servidores anteriores, en {manifiesto}.sig
+	ManifestPublicKey string
+
+	// ComponentPaths son las rutas donde el manifiesto puede instalar
+	// componentes: install_path y symlink deben ser una de ellas o estar
+	// debajo de una (se expande "~"). Sin ComponentPaths se rechazan los
+	// manifiestos con componentes
+	ComponentPaths []string
+
+	// SystemVersion es la versión de macOS usada para filtrar por
+	// sparkle:minimumSystemVersion. Default: la versión del sistema actual
+	SystemVersion string
+
+	// VersionComparator define el esquema de versionado (SemverComparator,
+	// CalverComparator, BuildNumberComparator o uno propio). Default: semver
+	VersionComparator VersionComparator
+
+	// StatePath es la ruta del archivo donde se persiste el estado del updater
+	// (high-water mark de versiones). Default: DownloadPath/updater-state.json
+	StatePath string
+
+	// FileSystem reemplaza las operaciones de archivos que hace la app (plan
+	// de instalación, permisos, espacio libre y limpieza) en tests. El swap,
+	// backup y limpieza del instalador detached siempre usan OSFileSystem.
+	// Default: OSFileSystem
+	FileSystem FileSystem
+
+	// ProcessLauncher reemplaza la gestión de procesos de la app (PID actual,
+	// ejecutable y lanzamiento del instalador) en tests. La espera, los
+	// comandos y el relanzamiento del instalador detached siempre usan
+	// OSProcessLauncher. Default: OSProcessLauncher
+	ProcessLauncher ProcessLauncher
+
+	// Elevator ejecuta el instalador con privilegios cuando el usuario no
+	// puede escribir en la app instalada (ver AppleScriptElevator). Si es nil,
+	// ApplyUpdate retorna ErrNeedsPrivileges
+	Elevator Elevator
+
+	// AppLocator determina el bundle a actualizar. Default: el bundle del
+	// ejecutable actual (ExecutableAppLocator). Con FixedAppLocator se puede
+	// actualizar otra app, por ejemplo una app auxiliar
+	AppLocator AppLocator
 }
 
 // Manifest representa la estructura del archivo JSON de manifiesto
 type Manifest struct {
-	Version  string `json:"version"`
+	Version string `json:"version"`
+
+	// Checksum es el SHA-256 del ZIP (formato legacy, se mantiene por compatibilidad)
 	Checksum string `json:"checksum"`
+
+	// Digests contiene los hashes del ZIP por algoritmo (sha256, sha512, blake3)
+	Digests map[string]string `json:"digests,omitempty"`
+
+	// Rollback marca un downgrade intencional. Solo se instala si
+	// Config.AllowDowngrade está activo
+	Rollback bool `json:"rollback,omitempty"`
+
+	// Critical marca una release obligatoria: se ofrece aunque el usuario haya
+	// omitido la versión o pospuesto los avisos
+	Critical bool `json:"critical,omitempty"`
+
+	// URL es la URL del ZIP: absoluta o relativa a SourceURL
+	// (ej: "releases/1.2.3/myapp.zip"). Si está vacía se usa SourceURL + ZipFileName
+	URL string `json:"url,omitempty"`
+
+	// Size es el tamaño del ZIP en bytes (0 si no se conoce)
+	Size int64 `json:"size,omitempty"`
+
+	// InstalledSize es el tamaño descomprimido del ZIP en bytes (0 si no se
+	// conoce). Se usa para verificar el espacio libre antes de descargar
+	InstalledSize int64 `json:"installed_size,omitempty"`
+
+	// EdSignature es la firma Ed25519 del ZIP en base64 (sparkle:edSignature)
+	EdSignature string `json:"ed_signature,omitempty"`
+
+	// Components son componentes adicionales (helper privilegiado, herramienta
+	// de línea de comandos, etc.) que se instalan junto con la app en una
+	// sola transacción
+	Components []Component `json:"components,omitempty"`
 }
 
-// Updater gestiona el ciclo de vida de las actualizaciones
+// expectedDigest retorna el algoritmo más fuerte soportado del manifiesto y su
+// digest. Los manifiestos que solo tienen checksum se validan con SHA-256
+func (m *Manifest) expectedDigest() (string, string, error) {
+	digests := make(map[string]string, len(m.Digests)+1)
+	for algorithm, digest := range m.Digests {
+		digests[algorithm] = digest
+	}
+	if m.Checksum != "" {
+		if _, ok := digests[utils.HashSHA256]; !ok {
+			digests[utils.HashSHA256] = m.Checksum
+		}
+	}
+
+	return utils.StrongestDigest(digests)
+}
+
+// Updater gestiona el ciclo de vida de las actualizaciones. Es seguro usarlo
+// desde varias goroutines (por ejemplo la UI y el Scheduler):
+//   - CheckForUpdate concurrentes comparten una sola consulta en curso
+//   - DownloadUpdate y ApplyUpdate trabajan con el manifiesto vigente al
+//     iniciar, aunque otra goroutine lo reemplace
+//   - las descargas e instalaciones se serializan con el lock de DownloadPath
+//   - las lecturas y escrituras del estado persistido se serializan
+//
+// Config no se puede modificar después de New
 type Updater struct {
-	config   Config
+	config Config
+
+	// mu protege manifest. Un manifiesto guardado no se modifica: se reemplaza
+	mu       sync.RWMutex
 	manifest *Manifest
+
+	// stateMu serializa las lecturas y escrituras del estado persistido
+	stateMu sync.Mutex
+
+	// checkMu protege check, la consulta de CheckForUpdate en curso
+	checkMu sync.Mutex
+	check   *checkCall
 }
 
 // New crea una nueva instancia del Updater
 func New(config Config) *Updater {
-	// Expandir ~ en DownloadPath
-	if strings.HasPrefix(config.DownloadPath, "~/") {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			config.DownloadPath = filepath.Join(home, config.DownloadPath[2:])
-		}
-	}
+	// Expandir ~ en DownloadPath y StatePath
+	config.DownloadPath = expandHome(config.DownloadPath)
+	config.StatePath = expandHome(config.StatePath)
 
 	// Asegurar que SourceURL termina con /
 	if !strings.HasSuffix(config.SourceURL, "/") {
 		config.SourceURL += "/"
 	}
 
+	// Espera a los procesos de la app
+	if config.WaitTimeout <= 0 {
+		config.WaitTimeout = defaultWaitTimeout
+	}
+	if config.WaitTimeoutAction == "" {
+		config.WaitTimeoutAction = WaitTimeoutAbort
+	}
+	if config.TerminateGracePeriod <= 0 {
+		config.TerminateGracePeriod = defaultTerminateGracePeriod
+	}
+
+	// Implementaciones reales por defecto
+	if config.FileSystem == nil {
+		config.FileSystem = OSFileSystem{}
+	}
+	if config.ProcessLauncher == nil {
+		config.ProcessLauncher = OSProcessLauncher{}
+	}
+	if config.AppLocator == nil {
+		config.AppLocator = ExecutableAppLocator{Launcher: config.ProcessLauncher}
+	}
+
 	return &Updater{
 		config: config,
 	}
 }
 
+// expandHome expande el prefijo ~/ al directorio del usuario
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
 // GetConfig retorna la configuración actual
 func (u *Updater) GetConfig() Config {
 	return u.config
 }
 
-// GetManifest retorna el manifiesto descargado (nil si no se ha verificado)
+// GetManifest retorna el manifiesto descargado (nil si no se ha verificado).
+// Se comparte entre goroutines y no se debe modificar
 func (u *Updater) GetManifest() *Manifest {
+	return u.currentManifest()
+}
+
+// currentManifest retorna el manifiesto vigente
+func (u *Updater) currentManifest() *Manifest {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
 	return u.manifest
 }
 
+// setManifest reemplaza el manifiesto vigente
+func (u *Updater) setManifest(manifest *Manifest) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.manifest = manifest
+}
+
 // GetZipPath retorna la ruta del ZIP descargado
 func (u *Updater) GetZipPath() string {
-	return filepath.Join(u.config.DownloadPath, u.config.ZipFileName)
+	return u.zipPath(u.currentManifest())
+}
+
+// zipPath retorna la ruta local del ZIP de manifest
+func (u *Updater) zipPath(manifest *Manifest) string {
+	name := u.config.ZipFileName
+	if name == "" && manifest != nil && manifest.URL != "" {
+		if payload, err := url.Parse(manifest.URL); err == nil {
+			name = path.Base(payload.Path)
+		}
+	}
+	if name == "" || name == "." || name == "/" {
+		name = "update.zip"
+	}
+	return filepath.Join(u.config.DownloadPath, name)
+}
+
+// payloadURL resuelve la URL de descarga del ZIP: la url del manifiesto
+// (relativa a SourceURL si no es absoluta) o, en manifiestos legacy,
+// SourceURL + ZipFileName
+func (u *Updater) payloadURL(manifest *Manifest) (string, error) {
+	if manifest.URL == "" {
+		if u.config.ZipFileName == "" {
+			return "", fmt.Errorf("el manifiesto no incluye url y ZipFileName no está configurado")
+		}
+		return u.config.SourceURL + u.config.ZipFileName, nil
+	}
+	return u.resolveURL(manifest.URL)
+}
+
+// resolveURL resuelve una URL del manifiesto, absoluta o relativa a SourceURL
+func (u *Updater) resolveURL(rawURL string) (string, error) {
+	payload, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("url del manifiesto inválida: %w", err)
+	}
+	if payload.IsAbs() {
+		return payload.String(), nil
+	}
+
+	base, err := url.Parse(u.config.SourceURL)
+	if err != nil {
+		return "", fmt.Errorf("SourceURL inválida: %w", err)
+	}
+	return base.ResolveReference(payload).String(), nil
 }
 
 // ensureDownloadPath crea el directorio de descarga si no existe
@@ -0,0 +1,210 @@
+package updater
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gerrandonea-joobpay/joobpay-go-updater/internal/utils"
+)
+
+// ErrChecksumMismatch indica que el contenido descargado no coincide con el manifiesto
+var ErrChecksumMismatch = errors.New("checksum mismatch: el archivo descargado está corrupto o fue manipulado")
+
+// ErrManifestSignature indica que la firma del manifiesto no es válida o falta
+var ErrManifestSignature = errors.New("firma del manifiesto inválida")
+
+// ParseManifest decodifica un manifiesto JSON
+func ParseManifest(data []byte) (*Manifest, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("error parseando manifiesto: %w", err)
+	}
+	return &manifest, nil
+}
+
+// ManifestSignaturePath retorna la ruta, relativa al manifiesto, de su firma
+// direccionada por contenido: signatures/{sha256 del manifiesto}.sig. Como
+// cada versión del manifiesto tiene su propia firma, se publica antes que el
+// manifiesto y el cambio de ambos es atómico
+func ManifestSignaturePath(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "signatures/" + hex.EncodeToString(sum[:]) + ".sig"
+}
+
+// VerifyManifestSignature valida la firma Ed25519 separada de un manifiesto o
+// appcast (contenido del archivo .sig, en base64) contra su contenido exacto
+func VerifyManifestSignature(data, signature []byte, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("clave pública de manifiesto inválida")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: formato de firma inválido", ErrManifestSignature)
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return ErrManifestSignature
+	}
+	return nil
+}
+
+// VerifyFile valida un ZIP local contra el manifiesto con las mismas reglas que
+// DownloadUpdate: tamaño, digest más fuerte y firma EdDSA (obligatoria si se
+// indica edPublicKey)
+func (m *Manifest) VerifyFile(path, edPublicKey string) error {
+	check, err := newDownloadCheck(m, edPublicKey)
+	if err != nil {
+		return err
+	}
+
+	payload, err := check.newWriter()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error abriendo archivo: %w", err)
+	}
+	defer file.Close()
+
+	written, err := io.Copy(payload, file)
+	if err != nil {
+		return fmt.Errorf("error leyendo archivo: %w", err)
+	}
+
+	return check.verify(written, payload)
+}
+
+// downloadCheck describe las validaciones que debe pasar un archivo descargado
+type downloadCheck struct {
+	// algorithm y digest validan el contenido con un hash (opcional si hay firma)
+	algorithm string
+	digest    string
+
+	// size es el tamaño esperado en bytes (0 si no se conoce)
+	size int64
+
+	// edSignature y edPublicKey validan la firma EdDSA de Sparkle
+	edSignature []byte
+	edPublicKey ed25519.PublicKey
+}
+
+// newDownloadCheck arma las validaciones a partir del manifiesto. Se exige un
+// digest o una firma EdDSA verificable; si edPublicKey está definida, la
+// firma es obligatoria
+func newDownloadCheck(manifest *Manifest, edPublicKey string) (*downloadCheck, error) {
+	check := &downloadCheck{size: manifest.Size}
+
+	// Elegir el algoritmo más fuerte que publique el manifiesto
+	if algorithm, digest, err := manifest.expectedDigest(); err == nil {
+		check.algorithm = algorithm
+		check.digest = digest
+	}
+
+	if edPublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(edPublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("EdDSAPublicKey inválida")
+		}
+		if manifest.EdSignature == "" {
+			return nil, fmt.Errorf("el manifiesto no incluye firma EdDSA y EdDSAPublicKey está configurada")
+		}
+		signature, err := base64.StdEncoding.DecodeString(manifest.EdSignature)
+		if err != nil || len(signature) != ed25519.SignatureSize {
+			return nil, fmt.Errorf("firma EdDSA inválida en el manifiesto")
+		}
+		check.edPublicKey = ed25519.PublicKey(key)
+		check.edSignature = signature
+	}
+
+	if check.digest == "" && check.edSignature == nil {
+		if manifest.EdSignature != "" {
+			return nil, fmt.Errorf("el manifiesto solo tiene firma EdDSA: configure EdDSAPublicKey para validarla")
+		}
+		return nil, fmt.Errorf("el manifiesto no incluye checksum ni firma para validar la descarga")
+	}
+
+	return check, nil
+}
+
+// payloadWriter recibe el contenido del archivo y calcula en la misma pasada
+// el digest y el hash que valida la firma EdDSA, sin volver a leerlo
+type payloadWriter struct {
+	hasher   hash.Hash
+	verifier *utils.Ed25519Verifier
+}
+
+func (w *payloadWriter) Write(p []byte) (int, error) {
+	w.hasher.Write(p)
+	if w.verifier != nil {
+		w.verifier.Write(p)
+	}
+	return len(p), nil
+}
+
+// newWriter crea el payloadWriter con el hasher del algoritmo elegido (o uno
+// nulo si solo hay firma) y, si hay firma EdDSA, su verificador
+func (c *downloadCheck) newWriter() (*payloadWriter, error) {
+	w := &payloadWriter{hasher: nopHash{}}
+	if c.algorithm != "" {
+		hasher, err := utils.NewHasher(c.algorithm)
+		if err != nil {
+			return nil, err
+		}
+		w.hasher = hasher
+	}
+	if c.edSignature != nil {
+		verifier, err := utils.NewEd25519Verifier(c.edPublicKey, c.edSignature)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrChecksumMismatch, err)
+		}
+		w.verifier = verifier
+	}
+	return w, nil
+}
+
+// verify valida el contenido escrito en w: tamaño, digest y firma EdDSA
+func (c *downloadCheck) verify(size int64, w *payloadWriter) error {
+	if c.size > 0 && size != c.size {
+		return fmt.Errorf("%w: tamaño esperado %d bytes, descargados %d", ErrChecksumMismatch, c.size, size)
+	}
+
+	// Validar digest (comparación en tiempo constante)
+	if c.algorithm != "" {
+		fmt.Printf("Validando integridad del archivo (%s)...\n", c.algorithm)
+		if !utils.DigestsEqual(hex.EncodeToString(w.hasher.Sum(nil)), c.digest) {
+			return ErrChecksumMismatch
+		}
+	}
+
+	// Validar firma EdDSA (calculada mientras se escribía el contenido)
+	if w.verifier != nil {
+		fmt.Println("Validando firma EdDSA...")
+		if !w.verifier.Verify() {
+			return fmt.Errorf("%w: firma EdDSA inválida", ErrChecksumMismatch)
+		}
+	}
+
+	return nil
+}
+
+// nopHash descarta los datos cuando la descarga se valida solo con firma
+type nopHash struct{}
+
+func (nopHash) Write(p []byte) (int, error) { return len(p), nil }
+func (nopHash) Sum(b []byte) []byte         { return b }
+func (nopHash) Reset()                      {}
+func (nopHash) Size() int                   { return 0 }
+func (nopHash) BlockSize() int              { return 1 }
@@ -0,0 +1,218 @@
+package updater
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// VersionComparator define cómo se comparan las versiones de la app
+type VersionComparator interface {
+	// Compare retorna 1 si a > b, 0 si son equivalentes y -1 si a < b.
+	// Retorna error si alguna de las versiones no es válida para el esquema
+	Compare(a, b string) (int, error)
+}
+
+// Esquemas de versionado incluidos
+var (
+	// SemverComparator compara versiones semánticas (1.2.3, v1.2.3-beta.2).
+	// Una pre-release es menor que su release: 1.2.0-beta.2 < 1.2.0
+	SemverComparator VersionComparator = semverComparator{}
+
+	// CalverComparator compara versiones de calendario (2026.10.3, 2026.10.3-rc.1).
+	// El primer componente debe ser un año de 4 dígitos
+	CalverComparator VersionComparator = calverComparator{}
+
+	// BuildNumberComparator compara números de build (CFBundleVersion), ya sea
+	// un entero (1234) o enteros separados por puntos (1.2.3)
+	BuildNumberComparator VersionComparator = buildNumberComparator{}
+)
+
+// versionComparator retorna el comparador configurado (semver por defecto)
+func (u *Updater) versionComparator() VersionComparator {
+	if u.config.VersionComparator != nil {
+		return u.config.VersionComparator
+	}
+	return SemverComparator
+}
+
+// compareVersions compara dos versiones con el esquema configurado
+// Retorna 1 si a > b, 0 si son iguales y -1 si a < b
+func (u *Updater) compareVersions(a, b string) (int, error) {
+	return u.versionComparator().Compare(a, b)
+}
+
+type semverComparator struct{}
+
+func (semverComparator) Compare(a, b string) (int, error) {
+	va, err := normalizeSemver(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := normalizeSemver(b)
+	if err != nil {
+		return 0, err
+	}
+	return semver.Compare(va, vb), nil
+}
+
+// normalizeSemver agrega el prefijo "v" requerido por semver y valida la versión
+func normalizeSemver(version string) (string, error) {
+	v := version
+	if v != "" && v[0] != 'v' {
+		v = "v" + v
+	}
+	if !semver.IsValid(v) {
+		return "", fmt.Errorf("versión semántica inválida: %q", version)
+	}
+	return v, nil
+}
+
+type calverComparator struct{}
+
+func (calverComparator) Compare(a, b string) (int, error) {
+	pa, err := parseCalver(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parseCalver(b)
+	if err != nil {
+		return 0, err
+	}
+	return pa.compare(pb), nil
+}
+
+// parseCalver valida una versión de calendario: YYYY.MM[.patch...][-prerelease]
+func parseCalver(version string) (numericVersion, error) {
+	v, err := parseNumericVersion(version, true)
+	if err != nil || len(v.core) < 2 || len(v.rawMajor) != 4 || v.core[1] < 1 || v.core[1] > 12 {
+		return numericVersion{}, fmt.Errorf("versión de calendario inválida: %q", version)
+	}
+	return v, nil
+}
+
+type buildNumberComparator struct{}
+
+func (buildNumberComparator) Compare(a, b string) (int, error) {
+	pa, err := parseNumericVersion(a, false)
+	if err != nil {
+		return 0, fmt.Errorf("número de build inválido: %q", a)
+	}
+	pb, err := parseNumericVersion(b, false)
+	if err != nil {
+		return 0, fmt.Errorf("número de build inválido: %q", b)
+	}
+	return pa.compare(pb), nil
+}
+
+// numericVersion es una versión formada por enteros separados por puntos con
+// un sufijo de pre-release opcional
+type numericVersion struct {
+	core       []uint64
+	prerelease []string
+
+	// rawMajor es el texto original del primer componente (calver valida el año)
+	rawMajor string
+}
+
+// parseNumericVersion parsea "1.2.3[-pre.1][+build]". La metadata de build se
+// ignora; la pre-release solo se admite si allowPrerelease es true
+func parseNumericVersion(version string, allowPrerelease bool) (numericVersion, error) {
+	v := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+
+	var result numericVersion
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		if !allowPrerelease || i == len(v)-1 {
+			return numericVersion{}, fmt.Errorf("pre-release inválida: %q", version)
+		}
+		result.prerelease = strings.Split(v[i+1:], ".")
+		for _, id := range result.prerelease {
+			if id == "" {
+				return numericVersion{}, fmt.Errorf("pre-release inválida: %q", version)
+			}
+		}
+		v = v[:i]
+	}
+
+	if v == "" {
+		return numericVersion{}, fmt.Errorf("versión vacía")
+	}
+	for i, part := range strings.Split(v, ".") {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return numericVersion{}, fmt.Errorf("componente inválido %q en %q", part, version)
+		}
+		if i == 0 {
+			result.rawMajor = part
+		}
+		result.core = append(result.core, n)
+	}
+
+	return result, nil
+}
+
+// compare aplica las reglas de precedencia de semver a versiones numéricas:
+// componentes numéricos (los faltantes valen 0) y luego pre-release
+func (v numericVersion) compare(other numericVersion) int {
+	for i := 0; i < len(v.core) || i < len(other.core); i++ {
+		var a, b uint64
+		if i < len(v.core) {
+			a = v.core[i]
+		}
+		if i < len(other.core) {
+			b = other.core[i]
+		}
+		if a != b {
+			return compareUint(a, b)
+		}
+	}
+	return comparePrerelease(v.prerelease, other.prerelease)
+}
+
+// comparePrerelease compara identificadores de pre-release según semver 2.0:
+// sin pre-release es mayor; numéricos < alfanuméricos; más identificadores gana
+func comparePrerelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, errA := strconv.ParseUint(a[i], 10, 64)
+		nb, errB := strconv.ParseUint(b[i], 10, 64)
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				return compareUint(na, nb)
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareUint(uint64(len(a)), uint64(len(b)))
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+package updater
+
+import "testing"
+
+func TestVersionComparators(t *testing.T) {
+	tests := []struct {
+		name       string
+		comparator VersionComparator
+		a, b       string
+		want       int
+	}{
+		{"semver mayor", SemverComparator, "1.2.3", "1.2.2", 1},
+		{"semver con prefijo v", SemverComparator, "v1.2.3", "1.2.3", 0},
+		{"semver pre-release menor que release", SemverComparator, "1.2.0-beta.2", "1.2.0", -1},
+		{"semver release mayor que pre-release", SemverComparator, "1.2.0", "1.2.0-beta.2", 1},
+		{"semver pre-release numérica", SemverComparator, "1.2.0-beta.10", "1.2.0-beta.2", 1},
+		{"calver mes", CalverComparator, "2026.10.3", "2026.9.12", 1},
+		{"calver componente faltante", CalverComparator, "2026.10", "2026.10.0", 0},
+		{"calver pre-release", CalverComparator, "2026.10.3-rc.1", "2026.10.3", -1},
+		{"calver alfanumérica mayor que numérica", CalverComparator, "2026.10.3-rc.1", "2026.10.3-1", 1},
+		{"build entero", BuildNumberComparator, "1234", "999", 1},
+		{"build con puntos", BuildNumberComparator, "1.2.10", "1.2.9", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.comparator.Compare(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Compare(%q, %q): %v", tt.a, tt.b, err)
+			}
+			if got != tt.want {
+				t.Fatalf("Compare(%q, %q) = %d, esperado %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionComparatorsRejectInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		comparator VersionComparator
+		version    string
+	}{
+		{"semver basura", SemverComparator, "latest"},
+		{"calver sin año", CalverComparator, "26.10.3"},
+		{"calver mes inválido", CalverComparator, "2026.13.1"},
+		{"build con pre-release", BuildNumberComparator, "123-beta"},
+		{"build vacío", BuildNumberComparator, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.comparator.Compare(tt.version, tt.version); err == nil {
+				t.Fatalf("Compare(%q) no retornó error", tt.version)
+			}
+		})
+	}
+}
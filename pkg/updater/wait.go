@@ -0,0 +1,164 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// WaitTimeoutAction define qué hace el instalador si los procesos de la app
+// no terminan dentro de Config.WaitTimeout
+type WaitTimeoutAction string
+
+const (
+	// WaitTimeoutAbort cancela la actualización y elimina los archivos
+	// extraídos. La app instalada no se modifica (default)
+	WaitTimeoutAbort WaitTimeoutAction = "abort"
+
+	// WaitTimeoutTerminate envía SIGTERM y, si los procesos siguen vivos
+	// después de Config.TerminateGracePeriod, SIGKILL
+	WaitTimeoutTerminate WaitTimeoutAction = "terminate"
+
+	// WaitTimeoutKill envía SIGKILL directamente
+	WaitTimeoutKill WaitTimeoutAction = "kill"
+)
+
+// WaitOutcome describe cómo terminaron los procesos que esperó el instalador
+type WaitOutcome string
+
+const (
+	// WaitExited indica que los procesos terminaron por sí solos
+	WaitExited WaitOutcome = "exited"
+
+	// WaitTerminated indica que terminaron después de recibir SIGTERM
+	WaitTerminated WaitOutcome = "terminated"
+
+	// WaitKilled indica que hubo que enviarles SIGKILL
+	WaitKilled WaitOutcome = "killed"
+
+	// WaitTimedOut indica que no terminaron y la actualización se canceló
+	WaitTimedOut WaitOutcome = "timed_out"
+)
+
+// Valores por defecto de la espera
+const (
+	defaultWaitTimeout          = 2 * time.Minute
+	defaultTerminateGracePeriod = 10 * time.Second
+)
+
+// signalNames son los nombres de las señales que envía el instalador
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGKILL: "SIGKILL",
+}
+
+// errWaitTimeout indica que los procesos de la app no terminaron a tiempo
+var errWaitTimeout = errors.New("los procesos de la app no terminaron a tiempo")
+
+// waitForProcesses espera a que terminen los PIDs del plan, sus procesos
+// hijos y, si corresponde, todos los procesos que se ejecutan desde el
+// bundle. Si no terminan dentro de WaitTimeout aplica WaitTimeoutAction
+func (i *installer) waitForProcesses() (WaitOutcome, error) {
+	if i.waitUntilExit(i.plan.WaitTimeout) {
+		return WaitExited, nil
+	}
+
+	grace := i.plan.TerminateGracePeriod
+	if grace <= 0 {
+		grace = defaultTerminateGracePeriod
+	}
+
+	switch i.plan.WaitTimeoutAction {
+	case WaitTimeoutTerminate:
+		i.signalRunning(syscall.SIGTERM)
+		if i.waitUntilExit(grace) {
+			return WaitTerminated, nil
+		}
+		fallthrough
+	case WaitTimeoutKill:
+		i.signalRunning(syscall.SIGKILL)
+		if i.waitUntilExit(grace) {
+			return WaitKilled, nil
+		}
+	}
+
+	return WaitTimedOut, fmt.Errorf("%w (%s): %v", errWaitTimeout, i.plan.WaitTimeout, i.runningProcesses())
+}
+
+// waitUntilExit espera hasta que no quede ningún proceso en ejecución o se
+// cumpla timeout (sin límite si es 0). Retorna si terminaron todos
+func (i *installer) waitUntilExit(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if len(i.runningProcesses()) == 0 {
+			return true
+		}
+		if timeout > 0 && time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(i.pollInterval)
+	}
+}
+
+// runningProcesses retorna los procesos esperados que siguen en ejecución,
+// sin incluir al propio instalador
+func (i *installer) runningProcesses() []int {
+	seen := make(map[int]bool)
+	var running []int
+
+	for _, pid := range append(append([]int{}, i.plan.PIDs...), i.children...) {
+		if !seen[pid] && i.launcher.ProcessAlive(pid) {
+			seen[pid] = true
+			running = append(running, pid)
+		}
+	}
+	if i.plan.WaitForApp {
+		pids, err := i.launcher.ProcessesForApp(i.plan.CurrentAppPath)
+		if err != nil {
+			i.logf("No se pudieron listar los procesos de la app: %v", err)
+		}
+		self := i.launcher.Getpid()
+		for _, pid := range pids {
+			if pid != self && !seen[pid] {
+				seen[pid] = true
+				running = append(running, pid)
+			}
+		}
+	}
+	return running
+}
+
+// collectChildren registra los procesos hijos de los PIDs del plan. Se
+// hace al iniciar, mientras la app sigue viva: al terminar, sus hijos pasan
+// a depender de launchd y ya no se pueden asociar a ella
+func (i *installer) collectChildren() {
+	self := i.launcher.Getpid()
+	for _, pid := range i.plan.PIDs {
+		children, err := i.launcher.ChildProcesses(pid)
+		if err != nil {
+			i.logf("No se pudieron listar los procesos hijos de %d: %v", pid, err)
+			continue
+		}
+		for _, child := range children {
+			// El instalador también es hijo de la app
+			if child != self {
+				i.children = append(i.children, child)
+			}
+		}
+	}
+	if len(i.children) > 0 {
+		i.logf("Procesos hijos a esperar: %v", i.children)
+	}
+}
+
+// signalRunning envía sig a los procesos que siguen en ejecución
+func (i *installer) signalRunning(sig syscall.Signal) {
+	running := i.runningProcesses()
+	i.logf("Enviando %s a %v", signalNames[sig], running)
+	for _, pid := range running {
+		if err := i.launcher.Signal(pid, sig); err != nil {
+			i.logf("No se pudo enviar %s a %d: %v", signalNames[sig], pid, err)
+		}
+	}
+}